@@ -3,7 +3,9 @@ package fwd
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/advancevillage/3rd/logx"
 	"github.com/advancevillage/3rd/netx"
@@ -17,31 +19,294 @@ var (
 	NotSupportCode      = uint32(1101)
 	UpdateCode          = uint32(1200)
 	QueryCode           = uint32(1201)
+	DeleteCode          = uint32(1202)
+	NotFoundCode        = uint32(1203)
+	GroupCode           = uint32(1204)
+	GroupNotFoundCode   = uint32(1205)
+	GroupInUseCode      = uint32(1206)
+	StatsCode           = uint32(1207)
+	WatchSeqCode        = uint32(1208)
+	RoutesCode          = uint32(1209)
+	NeighCode           = uint32(1210)
+	IfaceNotFoundCode   = uint32(1211)
+	GatewayCode         = uint32(1212)
+	LeaseCode           = uint32(1213)
+	LeaseNotFoundCode   = uint32(1214)
+	OriginCode          = uint32(1215)
+	FlushCode           = uint32(1216)
+	ActionCode          = uint32(1217)
+	AclCode             = uint32(1218)
+	AclRuleCode         = uint32(1219)
+	BlockCode           = uint32(1220)
+	BlockNotFoundCode   = uint32(1221)
+	PolicerCode         = uint32(1222)
+	VlanCode            = uint32(1223)
+	TunnelCode          = uint32(1224)
+	TunnelNotFoundCode  = uint32(1225)
+	TunnelInUseCode     = uint32(1226)
+	DecapCode           = uint32(1227)
+	DecapNotFoundCode   = uint32(1228)
+	PolicyCode          = uint32(1229)
+	PolicyRuleCode      = uint32(1230)
+	PolicyNotFoundCode  = uint32(1231)
+	PolicyExistCode     = uint32(1232)
+	PolicyTableCode     = uint32(1233)
 
 	HttpRequestBodyErr = "read request body error"
 	JsonFormatErr      = "json format error"
 	NotSupportMsg      = "not support action error"
 	UpdateMsg          = "update forward error"
 	QueryMsg           = "query forward error"
+	DeleteMsg          = "delete forward error"
+	NotFoundMsg        = "forward not found error"
+	GroupMsg           = "next hop group error"
+	GroupNotFoundMsg   = "next hop group not found error"
+	GroupInUseMsg      = "next hop group in use error"
+	StatsMsg           = "query stats error"
+	WatchSeqMsg        = "watch sequence expired error"
+	RoutesMsg          = "static routes error"
+	NeighMsg           = "neighbor unresolved error"
+	IfaceNotFoundMsg   = "iface not found error"
+	GatewayMsg         = "gateway not directly connected error"
+	LeaseMsg           = "forward lease error"
+	LeaseNotFoundMsg   = "forward lease not found error"
+	OriginMsg          = "forward origin invalid error"
+	FlushMsg           = "flush learned forward error"
+	ActionMsg          = "forward action invalid error"
+	AclMsg             = "acl error"
+	AclRuleMsg         = "acl rule invalid error"
+	BlockMsg           = "source block error"
+	BlockNotFoundMsg   = "source block not found error"
+	PolicerMsg         = "forward policer invalid error"
+	VlanMsg            = "forward vlan invalid error"
+	TunnelMsg          = "tunnel error"
+	TunnelNotFoundMsg  = "tunnel not found error"
+	TunnelInUseMsg     = "tunnel in use error"
+	DecapMsg           = "tunnel decap error"
+	DecapNotFoundMsg   = "tunnel decap not found error"
+	PolicyMsg          = "policy route error"
+	PolicyRuleMsg      = "policy rule invalid error"
+	PolicyNotFoundMsg  = "policy rule not found error"
+	PolicyExistMsg     = "policy rule already exists error"
+	PolicyTableMsg     = "policy table entry invalid error"
 
-	SrvOk  = uint32(http.StatusOK)
-	SrvErr = uint32(http.StatusInternalServerError)
+	SrvOk       = uint32(http.StatusOK)
+	SrvErr      = uint32(http.StatusInternalServerError)
+	SrvNotFound = uint32(http.StatusNotFound)
+	SrvGone     = uint32(http.StatusGone)
 )
 
+//Group   非0时表项指向下一跳组, 忽略SrcMac/DstMac/Iface
+//Gateway 非空时按网关解析Iface及MAC
+//IfName  出接口名 非空时优先于Iface
+//Ttl     租约秒数 到期未续约则删除, 0表示永久有效
+//FwdAction 表项动作 redirect(默认)/drop/pass, drop/pass时忽略下一跳
+//          action已用于请求操作, 故命名为fwdAction
+//Policer 限速 为空表示不限速, 重新下发时未指定则取消限速
+//VlanIn/VlanOut 入出VLAN 0表示不带标签, 取值1~4094
+//Tunnel  非0时表项按隧道封装, 忽略Iface, VXLAN时SrcMac/DstMac为内层MAC
+//Table   非0时写入策略表, 仅IPv4, 不支持Ttl及Policer
+type updateEntry struct {
+	SrcMac    string        `json:"srcMac"`
+	DstMac    string        `json:"dstMac"`
+	Iface     uint32        `json:"iface"`
+	Ip        string        `json:"ip"`
+	Group     uint32        `json:"group"`
+	Gateway   string        `json:"gateway"`
+	IfName    string        `json:"ifName"`
+	Ttl       int           `json:"ttl"`
+	FwdAction string        `json:"fwdAction"`
+	Policer   *policerEntry `json:"policer"`
+	VlanIn    uint16        `json:"vlanIn"`
+	VlanOut   uint16        `json:"vlanOut"`
+	Tunnel    uint32        `json:"tunnel"`
+	Table     uint32        `json:"table"`
+}
+
+//Pps/Bps 报文每秒/比特每秒 BurstPkts/BurstBytes 桶深 0时取1秒的速率
+//Exceed  超限动作 drop(默认)/pass
+type policerEntry struct {
+	Pps        uint64 `json:"pps"`
+	Bps        uint64 `json:"bps"`
+	BurstPkts  uint64 `json:"burstPkts"`
+	BurstBytes uint64 `json:"burstBytes"`
+	Exceed     string `json:"exceed"`
+}
+
 type updateRequest struct {
 	proto.ActionRequest
+	updateEntry
+}
+
+type updateResponse struct {
+	proto.ActionResponse
+}
+
+type batchUpdateRequest struct {
+	proto.ActionRequest
+	Entries []*updateEntry `json:"entries"`
+}
+
+type batchDeleteRequest struct {
+	proto.ActionRequest
+	Ips []string `json:"ips"`
+}
+
+//Ttl 续约秒数 0表示沿用上次租约时长
+type renewRequest struct {
+	proto.ActionRequest
+	Ips []string `json:"ips"`
+	Ttl int      `json:"ttl"`
+}
+
+//Ip 与 Ips 可同时指定
+//Table 非0时删除策略表项
+type deleteRequest struct {
+	proto.ActionRequest
+	Ip    string   `json:"ip"`
+	Ips   []string `json:"ips"`
+	Table uint32   `json:"table"`
+}
+
+//Results 与请求条目一一对应, code为0表示成功
+type batchResponse struct {
+	proto.ActionResponse
+	Results []*proto.Error `json:"results"`
+}
+
+type hopEntry struct {
 	SrcMac string `json:"srcMac"`
 	DstMac string `json:"dstMac"`
 	Iface  uint32 `json:"iface"`
-	Ip     string `json:"ip"`
+	IfName string `json:"ifName"`
 }
 
-type updateResponse struct {
+//CreateGroup UpdateGroup DeleteGroup
+type groupRequest struct {
+	proto.ActionRequest
+	Group uint32      `json:"group"`
+	Hops  []*hopEntry `json:"hops"`
+}
+
+type groupResponse struct {
+	proto.ActionResponse
+	Groups []*fwd.GroupElem
+}
+
+//CreateTunnel UpdateTunnel DeleteTunnel
+//Type    ipip/gre/vxlan
+//Src/Dst 外层源/目的IPv4地址
+//Key     VXLAN VNI或GRE key
+//Port    VXLAN目的端口 0时取4789
+//SrcMac/DstMac/Iface/IfName 外层下一跳
+type tunnelRequest struct {
+	proto.ActionRequest
+	Tunnel uint32 `json:"tunnel"`
+	Type   string `json:"type"`
+	Src    string `json:"src"`
+	Dst    string `json:"dst"`
+	Key    uint32 `json:"key"`
+	Port   uint16 `json:"port"`
+	SrcMac string `json:"srcMac"`
+	DstMac string `json:"dstMac"`
+	Iface  uint32 `json:"iface"`
+	IfName string `json:"ifName"`
+}
+
+type tunnelResponse struct {
 	proto.ActionResponse
+	Tunnels []*fwd.TunnelElem
 }
 
+//CreateDecap DeleteDecap
+//Dst 本端隧道端点IPv4地址, Type/Key/Port同隧道
+type decapRequest struct {
+	proto.ActionRequest
+	Type string `json:"type"`
+	Dst  string `json:"dst"`
+	Key  uint32 `json:"key"`
+	Port uint16 `json:"port"`
+}
+
+type decapResponse struct {
+	proto.ActionResponse
+	Decaps []*fwd.DecapElem
+}
+
+//Src/Dst 源/目的前缀 为空表示任意
+//Sport/Dport 端口闭区间 Max为0表示任意, 须指定proto为6或17
+//IfName  入接口名 非空时优先于Iface
+//Action  allow/deny
+type aclEntry struct {
+	Src      string `json:"src"`
+	Dst      string `json:"dst"`
+	Proto    uint8  `json:"proto"`
+	SportMin uint16 `json:"sportMin"`
+	SportMax uint16 `json:"sportMax"`
+	DportMin uint16 `json:"dportMin"`
+	DportMax uint16 `json:"dportMax"`
+	Iface    uint32 `json:"iface"`
+	IfName   string `json:"ifName"`
+	Action   string `json:"action"`
+}
+
+//CreateAcl 按顺序整体替换规则集 DeleteAcl 清空规则集
+type aclRequest struct {
+	proto.ActionRequest
+	Rules []*aclEntry `json:"rules"`
+}
+
+type aclResponse struct {
+	proto.ActionResponse
+	Rules []*fwd.AclRule
+}
+
+//Ips 源地址或前缀 eg: 192.0.2.1 198.51.100.0/24
+//Ttl 封禁秒数 0表示永久, 仅CreateBlock
+type blockRequest struct {
+	proto.ActionRequest
+	Ips []string `json:"ips"`
+	Ttl int      `json:"ttl"`
+}
+
+//Results 与Ips一一对应, code为0表示成功
+type blockResponse struct {
+	proto.ActionResponse
+	Results []*proto.Error `json:"results"`
+	Blocks  []*fwd.BlockElem
+}
+
+//CreatePolicy 按优先级插入规则 DeletePolicy 按优先级删除规则
+//Priority 越小越先匹配, 唯一标识规则
+//InIface/InIfName 入接口 Src 源前缀 Dscp 为空表示任意, 其余为0表示任意
+//Table 策略表 Group 下一跳组 均为0时按Iface/IfName/SrcMac/DstMac转发
+type policyRequest struct {
+	proto.ActionRequest
+	Priority uint32 `json:"priority"`
+	InIface  uint32 `json:"inIface"`
+	InIfName string `json:"inIfName"`
+	Src      string `json:"src"`
+	Proto    uint8  `json:"proto"`
+	Dscp     *uint8 `json:"dscp"`
+	DportMin uint16 `json:"dportMin"`
+	DportMax uint16 `json:"dportMax"`
+	Table    uint32 `json:"table"`
+	Group    uint32 `json:"group"`
+	Iface    uint32 `json:"iface"`
+	IfName   string `json:"ifName"`
+	SrcMac   string `json:"srcMac"`
+	DstMac   string `json:"dstMac"`
+}
+
+type policyResponse struct {
+	proto.ActionResponse
+	Policies []*fwd.PolicyElem
+}
+
+//Origin 为空时返回全部表项, 否则仅返回static或learned
 type queryRequest struct {
 	proto.ActionRequest
+	Origin string `json:"origin"`
 }
 
 type queryResponse struct {
@@ -49,7 +314,51 @@ type queryResponse struct {
 	Tables []*fwd.FwdElem
 }
 
+//Reconcile 为true时立即重新加载文件并对账
+type routesRequest struct {
+	proto.ActionRequest
+	Reconcile bool `json:"reconcile"`
+}
+
+type routesResponse struct {
+	proto.ActionResponse
+	Drift *routeDrift
+}
+
+//Ips 已删除的学习表项
+type flushResponse struct {
+	proto.ActionResponse
+	Ips []string
+}
+
+type statsResponse struct {
+	proto.ActionResponse
+	Stats *fwd.StatsElem
+}
+
+//解析请求参数后处理, 每个请求仅写一次响应
+func (s *Srv) dispatch(wr netx.IHTTPWriteReader, b []byte, traceId string, request interface{}, response interface{}, reply *proto.ActionResponse, handle func()) {
+	reply.TraceId = traceId
+	var err = json.Unmarshal(b, request)
+	if err != nil {
+		reply.Code = SrvErr
+		reply.Errors = append(reply.Errors, &proto.Error{Code: JsonFromatCode, Msg: JsonFormatErr})
+	} else {
+		reply.Code = SrvOk
+		handle()
+	}
+	wr.Write(http.StatusOK, response)
+}
+
 func (s *Srv) httpHandler(ctx context.Context, wr netx.IHTTPWriteReader) {
+	//0. 按action及业务码统计请求
+	var (
+		action = ""
+		mw     = &metricWriter{IHTTPWriteReader: wr}
+	)
+	defer func() { s.metrics.request(action, mw.code) }()
+	wr = mw
+
 	//1. 解析参数
 	var reply = &proto.ActionResponse{
 		Code: SrvErr,
@@ -72,64 +381,711 @@ func (s *Srv) httpHandler(ctx context.Context, wr netx.IHTTPWriteReader) {
 	reply.TraceId = req.GetTraceId()
 
 	//3. 请求处理
-	switch req.GetAction() {
+	action = req.GetAction()
+	switch action {
 	case "UpdateForward":
 		var (
 			request  = &updateRequest{}
 			response = &updateResponse{}
 		)
-		response.TraceId = reply.GetTraceId()
-
-		err = json.Unmarshal(b, request)
-		if err != nil {
-			response.Code = SrvErr
-			response.Errors = append(response.Errors, &proto.Error{Code: JsonFromatCode, Msg: JsonFormatErr})
-			wr.Write(http.StatusOK, response)
-		} else {
-			response.Code = SrvOk
-			s.updateForward(sctx, response, request)
-		}
-
-		wr.Write(http.StatusOK, response)
+		s.dispatch(wr, b, reply.GetTraceId(), request, response, &response.ActionResponse, func() { s.updateForward(sctx, response, request) })
 	case "QueryForward":
 		var (
 			request  = &queryRequest{}
 			response = &queryResponse{}
 		)
-		response.TraceId = reply.GetTraceId()
-
-		err = json.Unmarshal(b, request)
-		if err != nil {
-			response.Code = SrvErr
-			response.Errors = append(response.Errors, &proto.Error{Code: JsonFromatCode, Msg: JsonFormatErr})
-			wr.Write(http.StatusOK, response)
-		} else {
-			response.Code = SrvOk
-			s.queryForward(sctx, response, request)
-		}
-
-		wr.Write(http.StatusOK, response)
+		s.dispatch(wr, b, reply.GetTraceId(), request, response, &response.ActionResponse, func() { s.queryForward(sctx, response, request) })
+	case "DeleteForward":
+		var (
+			request  = &deleteRequest{}
+			response = &batchResponse{}
+		)
+		s.dispatch(wr, b, reply.GetTraceId(), request, response, &response.ActionResponse, func() { s.deleteForward(sctx, response, request) })
+	case "BatchUpdateForward":
+		var (
+			request  = &batchUpdateRequest{}
+			response = &batchResponse{}
+		)
+		s.dispatch(wr, b, reply.GetTraceId(), request, response, &response.ActionResponse, func() { s.batchUpdateForward(sctx, response, request) })
+	case "BatchDeleteForward":
+		var (
+			request  = &batchDeleteRequest{}
+			response = &batchResponse{}
+		)
+		s.dispatch(wr, b, reply.GetTraceId(), request, response, &response.ActionResponse, func() { s.batchDeleteForward(sctx, response, request) })
+	case "RenewForward":
+		var (
+			request  = &renewRequest{}
+			response = &batchResponse{}
+		)
+		s.dispatch(wr, b, reply.GetTraceId(), request, response, &response.ActionResponse, func() { s.renewForward(sctx, response, request) })
+	case "FlushLearned":
+		var (
+			request  = &queryRequest{}
+			response = &flushResponse{}
+		)
+		s.dispatch(wr, b, reply.GetTraceId(), request, response, &response.ActionResponse, func() { s.flushLearned(sctx, response, request) })
+	case "CreateGroup", "UpdateGroup", "DeleteGroup", "QueryGroup":
+		var (
+			request  = &groupRequest{}
+			response = &groupResponse{}
+		)
+		s.dispatch(wr, b, reply.GetTraceId(), request, response, &response.ActionResponse, func() { s.group(sctx, response, request) })
+	case "CreateTunnel", "UpdateTunnel", "DeleteTunnel", "QueryTunnel":
+		var (
+			request  = &tunnelRequest{}
+			response = &tunnelResponse{}
+		)
+		s.dispatch(wr, b, reply.GetTraceId(), request, response, &response.ActionResponse, func() { s.tunnel(sctx, response, request) })
+	case "CreateDecap", "DeleteDecap", "QueryDecap":
+		var (
+			request  = &decapRequest{}
+			response = &decapResponse{}
+		)
+		s.dispatch(wr, b, reply.GetTraceId(), request, response, &response.ActionResponse, func() { s.decap(sctx, response, request) })
+	case "CreateAcl", "DeleteAcl", "QueryAcl":
+		var (
+			request  = &aclRequest{}
+			response = &aclResponse{}
+		)
+		s.dispatch(wr, b, reply.GetTraceId(), request, response, &response.ActionResponse, func() { s.acl(sctx, response, request) })
+	case "CreateBlock", "DeleteBlock", "QueryBlock":
+		var (
+			request  = &blockRequest{}
+			response = &blockResponse{}
+		)
+		s.dispatch(wr, b, reply.GetTraceId(), request, response, &response.ActionResponse, func() { s.block(sctx, response, request) })
+	case "CreatePolicy", "DeletePolicy", "QueryPolicy":
+		var (
+			request  = &policyRequest{}
+			response = &policyResponse{}
+		)
+		s.dispatch(wr, b, reply.GetTraceId(), request, response, &response.ActionResponse, func() { s.policy(sctx, response, request) })
+	case "QueryStats":
+		var (
+			request  = &queryRequest{}
+			response = &statsResponse{}
+		)
+		s.dispatch(wr, b, reply.GetTraceId(), request, response, &response.ActionResponse, func() { s.queryStats(sctx, response, request) })
+	case "QueryRoutes":
+		var (
+			request  = &routesRequest{}
+			response = &routesResponse{}
+		)
+		s.dispatch(wr, b, reply.GetTraceId(), request, response, &response.ActionResponse, func() { s.queryRoutes(sctx, response, request) })
 	default:
+		//未知action不作为标签, 避免指标基数膨胀
+		action = ""
 		reply.Errors = append(reply.Errors, &proto.Error{Code: NotSupportCode, Msg: NotSupportMsg})
 		wr.Write(http.StatusOK, reply)
 	}
 }
 
 func (s *Srv) updateForward(ctx context.Context, response *updateResponse, request *updateRequest) {
-	var err = s.fwdCli.UptFwd(ctx, request.Ip, request.Iface, request.SrcMac, request.DstMac)
+	var err error
+	switch {
+	case request.Policer != nil || request.VlanIn > 0 || request.VlanOut > 0 || request.Tunnel > 0 || request.Table > 0:
+		var elem *fwd.FwdElem
+		elem, err = s.entryElem(ctx, &request.updateEntry)
+		if err == nil {
+			err = s.fwdCli.UptFwdBatch(ctx, []*fwd.FwdElem{elem})[0]
+		}
+	case len(request.FwdAction) > 0 && request.FwdAction != fwd.ActionRedirect:
+		err = s.fwdCli.UptFwdAction(ctx, request.Ip, request.FwdAction)
+	case request.Group > 0:
+		err = s.fwdCli.UptFwdGroup(ctx, request.Ip, request.Group)
+	case len(request.Gateway) > 0:
+		err = s.fwdCli.UptFwdVia(ctx, request.Ip, request.Gateway, request.IfName)
+	default:
+		var elem = &fwd.FwdElem{Ip: request.Ip, Iface: request.Iface, IfName: request.IfName, SrcMac: request.SrcMac, DstMac: request.DstMac}
+		err = s.fwdCli.UptFwdBatch(ctx, []*fwd.FwdElem{elem})[0]
+	}
+	if e := s.hopError(err); e != nil {
+		s.logger.Errorw(ctx, "update forward fail", "gateway", request.Gateway, "err", err)
+		response.Errors = append(response.Errors, e)
+		response.Code = SrvErr
+		return
+	}
+	if errors.Is(err, fwd.ErrFwdAction) {
+		s.logger.Errorw(ctx, "update forward fail", "action", request.FwdAction, "err", err)
+		response.Errors = append(response.Errors, &proto.Error{Code: ActionCode, Msg: ActionMsg})
+		response.Code = SrvErr
+		return
+	}
+	if errors.Is(err, fwd.ErrPolicer) {
+		s.logger.Errorw(ctx, "update forward fail", "policer", request.Policer, "err", err)
+		response.Errors = append(response.Errors, &proto.Error{Code: PolicerCode, Msg: PolicerMsg})
+		response.Code = SrvErr
+		return
+	}
+	if errors.Is(err, fwd.ErrFwdVlan) {
+		s.logger.Errorw(ctx, "update forward fail", "vlanIn", request.VlanIn, "vlanOut", request.VlanOut, "err", err)
+		response.Errors = append(response.Errors, &proto.Error{Code: VlanCode, Msg: VlanMsg})
+		response.Code = SrvErr
+		return
+	}
+	if errors.Is(err, fwd.ErrTunnel) {
+		s.logger.Errorw(ctx, "update forward fail", "tunnel", request.Tunnel, "err", err)
+		response.Errors = append(response.Errors, &proto.Error{Code: TunnelCode, Msg: TunnelMsg})
+		response.Code = SrvErr
+		return
+	}
+	if errors.Is(err, fwd.ErrTunnelNotExist) {
+		s.logger.Errorw(ctx, "update forward fail", "tunnel", request.Tunnel, "err", err)
+		response.Errors = append(response.Errors, &proto.Error{Code: TunnelNotFoundCode, Msg: TunnelNotFoundMsg})
+		response.Code = SrvNotFound
+		return
+	}
+	if errors.Is(err, fwd.ErrPolicyTable) {
+		s.logger.Errorw(ctx, "update forward fail", "table", request.Table, "err", err)
+		response.Errors = append(response.Errors, &proto.Error{Code: PolicyTableCode, Msg: PolicyTableMsg})
+		response.Code = SrvErr
+		return
+	}
+	if errors.Is(err, fwd.ErrGroupNotExist) {
+		s.logger.Errorw(ctx, "update forward fail", "err", err)
+		response.Errors = append(response.Errors, &proto.Error{Code: GroupNotFoundCode, Msg: GroupNotFoundMsg})
+		response.Code = SrvNotFound
+		return
+	}
 	if err != nil {
 		s.logger.Errorw(ctx, "update forward fail", "err", err)
 		response.Errors = append(response.Errors, &proto.Error{Code: UpdateCode, Msg: UpdateMsg})
 		response.Code = SrvErr
+		return
+	}
+	s.watcher.trigger()
+	//策略表项无租约
+	if request.Table > 0 {
+		return
+	}
+	err = s.fwdCli.LeaseFwd(ctx, request.Ip, time.Duration(request.Ttl)*time.Second)
+	if err != nil {
+		s.logger.Errorw(ctx, "lease forward fail", "ip", request.Ip, "ttl", request.Ttl, "err", err)
+		response.Errors = append(response.Errors, &proto.Error{Code: LeaseCode, Msg: LeaseMsg})
+		response.Code = SrvErr
+	}
+}
+
+//转换为表项, 按网关解析下一跳
+func (s *Srv) entryElem(ctx context.Context, e *updateEntry) (*fwd.FwdElem, error) {
+	var elem = &fwd.FwdElem{Ip: e.Ip, Iface: e.Iface, IfName: e.IfName, SrcMac: e.SrcMac, DstMac: e.DstMac, Group: e.Group, Action: e.FwdAction, VlanIn: e.VlanIn, VlanOut: e.VlanOut, Tunnel: e.Tunnel, Table: e.Table}
+	if e.Table > 0 && e.Ttl > 0 {
+		return nil, fwd.ErrPolicyTable
+	}
+	if e.Policer != nil {
+		elem.Policer = fwd.Policer{Pps: e.Policer.Pps, Bps: e.Policer.Bps, BurstPkts: e.Policer.BurstPkts, BurstBytes: e.Policer.BurstBytes, Exceed: e.Policer.Exceed}
+	}
+	if e.Group <= 0 && e.Tunnel <= 0 && len(e.Gateway) > 0 && (len(e.FwdAction) <= 0 || e.FwdAction == fwd.ActionRedirect) {
+		var hop, err = s.fwdCli.ResolveHop(ctx, e.Gateway, e.IfName)
+		if err != nil {
+			return nil, err
+		}
+		elem.Iface, elem.IfName, elem.SrcMac, elem.DstMac = hop.Iface, "", hop.SrcMac, hop.DstMac
+	}
+	return elem, nil
+}
+
+func (s *Srv) batchUpdateForward(ctx context.Context, response *batchResponse, request *batchUpdateRequest) {
+	var (
+		elems = make([]*fwd.FwdElem, 0, len(request.Entries))
+		idx   = make([]int, 0, len(request.Entries))
+	)
+	response.Results = make([]*proto.Error, len(request.Entries))
+	for i, e := range request.Entries {
+		response.Results[i] = &proto.Error{}
+		if e == nil {
+			e = &updateEntry{}
+		}
+		var elem, err = s.entryElem(ctx, e)
+		if err != nil {
+			s.logger.Errorw(ctx, "batch update forward fail", "ip", e.Ip, "gateway", e.Gateway, "err", err)
+			response.Results[i] = s.hopError(err)
+			if errors.Is(err, fwd.ErrPolicyTable) {
+				response.Results[i] = &proto.Error{Code: PolicyTableCode, Msg: PolicyTableMsg}
+			}
+			if response.Results[i] == nil {
+				response.Results[i] = &proto.Error{Code: UpdateCode, Msg: UpdateMsg}
+			}
+			response.Code = SrvErr
+			continue
+		}
+		idx = append(idx, i)
+		elems = append(elems, elem)
+	}
+	var errs = s.fwdCli.UptFwdBatch(ctx, elems)
+	defer s.watcher.trigger()
+	for i, err := range errs {
+		var e = request.Entries[idx[i]]
+		if err == nil && e.Table > 0 {
+			continue
+		}
+		if err == nil {
+			err = s.fwdCli.LeaseFwd(ctx, elems[i].Ip, time.Duration(e.Ttl)*time.Second)
+			if err != nil {
+				s.logger.Errorw(ctx, "lease forward fail", "ip", elems[i].Ip, "ttl", e.Ttl, "err", err)
+				response.Results[idx[i]] = &proto.Error{Code: LeaseCode, Msg: LeaseMsg}
+				response.Code = SrvErr
+			}
+			continue
+		}
+		s.logger.Errorw(ctx, "batch update forward fail", "ip", elems[i].Ip, "err", err)
+		response.Results[idx[i]] = s.hopError(err)
+		if errors.Is(err, fwd.ErrFwdAction) {
+			response.Results[idx[i]] = &proto.Error{Code: ActionCode, Msg: ActionMsg}
+		}
+		if errors.Is(err, fwd.ErrPolicer) {
+			response.Results[idx[i]] = &proto.Error{Code: PolicerCode, Msg: PolicerMsg}
+		}
+		if errors.Is(err, fwd.ErrFwdVlan) {
+			response.Results[idx[i]] = &proto.Error{Code: VlanCode, Msg: VlanMsg}
+		}
+		if errors.Is(err, fwd.ErrTunnel) {
+			response.Results[idx[i]] = &proto.Error{Code: TunnelCode, Msg: TunnelMsg}
+		}
+		if errors.Is(err, fwd.ErrTunnelNotExist) {
+			response.Results[idx[i]] = &proto.Error{Code: TunnelNotFoundCode, Msg: TunnelNotFoundMsg}
+		}
+		if errors.Is(err, fwd.ErrPolicyTable) {
+			response.Results[idx[i]] = &proto.Error{Code: PolicyTableCode, Msg: PolicyTableMsg}
+		}
+		if response.Results[idx[i]] == nil {
+			response.Results[idx[i]] = &proto.Error{Code: UpdateCode, Msg: UpdateMsg}
+		}
+		response.Code = SrvErr
+	}
+	if response.Code != SrvOk {
+		response.Errors = append(response.Errors, &proto.Error{Code: UpdateCode, Msg: UpdateMsg})
+	}
+}
+
+//下一跳解析错误, 其他错误返回nil
+func (s *Srv) hopError(err error) *proto.Error {
+	switch {
+	case errors.Is(err, fwd.ErrNeighNotExist):
+		return &proto.Error{Code: NeighCode, Msg: NeighMsg}
+	case errors.Is(err, fwd.ErrIfaceNotExist):
+		return &proto.Error{Code: IfaceNotFoundCode, Msg: IfaceNotFoundMsg}
+	case errors.Is(err, fwd.ErrGatewayOnLink):
+		return &proto.Error{Code: GatewayCode, Msg: GatewayMsg}
+	}
+	return nil
+}
+
+//表项不存在与租约不存在区分错误码
+func (s *Srv) renewForward(ctx context.Context, response *batchResponse, request *renewRequest) {
+	var (
+		errs     = s.fwdCli.RenewFwd(ctx, request.Ips, time.Duration(request.Ttl)*time.Second)
+		notFound = false
+		failed   = false
+	)
+	response.Results = make([]*proto.Error, len(errs))
+	for i, err := range errs {
+		response.Results[i] = &proto.Error{}
+		switch {
+		case err == nil:
+		case errors.Is(err, fwd.ErrFwdNotExist):
+			response.Results[i] = &proto.Error{Code: NotFoundCode, Msg: NotFoundMsg}
+			notFound = true
+		case errors.Is(err, fwd.ErrLeaseNotExist):
+			response.Results[i] = &proto.Error{Code: LeaseNotFoundCode, Msg: LeaseNotFoundMsg}
+			notFound = true
+		default:
+			s.logger.Errorw(ctx, "renew forward fail", "ip", request.Ips[i], "err", err)
+			response.Results[i] = &proto.Error{Code: LeaseCode, Msg: LeaseMsg}
+			failed = true
+		}
+	}
+	if notFound {
+		response.Code = SrvNotFound
+		response.Errors = append(response.Errors, &proto.Error{Code: LeaseNotFoundCode, Msg: LeaseNotFoundMsg})
+	}
+	if failed {
+		response.Code = SrvErr
+		response.Errors = append(response.Errors, &proto.Error{Code: LeaseCode, Msg: LeaseMsg})
+	}
+}
+
+func (s *Srv) batchDeleteForward(ctx context.Context, response *batchResponse, request *batchDeleteRequest) {
+	s.delete(ctx, response, 0, request.Ips)
+}
+
+func (s *Srv) deleteForward(ctx context.Context, response *batchResponse, request *deleteRequest) {
+	var ips = make([]string, 0, len(request.Ips)+1)
+	if len(request.Ip) > 0 {
+		ips = append(ips, request.Ip)
+	}
+	ips = append(ips, request.Ips...)
+	s.delete(ctx, response, request.Table, ips)
+}
+
+//表项不存在与删除失败区分错误码, 仅存在不存在的表项时返回SrvNotFound
+//table 非0时删除策略表项
+func (s *Srv) delete(ctx context.Context, response *batchResponse, table uint32, ips []string) {
+	var (
+		errs     []error
+		notFound = false
+		failed   = false
+	)
+	if table > 0 {
+		errs = make([]error, len(ips))
+		for i := range ips {
+			errs[i] = s.fwdCli.DelFwdTable(ctx, table, ips[i])
+		}
+	} else {
+		errs = s.fwdCli.DelFwdBatch(ctx, ips)
+	}
+	defer s.watcher.trigger()
+	response.Results = make([]*proto.Error, len(errs))
+	for i, err := range errs {
+		response.Results[i] = &proto.Error{}
+		switch {
+		case err == nil:
+		case errors.Is(err, fwd.ErrFwdNotExist):
+			response.Results[i] = &proto.Error{Code: NotFoundCode, Msg: NotFoundMsg}
+			notFound = true
+		default:
+			s.logger.Errorw(ctx, "delete forward fail", "ip", ips[i], "err", err)
+			response.Results[i] = &proto.Error{Code: DeleteCode, Msg: DeleteMsg}
+			failed = true
+		}
+	}
+	if notFound {
+		response.Code = SrvNotFound
+		response.Errors = append(response.Errors, &proto.Error{Code: NotFoundCode, Msg: NotFoundMsg})
+	}
+	if failed {
+		response.Code = SrvErr
+		response.Errors = append(response.Errors, &proto.Error{Code: DeleteCode, Msg: DeleteMsg})
+	}
+}
+
+func (s *Srv) group(ctx context.Context, response *groupResponse, request *groupRequest) {
+	var (
+		err  error
+		hops = make([]*fwd.NextHop, 0, len(request.Hops))
+	)
+	for _, h := range request.Hops {
+		if h == nil {
+			h = &hopEntry{}
+		}
+		hops = append(hops, &fwd.NextHop{Iface: h.Iface, IfName: h.IfName, SrcMac: h.SrcMac, DstMac: h.DstMac})
+	}
+	switch request.GetAction() {
+	case "CreateGroup":
+		err = s.fwdCli.AddGroup(ctx, request.Group, hops)
+	case "UpdateGroup":
+		err = s.fwdCli.UptGroup(ctx, request.Group, hops)
+	case "DeleteGroup":
+		err = s.fwdCli.DelGroup(ctx, request.Group)
+	case "QueryGroup":
+		response.Groups, err = s.fwdCli.QryGroup(ctx)
+	}
+	if err == nil {
+		return
+	}
+	s.logger.Errorw(ctx, "group fail", "action", request.GetAction(), "group", request.Group, "err", err)
+	if e := s.hopError(err); e != nil {
+		response.Errors = append(response.Errors, e)
+		response.Code = SrvErr
+		return
+	}
+	switch {
+	case errors.Is(err, fwd.ErrGroupNotExist):
+		response.Errors = append(response.Errors, &proto.Error{Code: GroupNotFoundCode, Msg: GroupNotFoundMsg})
+		response.Code = SrvNotFound
+	case errors.Is(err, fwd.ErrGroupInUse):
+		response.Errors = append(response.Errors, &proto.Error{Code: GroupInUseCode, Msg: GroupInUseMsg})
+		response.Code = SrvErr
+	default:
+		response.Errors = append(response.Errors, &proto.Error{Code: GroupCode, Msg: GroupMsg})
+		response.Code = SrvErr
+	}
+}
+
+func (s *Srv) tunnel(ctx context.Context, response *tunnelResponse, request *tunnelRequest) {
+	var (
+		err error
+		t   = &fwd.TunnelElem{
+			Tunnel: request.Tunnel,
+			Type:   request.Type,
+			Src:    request.Src,
+			Dst:    request.Dst,
+			Key:    request.Key,
+			Port:   request.Port,
+			Iface:  request.Iface,
+			IfName: request.IfName,
+			SrcMac: request.SrcMac,
+			DstMac: request.DstMac,
+		}
+	)
+	switch request.GetAction() {
+	case "CreateTunnel":
+		err = s.fwdCli.AddTunnel(ctx, t)
+	case "UpdateTunnel":
+		err = s.fwdCli.UptTunnel(ctx, t)
+	case "DeleteTunnel":
+		err = s.fwdCli.DelTunnel(ctx, request.Tunnel)
+	case "QueryTunnel":
+		response.Tunnels, err = s.fwdCli.QryTunnel(ctx)
+	}
+	if err == nil {
+		return
+	}
+	s.logger.Errorw(ctx, "tunnel fail", "action", request.GetAction(), "tunnel", request.Tunnel, "err", err)
+	if e := s.hopError(err); e != nil {
+		response.Errors = append(response.Errors, e)
+		response.Code = SrvErr
+		return
+	}
+	switch {
+	case errors.Is(err, fwd.ErrTunnelNotExist):
+		response.Errors = append(response.Errors, &proto.Error{Code: TunnelNotFoundCode, Msg: TunnelNotFoundMsg})
+		response.Code = SrvNotFound
+	case errors.Is(err, fwd.ErrTunnelInUse):
+		response.Errors = append(response.Errors, &proto.Error{Code: TunnelInUseCode, Msg: TunnelInUseMsg})
+		response.Code = SrvErr
+	default:
+		response.Errors = append(response.Errors, &proto.Error{Code: TunnelCode, Msg: TunnelMsg})
+		response.Code = SrvErr
+	}
+}
+
+func (s *Srv) decap(ctx context.Context, response *decapResponse, request *decapRequest) {
+	var (
+		err error
+		e   = &fwd.DecapElem{Type: request.Type, Dst: request.Dst, Key: request.Key, Port: request.Port}
+	)
+	switch request.GetAction() {
+	case "CreateDecap":
+		err = s.fwdCli.AddDecap(ctx, e)
+	case "DeleteDecap":
+		err = s.fwdCli.DelDecap(ctx, e)
+	case "QueryDecap":
+		response.Decaps, err = s.fwdCli.QryDecap(ctx)
+	}
+	if err == nil {
+		return
+	}
+	s.logger.Errorw(ctx, "decap fail", "action", request.GetAction(), "type", request.Type, "dst", request.Dst, "err", err)
+	switch {
+	case errors.Is(err, fwd.ErrDecapNotExist):
+		response.Errors = append(response.Errors, &proto.Error{Code: DecapNotFoundCode, Msg: DecapNotFoundMsg})
+		response.Code = SrvNotFound
+	default:
+		response.Errors = append(response.Errors, &proto.Error{Code: DecapCode, Msg: DecapMsg})
+		response.Code = SrvErr
+	}
+}
+
+func (s *Srv) acl(ctx context.Context, response *aclResponse, request *aclRequest) {
+	var (
+		err   error
+		rules = make([]*fwd.AclRule, 0, len(request.Rules))
+	)
+	for _, r := range request.Rules {
+		if r == nil {
+			r = &aclEntry{}
+		}
+		rules = append(rules, &fwd.AclRule{
+			Src:      r.Src,
+			Dst:      r.Dst,
+			Proto:    r.Proto,
+			SportMin: r.SportMin,
+			SportMax: r.SportMax,
+			DportMin: r.DportMin,
+			DportMax: r.DportMax,
+			Iface:    r.Iface,
+			IfName:   r.IfName,
+			Action:   r.Action,
+		})
+	}
+	switch request.GetAction() {
+	case "CreateAcl":
+		err = s.fwdCli.UptAcl(ctx, rules)
+	case "DeleteAcl":
+		err = s.fwdCli.DelAcl(ctx)
+	case "QueryAcl":
+		response.Rules, err = s.fwdCli.QryAcl(ctx)
+	}
+	if err == nil {
+		return
+	}
+	s.logger.Errorw(ctx, "acl fail", "action", request.GetAction(), "rules", len(rules), "err", err)
+	switch {
+	case errors.Is(err, fwd.ErrIfaceNotExist):
+		response.Errors = append(response.Errors, &proto.Error{Code: IfaceNotFoundCode, Msg: IfaceNotFoundMsg})
+	case errors.Is(err, fwd.ErrAclRule), errors.Is(err, fwd.ErrAclLimit):
+		response.Errors = append(response.Errors, &proto.Error{Code: AclRuleCode, Msg: AclRuleMsg})
+	default:
+		response.Errors = append(response.Errors, &proto.Error{Code: AclCode, Msg: AclMsg})
+	}
+	response.Code = SrvErr
+}
+
+func (s *Srv) policy(ctx context.Context, response *policyResponse, request *policyRequest) {
+	var err error
+	switch request.GetAction() {
+	case "CreatePolicy":
+		err = s.fwdCli.AddPolicy(ctx, &fwd.PolicyElem{
+			Priority: request.Priority,
+			InIface:  request.InIface,
+			InIfName: request.InIfName,
+			Src:      request.Src,
+			Proto:    request.Proto,
+			Dscp:     request.Dscp,
+			DportMin: request.DportMin,
+			DportMax: request.DportMax,
+			Table:    request.Table,
+			Group:    request.Group,
+			Iface:    request.Iface,
+			IfName:   request.IfName,
+			SrcMac:   request.SrcMac,
+			DstMac:   request.DstMac,
+		})
+	case "DeletePolicy":
+		err = s.fwdCli.DelPolicy(ctx, request.Priority)
+	case "QueryPolicy":
+		response.Policies, err = s.fwdCli.QryPolicy(ctx)
+	}
+	if err == nil {
+		return
+	}
+	s.logger.Errorw(ctx, "policy fail", "action", request.GetAction(), "priority", request.Priority, "err", err)
+	if e := s.hopError(err); e != nil {
+		response.Errors = append(response.Errors, e)
+		response.Code = SrvErr
+		return
+	}
+	switch {
+	case errors.Is(err, fwd.ErrPolicyNotExist):
+		response.Errors = append(response.Errors, &proto.Error{Code: PolicyNotFoundCode, Msg: PolicyNotFoundMsg})
+		response.Code = SrvNotFound
+	case errors.Is(err, fwd.ErrGroupNotExist):
+		response.Errors = append(response.Errors, &proto.Error{Code: GroupNotFoundCode, Msg: GroupNotFoundMsg})
+		response.Code = SrvNotFound
+	case errors.Is(err, fwd.ErrPolicyExist):
+		response.Errors = append(response.Errors, &proto.Error{Code: PolicyExistCode, Msg: PolicyExistMsg})
+		response.Code = SrvErr
+	case errors.Is(err, fwd.ErrPolicy), errors.Is(err, fwd.ErrPolicyLimit):
+		response.Errors = append(response.Errors, &proto.Error{Code: PolicyRuleCode, Msg: PolicyRuleMsg})
+		response.Code = SrvErr
+	default:
+		response.Errors = append(response.Errors, &proto.Error{Code: PolicyCode, Msg: PolicyMsg})
+		response.Code = SrvErr
+	}
+}
+
+//黑名单条目不存在与操作失败区分错误码, 仅存在不存在的条目时返回SrvNotFound
+func (s *Srv) block(ctx context.Context, response *blockResponse, request *blockRequest) {
+	var (
+		err      error
+		notFound = false
+		failed   = false
+	)
+	if request.GetAction() == "QueryBlock" {
+		response.Blocks, err = s.fwdCli.QryBlock(ctx)
+		if err != nil {
+			s.logger.Errorw(ctx, "query block fail", "err", err)
+			response.Errors = append(response.Errors, &proto.Error{Code: BlockCode, Msg: BlockMsg})
+			response.Code = SrvErr
+		}
+		return
+	}
+	response.Results = make([]*proto.Error, len(request.Ips))
+	for i, ip := range request.Ips {
+		switch request.GetAction() {
+		case "CreateBlock":
+			err = s.fwdCli.AddBlock(ctx, ip, time.Duration(request.Ttl)*time.Second, false)
+		case "DeleteBlock":
+			err = s.fwdCli.DelBlock(ctx, ip)
+		}
+		response.Results[i] = &proto.Error{}
+		switch {
+		case err == nil:
+		case errors.Is(err, fwd.ErrBlockNotExist):
+			response.Results[i] = &proto.Error{Code: BlockNotFoundCode, Msg: BlockNotFoundMsg}
+			notFound = true
+		default:
+			s.logger.Errorw(ctx, "block fail", "action", request.GetAction(), "ip", ip, "err", err)
+			response.Results[i] = &proto.Error{Code: BlockCode, Msg: BlockMsg}
+			failed = true
+		}
+	}
+	if notFound {
+		response.Code = SrvNotFound
+		response.Errors = append(response.Errors, &proto.Error{Code: BlockNotFoundCode, Msg: BlockNotFoundMsg})
+	}
+	if failed {
+		response.Code = SrvErr
+		response.Errors = append(response.Errors, &proto.Error{Code: BlockCode, Msg: BlockMsg})
 	}
 }
 
 func (s *Srv) queryForward(ctx context.Context, response *queryResponse, request *queryRequest) {
+	switch request.Origin {
+	case "", fwd.OriginStatic, fwd.OriginLearned:
+	default:
+		response.Errors = append(response.Errors, &proto.Error{Code: OriginCode, Msg: OriginMsg})
+		response.Code = SrvErr
+		return
+	}
 	var tables, err = s.fwdCli.QryFwd(ctx)
 	if err != nil {
 		s.logger.Errorw(ctx, "query forward fail", "err", err)
 		response.Errors = append(response.Errors, &proto.Error{Code: QueryCode, Msg: QueryMsg})
 		response.Code = SrvErr
 	}
-	response.Tables = tables
+	if len(request.Origin) <= 0 {
+		response.Tables = tables
+		return
+	}
+	response.Tables = make([]*fwd.FwdElem, 0, len(tables))
+	for _, e := range tables {
+		if e.Origin == request.Origin {
+			response.Tables = append(response.Tables, e)
+		}
+	}
+}
+
+//拓扑变更后清除XDP学习的表项, 静态表项不受影响
+func (s *Srv) flushLearned(ctx context.Context, response *flushResponse, request *queryRequest) {
+	var ips, err = s.fwdCli.FlushLearned(ctx)
+	defer s.watcher.trigger()
+	response.Ips = ips
+	if err != nil {
+		s.logger.Errorw(ctx, "flush learned forward fail", "err", err)
+		response.Errors = append(response.Errors, &proto.Error{Code: FlushCode, Msg: FlushMsg})
+		response.Code = SrvErr
+		return
+	}
+	s.logger.Infow(ctx, "flush learned forward", "count", len(ips))
+}
+
+func (s *Srv) queryStats(ctx context.Context, response *statsResponse, request *queryRequest) {
+	var stats, err = s.fwdCli.QryStats(ctx)
+	if err != nil {
+		s.logger.Errorw(ctx, "query stats fail", "err", err)
+		response.Errors = append(response.Errors, &proto.Error{Code: StatsCode, Msg: StatsMsg})
+		response.Code = SrvErr
+	}
+	response.Stats = stats
+}
+
+//未配置静态路由文件或文件加载失败时返回错误, Drift 为最近一次对账结果
+func (s *Srv) queryRoutes(ctx context.Context, response *routesResponse, request *routesRequest) {
+	if s.router == nil {
+		response.Errors = append(response.Errors, &proto.Error{Code: RoutesCode, Msg: RoutesMsg})
+		response.Code = SrvNotFound
+		return
+	}
+	if request.Reconcile {
+		s.loadRoutes(ctx)
+		s.reconcile(ctx)
+	}
+	response.Drift = s.router.report()
+	if len(response.Drift.LoadError) > 0 || len(response.Drift.Failed) > 0 {
+		response.Errors = append(response.Errors, &proto.Error{Code: RoutesCode, Msg: RoutesMsg})
+		response.Code = SrvErr
+	}
 }
@@ -2,6 +2,7 @@
 #include <linux/in.h>
 #include <linux/if_ether.h>
 #include <linux/ip.h>
+#include <linux/ipv6.h>
 #include <linux/tcp.h>
 #include <linux/udp.h>
 #include <bpf/bpf_helpers.h>
@@ -19,10 +20,19 @@
 // xpd 查询内核路由通过 bpf_fib_lookup 函数
 // 
 // BPF_MAP_TYPE_LRU_HASH
-// dip   uint32  目的IP     IPv4
+// dip   uint32  目的IP     IPv4  (hfwd)
+//       uint128 目的IP     IPv6  (hfwd6)
 // smac  uint64  转发源Mac
 // dmac  uint64  转发目Mac
 // iface uint32  从哪个设备发包
+// gid   uint32  下一跳组ID, 非0时按五元组哈希从gfwd选择下一跳
+// plen  uint8   表项前缀长度, 主机路由为32或128, 用于定位表项计数
+// orig  uint8   表项来源, 控制面下发为静态, slow_fwd回写为学习
+// act   uint8   表项动作, 重定向至下一跳、丢弃(黑洞路由)或交由内核协议栈
+// pol   uint8   非0时表项限速, 按表项key查询rfwd/rfwd6令牌桶
+// vin   uint16  入VLAN, 报文802.1Q VID与之一致时表项生效, 0表示不带标签
+// vout  uint16  出VLAN, 0表示不带标签, 与入VLAN不同时push/pop/rewrite
+// tid   uint32  隧道ID, 非0时按tfwd封装后从隧道出接口发包
 //
 //eg:
 //    1: lo: <LOOPBACK,UP,LOWER_UP> mtu 65536 qdisc noqueue state UNKNOWN group default qlen 1000
@@ -50,15 +60,442 @@ struct fwd {
     __u32         ifindex;
     unsigned char smac[ETH_ALEN];
     unsigned char dmac[ETH_ALEN];
+    __u32         gid;
+    __u8          plen;
+    __u8          orig;
+    __u8          act;
+    __u8          pol;
+    __u16         vin;
+    __u16         vout;
+    __u32         tid;
+};
+
+//单层802.1Q标签, QinQ及优先级标签(VID为0)交由内核
+#define VLAN_VID_MASK 0x0fff
+
+struct vlan_hdr {
+    __be16        h_vlan_TCI;
+    __be16        h_vlan_encapsulated_proto;
+};
+
+//表项来源, 与fwd.go保持一致
+enum {
+    FWD_STATIC  = 0,
+    FWD_LEARNED = 1,
+};
+
+//表项动作, 与fwd.go保持一致
+enum {
+    FWD_REDIRECT = 0,
+    FWD_DROP     = 1,
+    FWD_PASS     = 2,
 };
 
 struct {
    __uint(type, BPF_MAP_TYPE_LRU_HASH);
-   __type(key,          0x04);
-   __type(value,        0x10);
+   __type(key,          __u32);
+   __type(value,        struct fwd);
    __uint(max_entries,  10000);
 } hfwd SEC(".maps"); 
 
+struct {
+   __uint(type, BPF_MAP_TYPE_LRU_HASH);
+   __type(key,          struct in6_addr);
+   __type(value,        struct fwd);
+   __uint(max_entries,  10000);
+} hfwd6 SEC(".maps"); 
+
+//前缀路由 BPF_MAP_TYPE_LPM_TRIE
+// prefixlen uint32  前缀长度(主机字节序)
+// addr              网络地址
+struct lpm_key {
+    __u32         prefixlen;
+    __u32         addr;
+};
+
+struct lpm_key6 {
+    __u32           prefixlen;
+    struct in6_addr addr;
+};
+
+struct {
+   __uint(type, BPF_MAP_TYPE_LPM_TRIE);
+   __type(key,          struct lpm_key);
+   __type(value,        struct fwd);
+   __uint(max_entries,  10000);
+   __uint(map_flags,    BPF_F_NO_PREALLOC);
+} lfwd SEC(".maps"); 
+
+struct {
+   __uint(type, BPF_MAP_TYPE_LPM_TRIE);
+   __type(key,          struct lpm_key6);
+   __type(value,        struct fwd);
+   __uint(max_entries,  10000);
+   __uint(map_flags,    BPF_F_NO_PREALLOC);
+} lfwd6 SEC(".maps");
+
+//下一跳组 BPF_MAP_TYPE_HASH (ECMP)
+// gid   uint32  组ID
+// count uint32  有效下一跳个数
+// nh            下一跳列表
+#define GROUP_MAX_HOPS 8
+
+struct nh {
+    __u32         ifindex;
+    unsigned char smac[ETH_ALEN];
+    unsigned char dmac[ETH_ALEN];
+};
+
+struct group {
+    __u32         count;
+    __u32         pad;
+    struct nh     nh[GROUP_MAX_HOPS];
+};
+
+struct {
+   __uint(type, BPF_MAP_TYPE_HASH);
+   __type(key,          __u32);
+   __type(value,        struct group);
+   __uint(max_entries,  1024);
+   __uint(map_flags,    BPF_F_NO_PREALLOC);
+} gfwd SEC(".maps");
+
+//转发计数 Per-CPU Map, 各CPU独立累加无需原子操作, 控制面汇总
+// sfwd  全局计数   key: 计数索引
+// ifwd  出接口计数 key: ifindex
+// cfwd  表项计数   key: prefixlen + 网络地址, 与lfwd/lfwd6的key一致
+enum {
+    STAT_FAST = 0,      //hfwd命中
+    STAT_LPM,           //lfwd命中
+    STAT_SLOW,          //bpf_fib_lookup命中
+    STAT_MISS,          //bpf_fib_lookup未命中
+    STAT_DROP,          //报文不完整
+    STAT_BLACKHOLE,     //黑洞路由丢弃
+    STAT_PASS,          //表项指定交由内核
+    STAT_ACL_DENY,      //ACL拒绝
+    STAT_BLOCKED,       //源地址黑名单丢弃
+    STAT_POLICED,       //表项限速超限
+    STAT_VLAN,          //入VLAN不匹配或带标签报文未命中, 交由内核
+    STAT_ENCAP,         //隧道封装
+    STAT_DECAP,         //隧道解封装
+    STAT_POLICY,        //策略路由命中
+    STAT_MAX  = 16,
+};
+
+struct cnt {
+    __u64         pkts;
+    __u64         bytes;
+};
+
+struct {
+   __uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+   __type(key,          __u32);
+   __type(value,        __u64);
+   __uint(max_entries,  STAT_MAX);
+} sfwd SEC(".maps");
+
+struct {
+   __uint(type, BPF_MAP_TYPE_PERCPU_HASH);
+   __type(key,          __u32);
+   __type(value,        struct cnt);
+   __uint(max_entries,  1024);
+   __uint(map_flags,    BPF_F_NO_PREALLOC);
+} ifwd SEC(".maps");
+
+struct {
+   __uint(type, BPF_MAP_TYPE_LRU_PERCPU_HASH);
+   __type(key,          struct lpm_key);
+   __type(value,        struct cnt);
+   __uint(max_entries,  10000);
+} cfwd SEC(".maps");
+
+struct {
+   __uint(type, BPF_MAP_TYPE_LRU_PERCPU_HASH);
+   __type(key,          struct lpm_key6);
+   __type(value,        struct cnt);
+   __uint(max_entries,  10000);
+} cfwd6 SEC(".maps"); 
+
+//隧道 BPF_MAP_TYPE_HASH, 仅支持IPv4外层
+// tfwd  封装下一跳 key: 隧道ID
+//       外层以太头使用smac/dmac, VXLAN内层以太头使用表项smac/dmac
+// dfwd  解封装规则 key: 本端地址 + 类型 + VNI/GRE key + UDP端口, 命中后剥离外层按内层报文转发
+//外层封装增加报文长度, 需保证出接口MTU
+#define VXLAN_PORT    4789
+#define VXLAN_FLAG_I  0x08000000
+#define GRE_KEY       0x2000
+
+enum {
+    TUN_IPIP  = 1,
+    TUN_GRE   = 2,
+    TUN_VXLAN = 3,
+};
+
+//GRE携带key
+enum {
+    TUN_F_KEY = 1,
+};
+
+//saddr/daddr 网络字节序, key/port 主机字节序, port为0时取VXLAN_PORT
+struct tunnel {
+    __u32         ifindex;
+    unsigned char smac[ETH_ALEN];
+    unsigned char dmac[ETH_ALEN];
+    __u32         saddr;
+    __u32         daddr;
+    __u32         key;
+    __u8          type;
+    __u8          flags;
+    __u16         port;
+};
+
+struct decap_key {
+    __u32         daddr;
+    __u32         key;
+    __u8          type;
+    __u8          pad;
+    __u16         port;
+};
+
+struct gre_hdr {
+    __be16        flags;
+    __be16        protocol;
+};
+
+struct vxlan_hdr {
+    __be32        flags;
+    __be32        vni;
+};
+
+struct {
+   __uint(type, BPF_MAP_TYPE_HASH);
+   __type(key,          __u32);
+   __type(value,        struct tunnel);
+   __uint(max_entries,  1024);
+   __uint(map_flags,    BPF_F_NO_PREALLOC);
+} tfwd SEC(".maps");
+
+//value 解封装计数, 多CPU原子累加
+struct {
+   __uint(type, BPF_MAP_TYPE_HASH);
+   __type(key,          struct decap_key);
+   __type(value,        struct cnt);
+   __uint(max_entries,  1024);
+   __uint(map_flags,    BPF_F_NO_PREALLOC);
+} dfwd SEC(".maps");
+
+//表项限速 BPF_MAP_TYPE_HASH, key同cfwd/cfwd6, 由控制面随表项下发
+//令牌按纳秒缩放(单位 * 1e9), 逐包补充不丢失精度
+//多个RX队列并发更新同一令牌桶, 补充及扣减在lock内完成
+// pps/rate  报文数/字节每秒 0表示不限
+// burst     桶深
+// exceed    超限动作 丢弃或交由内核协议栈
+#define NSEC 1000000000ULL
+
+enum {
+    POL_DROP = 0,
+    POL_PASS = 1,
+};
+
+struct policer {
+    __u8          exceed;
+    __u8          pad[3];
+    struct bpf_spin_lock lock;
+    __u64         pps;
+    __u64         rate;
+    __u64         burst_pkts;
+    __u64         burst_bytes;
+    __u64         tok_pkts;
+    __u64         tok_bytes;
+    __u64         last;
+    __u64         exceed_pkts;
+    __u64         exceed_bytes;
+};
+
+struct {
+   __uint(type, BPF_MAP_TYPE_HASH);
+   __type(key,          struct lpm_key);
+   __type(value,        struct policer);
+   __uint(max_entries,  10000);
+   __uint(map_flags,    BPF_F_NO_PREALLOC);
+} rfwd SEC(".maps");
+
+struct {
+   __uint(type, BPF_MAP_TYPE_HASH);
+   __type(key,          struct lpm_key6);
+   __type(value,        struct policer);
+   __uint(max_entries,  10000);
+   __uint(map_flags,    BPF_F_NO_PREALLOC);
+} rfwd6 SEC(".maps");
+
+//ACL 有序规则集, 首条匹配的规则生效, 无匹配时放行
+//afwd 双缓冲, [0, ACL_MAX_RULES)与[ACL_MAX_RULES, 2*ACL_MAX_RULES)各存一套规则
+//     控制面写入备用规则集后更新acfg切换, 实现整体原子替换
+// acfg  生效规则集 key: 0 value: set << 16 | count
+// acnt  规则命中计数 key: afwd槽位
+#define ACL_MAX_RULES 64
+
+enum {
+    ACL_ALLOW = 0,
+    ACL_DENY  = 1,
+};
+
+//src/dst 网络字节序, 掩码为0表示任意
+//port    主机字节序闭区间, 非TCP/UDP报文端口视为0
+//        分片或L4头不完整时端口未知, 指定端口的deny规则视为命中, allow规则视为不命中
+//ifindex 入接口 0表示任意
+//proto   IP协议号 0表示任意
+struct acl_rule {
+    __u32         src;
+    __u32         smask;
+    __u32         dst;
+    __u32         dmask;
+    __u16         sport_lo;
+    __u16         sport_hi;
+    __u16         dport_lo;
+    __u16         dport_hi;
+    __u32         ifindex;
+    __u8          proto;
+    __u8          action;
+    __u8          pad[2];
+};
+
+struct {
+   __uint(type, BPF_MAP_TYPE_ARRAY);
+   __type(key,          __u32);
+   __type(value,        struct acl_rule);
+   __uint(max_entries,  ACL_MAX_RULES * 2);
+} afwd SEC(".maps");
+
+struct {
+   __uint(type, BPF_MAP_TYPE_ARRAY);
+   __type(key,          __u32);
+   __type(value,        __u32);
+   __uint(max_entries,  1);
+} acfg SEC(".maps");
+
+struct {
+   __uint(type, BPF_MAP_TYPE_PERCPU_HASH);
+   __type(key,          __u32);
+   __type(value,        struct cnt);
+   __uint(max_entries,  ACL_MAX_RULES * 2);
+   __uint(map_flags,    BPF_F_NO_PREALLOC);
+} acnt SEC(".maps");
+
+//源地址黑名单 BPF_MAP_TYPE_LPM_TRIE, 解析L3后最先检查, 命中即丢弃
+// key   prefixlen + 源网络地址, 同lfwd/lfwd6
+// deadline 到期时间 unix秒, 0表示永久, 到期条目由控制面清理
+// flags    BLOCK_AUTO 控制面按源地址计数超过pps阈值自动封禁
+enum {
+    BLOCK_AUTO = 1,
+};
+
+struct block {
+    __u64         deadline;
+    __u8          flags;
+    __u8          pad[7];
+};
+
+struct {
+   __uint(type, BPF_MAP_TYPE_LPM_TRIE);
+   __type(key,          struct lpm_key);
+   __type(value,        struct block);
+   __uint(max_entries,  10000);
+   __uint(map_flags,    BPF_F_NO_PREALLOC);
+} bfwd SEC(".maps");
+
+struct {
+   __uint(type, BPF_MAP_TYPE_LPM_TRIE);
+   __type(key,          struct lpm_key6);
+   __type(value,        struct block);
+   __uint(max_entries,  10000);
+   __uint(map_flags,    BPF_F_NO_PREALLOC);
+} bfwd6 SEC(".maps");
+
+//源地址计数 key: IPv6地址, IPv4按::ffff:a.b.c.d映射
+struct {
+   __uint(type, BPF_MAP_TYPE_LRU_PERCPU_HASH);
+   __type(key,          struct in6_addr);
+   __type(value,        __u64);
+   __uint(max_entries,  65536);
+} pfwd SEC(".maps");
+
+//源地址计数开关 key: 0 value: 非0时计数, 未开启自动封禁时不访问pfwd
+struct {
+   __uint(type, BPF_MAP_TYPE_ARRAY);
+   __type(key,          __u32);
+   __type(value,        __u32);
+   __uint(max_entries,  1);
+} pcfg SEC(".maps");
+
+//策略路由 有序规则集, 仅IPv4, 按优先级在目的地址查找前匹配, 首条命中的规则生效
+//ofwd 双缓冲, 同afwd, 控制面写入备用规则集后更新ocfg切换
+// ocfg  生效规则集 key: 0 value: set << 16 | count
+// vfwd  策略表 BPF_MAP_TYPE_LPM_TRIE key: prefixlen(32 + 前缀长度) + 表ID + 网络地址
+//       表中无匹配时继续匹配后续规则, 均未命中时按目的地址查找
+#define POLICY_MAX_RULES 64
+
+enum {
+    POLICY_NH    = 0,
+    POLICY_GROUP = 1,
+    POLICY_TABLE = 2,
+};
+
+enum {
+    POLICY_F_DSCP = 1,
+};
+
+//src     网络字节序, 掩码为0表示任意
+//dport   主机字节序闭区间, 非TCP/UDP报文端口视为0, 端口未知时指定端口的规则不命中
+//ifindex 入接口 0表示任意
+//proto   IP协议号 0表示任意
+//dscp    flags含POLICY_F_DSCP时匹配
+//target  POLICY_NH按oif/smac/dmac转发, POLICY_GROUP/POLICY_TABLE时id为组ID或表ID
+struct policy_rule {
+    __u32         src;
+    __u32         smask;
+    __u16         dport_lo;
+    __u16         dport_hi;
+    __u32         ifindex;
+    __u8          proto;
+    __u8          dscp;
+    __u8          flags;
+    __u8          target;
+    __u32         prio;
+    __u32         id;
+    __u32         oif;
+    unsigned char smac[ETH_ALEN];
+    unsigned char dmac[ETH_ALEN];
+};
+
+struct policy_key {
+    __u32         prefixlen;
+    __u32         table;
+    __u32         addr;
+};
+
+struct {
+   __uint(type, BPF_MAP_TYPE_ARRAY);
+   __type(key,          __u32);
+   __type(value,        struct policy_rule);
+   __uint(max_entries,  POLICY_MAX_RULES * 2);
+} ofwd SEC(".maps");
+
+struct {
+   __uint(type, BPF_MAP_TYPE_ARRAY);
+   __type(key,          __u32);
+   __type(value,        __u32);
+   __uint(max_entries,  1);
+} ocfg SEC(".maps");
+
+struct {
+   __uint(type, BPF_MAP_TYPE_LPM_TRIE);
+   __type(key,          struct policy_key);
+   __type(value,        struct fwd);
+   __uint(max_entries,  10000);
+   __uint(map_flags,    BPF_F_NO_PREALLOC);
+} vfwd SEC(".maps");
+
 static __inline void  ipv4_decrease_ttl(struct iphdr *iph)
 {
 	__u32 check  = (__u32)iph->check;
@@ -67,6 +504,426 @@ static __inline void  ipv4_decrease_ttl(struct iphdr *iph)
 	--iph->ttl;
 }
 
+//IPv6无校验和, 仅递减跳数
+static __inline void  ipv6_decrease_hop_limit(struct ipv6hdr *ip6h)
+{
+	--ip6h->hop_limit;
+}
+
+static __inline void stat_inc(__u32 idx) {
+    __u64 *v = (__u64 *)bpf_map_lookup_elem(&sfwd, &idx);
+    if (v) {
+        *v += 1;
+    }
+}
+
+static __inline void cnt_add(void *map, void *key, __u64 bytes) {
+    struct cnt *v = (struct cnt *)bpf_map_lookup_elem(map, key);
+    if (v) {
+        v->pkts  += 1;
+        v->bytes += bytes;
+        return;
+    }
+    struct cnt init = {
+        .pkts  = 1,
+        .bytes = bytes,
+    };
+    bpf_map_update_elem(map, key, &init, BPF_NOEXIST);
+}
+
+//按表项前缀长度还原表项key
+static __inline void fwd_key(struct lpm_key *key, struct fwd *elem, struct iphdr *iph) {
+    __u32 plen = elem->plen;
+
+    if (plen > 32) {
+        plen = 32;
+    }
+    key->prefixlen = plen;
+    key->addr      = plen == 0 ? 0 : iph->daddr & bpf_htonl(~((1ULL << (32 - plen)) - 1));
+}
+
+static __inline void fwd_key6(struct lpm_key6 *key, struct fwd *elem, struct ipv6hdr *ip6h) {
+    __u32 plen = elem->plen;
+    __u32 bits;
+    int   i;
+
+    if (plen > 128) {
+        plen = 128;
+    }
+    key->prefixlen = plen;
+    #pragma unroll
+    for (i = 0; i < 4; i++) {
+        bits = plen > (__u32)i * 32 ? plen - (__u32)i * 32 : 0;
+        if (bits >= 32) {
+            key->addr.in6_u.u6_addr32[i] = ip6h->daddr.in6_u.u6_addr32[i];
+        } else if (bits == 0) {
+            key->addr.in6_u.u6_addr32[i] = 0;
+        } else {
+            key->addr.in6_u.u6_addr32[i] = ip6h->daddr.in6_u.u6_addr32[i] & bpf_htonl(~((1U << (32 - bits)) - 1));
+        }
+    }
+}
+
+static __inline void fwd_cnt(struct fwd *elem, struct iphdr *iph, __u64 bytes) {
+    struct lpm_key key;
+
+    fwd_key(&key, elem, iph);
+    cnt_add(&cfwd, &key, bytes);
+    if (elem->act == FWD_REDIRECT) {
+        cnt_add(&ifwd, &elem->ifindex, bytes);
+    }
+}
+
+static __inline void fwd_cnt6(struct fwd *elem, struct ipv6hdr *ip6h, __u64 bytes) {
+    struct lpm_key6 key;
+
+    fwd_key6(&key, elem, ip6h);
+    cnt_add(&cfwd6, &key, bytes);
+    if (elem->act == FWD_REDIRECT) {
+        cnt_add(&ifwd, &elem->ifindex, bytes);
+    }
+}
+
+//补充令牌, 超过桶深时截断
+//桶深上限2^33, max及elapsed*rate均小于2^63, 饱和相加不溢出
+static __inline __u64 tb_fill(__u64 tokens, __u64 rate, __u64 burst, __u64 elapsed) {
+    __u64 max = burst * NSEC;
+
+    if (tokens >= max || elapsed >= max / rate) {
+        return max;
+    }
+    __u64 add = elapsed * rate;
+    return add >= max - tokens ? max : tokens + add;
+}
+
+//令牌桶, 未超限返回0x0, 超限返回0x01并计入超限计数
+//pps与rate均配置时两者均需满足
+static __inline __u8 police(struct policer *p, __u64 bytes) {
+    __u64 now = bpf_ktime_get_ns();
+    __u8  ret = 0x0;
+
+    bpf_spin_lock(&p->lock);
+    __u64 elapsed = now > p->last ? now - p->last : 0;
+    __u64 pkts    = p->tok_pkts;
+    __u64 octets  = p->tok_bytes;
+
+    if (p->pps) {
+        pkts = tb_fill(pkts, p->pps, p->burst_pkts, elapsed);
+    }
+    if (p->rate) {
+        octets = tb_fill(octets, p->rate, p->burst_bytes, elapsed);
+    }
+    if (now > p->last) {
+        p->last = now;
+    }
+    if ((p->pps && pkts < NSEC) || (p->rate && octets < bytes * NSEC)) {
+        p->tok_pkts  = pkts;
+        p->tok_bytes = octets;
+        ret = 0x01;
+    } else {
+        p->tok_pkts  = p->pps ? pkts - NSEC : 0;
+        p->tok_bytes = p->rate ? octets - bytes * NSEC : 0;
+    }
+    bpf_spin_unlock(&p->lock);
+
+    if (ret) {
+        __sync_fetch_and_add(&p->exceed_pkts, 1);
+        __sync_fetch_and_add(&p->exceed_bytes, bytes);
+    }
+    return ret;
+}
+
+//表项未配置限速或令牌桶不存在时放行, 超限返回XDP动作
+static __inline int fwd_police(struct fwd *elem, struct iphdr *iph, __u64 bytes) {
+    struct lpm_key key;
+
+    if (!elem->pol) {
+        return -1;
+    }
+    fwd_key(&key, elem, iph);
+    struct policer *p = (struct policer *)bpf_map_lookup_elem(&rfwd, &key);
+    if (!p || !police(p, bytes)) {
+        return -1;
+    }
+    return p->exceed == POL_PASS ? XDP_PASS : XDP_DROP;
+}
+
+static __inline int fwd_police6(struct fwd *elem, struct ipv6hdr *ip6h, __u64 bytes) {
+    struct lpm_key6 key;
+
+    if (!elem->pol) {
+        return -1;
+    }
+    fwd_key6(&key, elem, ip6h);
+    struct policer *p = (struct policer *)bpf_map_lookup_elem(&rfwd6, &key);
+    if (!p || !police(p, bytes)) {
+        return -1;
+    }
+    return p->exceed == POL_PASS ? XDP_PASS : XDP_DROP;
+}
+
+//murmur3 finalizer 打散五元组
+static __inline __u32 hash_mix(__u32 h) {
+    h ^= h >> 16;
+    h *= 0x85ebca6b;
+    h ^= h >> 13;
+    h *= 0xc2b2ae35;
+    h ^= h >> 16;
+    return h;
+}
+
+//五元组哈希, 同一条流始终选择同一下一跳
+static __inline __u32 flow_hash(struct iphdr *iph, void *data_end) {
+    __u32 ports = 0;
+
+    if (iph->ihl == 5 && (iph->protocol == IPPROTO_TCP || iph->protocol == IPPROTO_UDP)) {
+        __u32 *l4 = (__u32 *)(iph + 1);
+        if ((void *)(l4 + 1) <= data_end) {
+            ports = *l4;
+        }
+    }
+    return hash_mix(iph->saddr ^ iph->daddr ^ ports ^ iph->protocol);
+}
+
+static __inline __u32 flow_hash6(struct ipv6hdr *ip6h, void *data_end) {
+    __u32 ports = 0;
+    __u32 h     = 0;
+
+    if (ip6h->nexthdr == IPPROTO_TCP || ip6h->nexthdr == IPPROTO_UDP) {
+        __u32 *l4 = (__u32 *)(ip6h + 1);
+        if ((void *)(l4 + 1) <= data_end) {
+            ports = *l4;
+        }
+    }
+    h ^= ip6h->saddr.in6_u.u6_addr32[0] ^ ip6h->daddr.in6_u.u6_addr32[0];
+    h ^= ip6h->saddr.in6_u.u6_addr32[1] ^ ip6h->daddr.in6_u.u6_addr32[1];
+    h ^= ip6h->saddr.in6_u.u6_addr32[2] ^ ip6h->daddr.in6_u.u6_addr32[2];
+    h ^= ip6h->saddr.in6_u.u6_addr32[3] ^ ip6h->daddr.in6_u.u6_addr32[3];
+    return hash_mix(h ^ ports ^ ip6h->nexthdr);
+}
+
+//表项指向下一跳组时, 按哈希选择组内下一跳
+static __inline __u8 select_nh(struct fwd *elem, __u32 hash) {
+    if (!elem->gid) {
+        return 0x0;
+    }
+    struct group* grp = (struct group *)bpf_map_lookup_elem(&gfwd, &elem->gid);
+    if (!grp) {
+        return 0x01;
+    }
+    __u32 count = grp->count;
+    if (count == 0 || count > GROUP_MAX_HOPS) {
+        return 0x01;
+    }
+    __u32 idx = hash % count;
+    if (idx >= GROUP_MAX_HOPS) {
+        return 0x01;
+    }
+    elem->ifindex = grp->nh[idx].ifindex;
+    memcpy(elem->dmac, grp->nh[idx].dmac, ETH_ALEN);
+    memcpy(elem->smac, grp->nh[idx].smac, ETH_ALEN);
+
+    return 0x0;
+}
+
+//按ihl定位L4头取TCP/UDP端口, 非TCP/UDP报文端口为0
+//分片(含首片)或L4头不完整时端口未知, 返回0x01
+static __inline __u8 l4_ports(struct iphdr *iph, void *data_end, __u16 *sport, __u16 *dport) {
+    *sport = 0;
+    *dport = 0;
+    if (iph->protocol != IPPROTO_TCP && iph->protocol != IPPROTO_UDP) {
+        return 0x0;
+    }
+    if (iph->frag_off & bpf_htons(0x3fff)) {
+        return 0x01;
+    }
+    __u32 hl = (iph->ihl & 0x0f) * 4;
+    if (hl < sizeof(*iph)) {
+        return 0x01;
+    }
+    __u16 *l4 = (__u16 *)((void *)iph + hl);
+    if ((void *)(l4 + 2) > data_end) {
+        return 0x01;
+    }
+    *sport = bpf_ntohs(l4[0]);
+    *dport = bpf_ntohs(l4[1]);
+    return 0x0;
+}
+
+static __inline void src_cnt(struct in6_addr *src) {
+    __u32  zero = 0;
+    __u32 *cfg  = (__u32 *)bpf_map_lookup_elem(&pcfg, &zero);
+    if (!cfg || !*cfg) {
+        return;
+    }
+    __u64 *v = (__u64 *)bpf_map_lookup_elem(&pfwd, src);
+    if (v) {
+        *v += 1;
+        return;
+    }
+    __u64 init = 1;
+    bpf_map_update_elem(&pfwd, src, &init, BPF_NOEXIST);
+}
+
+//命中黑名单返回0x01, 未命中时累加源地址计数
+static __inline __u8 src_check(struct iphdr *iph) {
+    struct lpm_key  key;
+    struct in6_addr src;
+
+    key.prefixlen = 32;
+    key.addr      = iph->saddr;
+    if (bpf_map_lookup_elem(&bfwd, &key)) {
+        return 0x01;
+    }
+    __builtin_memset(&src, 0, sizeof(src));
+    src.in6_u.u6_addr32[2] = bpf_htonl(0xffff);
+    src.in6_u.u6_addr32[3] = iph->saddr;
+    src_cnt(&src);
+    return 0x0;
+}
+
+static __inline __u8 src_check6(struct ipv6hdr *ip6h) {
+    struct lpm_key6 key;
+
+    key.prefixlen = 128;
+    memcpy(&key.addr, &ip6h->saddr, sizeof(key.addr));
+    if (bpf_map_lookup_elem(&bfwd6, &key)) {
+        return 0x01;
+    }
+    src_cnt(&ip6h->saddr);
+    return 0x0;
+}
+
+static __inline __u8 acl_check(struct xdp_md *ctx, struct iphdr *iph, void *data_end, __u64 bytes) {
+    __u32 zero = 0;
+    __u32 *cfg = (__u32 *)bpf_map_lookup_elem(&acfg, &zero);
+    if (!cfg) {
+        return ACL_ALLOW;
+    }
+    __u32 count = *cfg & 0xffff;
+    __u32 base  = (*cfg >> 16) ? ACL_MAX_RULES : 0;
+    __u16 sport = 0;
+    __u16 dport = 0;
+    __u8  unknown = l4_ports(iph, data_end, &sport, &dport);
+
+    for (__u32 i = 0; i < ACL_MAX_RULES; i++) {
+        if (i >= count) {
+            break;
+        }
+        __u32 slot = base + i;
+        struct acl_rule *r = (struct acl_rule *)bpf_map_lookup_elem(&afwd, &slot);
+        if (!r) {
+            break;
+        }
+        if ((iph->saddr & r->smask) != r->src || (iph->daddr & r->dmask) != r->dst) {
+            continue;
+        }
+        if (r->proto && r->proto != iph->protocol) {
+            continue;
+        }
+        if (r->ifindex && r->ifindex != ctx->ingress_ifindex) {
+            continue;
+        }
+        if (unknown && (r->sport_lo || r->sport_hi != 0xffff || r->dport_lo || r->dport_hi != 0xffff)) {
+            if (r->action != ACL_DENY) {
+                continue;
+            }
+        } else if (sport < r->sport_lo || sport > r->sport_hi || dport < r->dport_lo || dport > r->dport_hi) {
+            continue;
+        }
+        cnt_add(&acnt, &slot, bytes);
+        return r->action;
+    }
+    return ACL_ALLOW;
+}
+
+//策略表不回写hfwd, 表项不计数不限速
+static __inline __u8 policy_table(struct fwd *elem, __u32 table, struct iphdr *iph) {
+    struct policy_key key;
+
+    key.prefixlen = 64;
+    key.table     = table;
+    key.addr      = iph->daddr;
+
+    struct fwd* item = (struct fwd *)bpf_map_lookup_elem(&vfwd, &key);
+    if (!item) {
+        return 0x01;
+    }
+    elem->ifindex = item->ifindex;
+    memcpy(elem->dmac, item->dmac, ETH_ALEN);
+    memcpy(elem->smac, item->smac, ETH_ALEN);
+    elem->gid     = item->gid;
+    elem->plen    = item->plen;
+    elem->act     = item->act;
+    elem->pol     = 0;
+    elem->vin     = item->vin;
+    elem->vout    = item->vout;
+    elem->tid     = item->tid;
+
+    return 0x0;
+}
+
+static __inline __u8 policy_fwd(struct fwd *elem, struct xdp_md *ctx, struct iphdr *iph, void *data_end) {
+    __u32 zero = 0;
+    __u32 *cfg = (__u32 *)bpf_map_lookup_elem(&ocfg, &zero);
+    if (!cfg) {
+        return 0x01;
+    }
+    __u32 count = *cfg & 0xffff;
+    __u32 base  = (*cfg >> 16) ? POLICY_MAX_RULES : 0;
+    __u8  dscp  = iph->tos >> 2;
+    __u16 sport = 0;
+    __u16 dport = 0;
+    __u8  unknown = l4_ports(iph, data_end, &sport, &dport);
+
+    for (__u32 i = 0; i < POLICY_MAX_RULES; i++) {
+        if (i >= count) {
+            break;
+        }
+        __u32 slot = base + i;
+        struct policy_rule *r = (struct policy_rule *)bpf_map_lookup_elem(&ofwd, &slot);
+        if (!r) {
+            break;
+        }
+        if ((iph->saddr & r->smask) != r->src) {
+            continue;
+        }
+        if (r->proto && r->proto != iph->protocol) {
+            continue;
+        }
+        if ((r->flags & POLICY_F_DSCP) && r->dscp != dscp) {
+            continue;
+        }
+        if (r->ifindex && r->ifindex != ctx->ingress_ifindex) {
+            continue;
+        }
+        if (unknown && (r->dport_lo || r->dport_hi != 0xffff)) {
+            continue;
+        }
+        if (dport < r->dport_lo || dport > r->dport_hi) {
+            continue;
+        }
+        switch (r->target) {
+        case POLICY_TABLE:
+            if (!policy_table(elem, r->id, iph)) {
+                return 0x0;
+            }
+            continue;
+        case POLICY_GROUP:
+            elem->gid     = r->id;
+            elem->plen    = 32;
+            return 0x0;
+        default:
+            elem->ifindex = r->oif;
+            memcpy(elem->dmac, r->dmac, ETH_ALEN);
+            memcpy(elem->smac, r->smac, ETH_ALEN);
+            elem->plen    = 32;
+            return 0x0;
+        }
+    }
+    return 0x01;
+}
+
 static __inline __u8 fast_fwd(struct fwd *elem, struct iphdr* iph) {
     struct fwd* item = (struct fwd *)bpf_map_lookup_elem(&hfwd, &iph->daddr);
     if (!item) {
@@ -75,10 +932,39 @@ static __inline __u8 fast_fwd(struct fwd *elem, struct iphdr* iph) {
     elem->ifindex = item->ifindex;
     memcpy(elem->dmac, item->dmac, ETH_ALEN);
     memcpy(elem->smac, item->smac, ETH_ALEN);
+    elem->gid     = item->gid;
+    elem->plen    = item->plen;
+    elem->act     = item->act;
+    elem->pol     = item->pol;
+    elem->vin     = item->vin;
+    elem->vout    = item->vout;
+    elem->tid     = item->tid;
 
-    ipv4_decrease_ttl(iph);
+    return 0x0;
+}
+
+//前缀路由不回写hfwd, 保证前缀变更立即生效
+static __inline __u8 lpm_fwd(struct fwd *elem, struct iphdr* iph) {
+    struct lpm_key key;
+
+    key.prefixlen = 32;
+    key.addr      = iph->daddr;
+
+    struct fwd* item = (struct fwd *)bpf_map_lookup_elem(&lfwd, &key);
+    if (!item) {
+        return 0x01;
+    }
+    elem->ifindex = item->ifindex;
+    memcpy(elem->dmac, item->dmac, ETH_ALEN);
+    memcpy(elem->smac, item->smac, ETH_ALEN);
+    elem->gid     = item->gid;
+    elem->plen    = item->plen;
+    elem->act     = item->act;
+    elem->pol     = item->pol;
+    elem->vin     = item->vin;
+    elem->vout    = item->vout;
+    elem->tid     = item->tid;
 
-    bpf_printk("fast fwd dstIp=%x",iph->daddr); 
     return 0x0;
 }
 
@@ -101,74 +987,640 @@ static __inline __u8 slow_fwd(struct fwd *elem, struct xdp_md *ctx, struct iphdr
 
     rc = bpf_fib_lookup(ctx, &fib_params, sizeof(fib_params), 0); 
     if (rc != BPF_FIB_LKUP_RET_SUCCESS) {
-        bpf_printk("slow fwd fib lookup fail. rc=%x dst_ip=%x", rc, iph->daddr); 
         return 0x01;
     }
 
     elem->ifindex  = fib_params.ifindex;
+    elem->gid      = 0;
+    elem->plen     = 32;
+    elem->orig     = FWD_LEARNED;
     memcpy(elem->dmac, fib_params.dmac, ETH_ALEN);
 	memcpy(elem->smac, fib_params.smac, ETH_ALEN);
 
     bpf_map_update_elem(&hfwd, &iph->daddr, elem, BPF_ANY);
-    ipv4_decrease_ttl(iph);
+    return 0x0;
+}
+
+static __inline __u8 fast_fwd6(struct fwd *elem, struct ipv6hdr* ip6h) {
+    struct fwd* item = (struct fwd *)bpf_map_lookup_elem(&hfwd6, &ip6h->daddr);
+    if (!item) {
+        return 0x01;
+    }
+    elem->ifindex = item->ifindex;
+    memcpy(elem->dmac, item->dmac, ETH_ALEN);
+    memcpy(elem->smac, item->smac, ETH_ALEN);
+    elem->gid     = item->gid;
+    elem->plen    = item->plen;
+    elem->act     = item->act;
+    elem->pol     = item->pol;
+    elem->vin     = item->vin;
+    elem->vout    = item->vout;
+    elem->tid     = item->tid;
 
     return 0x0;
 }
 
-//refer https://github.com/torvalds/linux/blob/master/samples/bpf/xdp_fwd_kern.c
-SEC("xdp_fwd")
-int xpd_handle_fwd(struct xdp_md *ctx) {
+static __inline __u8 lpm_fwd6(struct fwd *elem, struct ipv6hdr* ip6h) {
+    struct lpm_key6 key;
+
+    key.prefixlen = 128;
+    memcpy(&key.addr, &ip6h->daddr, sizeof(key.addr));
+
+    struct fwd* item = (struct fwd *)bpf_map_lookup_elem(&lfwd6, &key);
+    if (!item) {
+        return 0x01;
+    }
+    elem->ifindex = item->ifindex;
+    memcpy(elem->dmac, item->dmac, ETH_ALEN);
+    memcpy(elem->smac, item->smac, ETH_ALEN);
+    elem->gid     = item->gid;
+    elem->plen    = item->plen;
+    elem->act     = item->act;
+    elem->pol     = item->pol;
+    elem->vin     = item->vin;
+    elem->vout    = item->vout;
+    elem->tid     = item->tid;
+
+    return 0x0;
+}
+
+static __inline __u8 slow_fwd6(struct fwd *elem, struct xdp_md *ctx, struct ipv6hdr* ip6h) {
+	struct bpf_fib_lookup fib_params;
+
+    __builtin_memset(&fib_params, 0, sizeof(fib_params));
+
+    fib_params.family	    = AF_INET6;
+	fib_params.flowinfo	    = *(__be32 *)ip6h & bpf_htonl(0x0FFFFFFF);
+	fib_params.l4_protocol	= ip6h->nexthdr;
+	fib_params.sport	    = 0;
+	fib_params.dport	    = 0;
+	fib_params.tot_len	    = bpf_ntohs(ip6h->payload_len);
+    memcpy(fib_params.ipv6_src, &ip6h->saddr, sizeof(ip6h->saddr));
+    memcpy(fib_params.ipv6_dst, &ip6h->daddr, sizeof(ip6h->daddr));
+    fib_params.ifindex      = ctx->ingress_ifindex;
+
+    __u64 rc;
+
+    rc = bpf_fib_lookup(ctx, &fib_params, sizeof(fib_params), 0); 
+    if (rc != BPF_FIB_LKUP_RET_SUCCESS) {
+        return 0x01;
+    }
+
+    elem->ifindex  = fib_params.ifindex;
+    elem->gid      = 0;
+    elem->plen     = 128;
+    elem->orig     = FWD_LEARNED;
+    memcpy(elem->dmac, fib_params.dmac, ETH_ALEN);
+	memcpy(elem->smac, fib_params.smac, ETH_ALEN);
+
+    bpf_map_update_elem(&hfwd6, &ip6h->daddr, elem, BPF_ANY);
+    return 0x0;
+}
+
+//按出VLAN改写L2后重定向, 调整报文头后重新校验边界
+// vid == vout  标签不变
+// vid == 0     push
+// vout == 0    pop
+// 其他         rewrite, 保留PCP/DEI
+static __inline int vlan_xmit(struct xdp_md *ctx, struct fwd *elem, __u16 vid) {
+    int delta = 0;
+
+    if (vid != elem->vout && !vid) {
+        delta = -(int)sizeof(struct vlan_hdr);
+    } else if (vid != elem->vout && !elem->vout) {
+        delta = (int)sizeof(struct vlan_hdr);
+    }
+    if (delta && bpf_xdp_adjust_head(ctx, delta)) {
+        stat_inc(STAT_DROP);
+        return XDP_DROP;
+    }
+
     void *data_end = (void *)(long)ctx->data_end;
 	void *data = (void *)(long)ctx->data;
 
-    //1. 解析L2
-    struct fwd            elem;
-	struct ethhdr         *eth = data;  //L2
-	struct iphdr          *iph;         //L3
+    struct ethhdr   *eth = data;
+    struct vlan_hdr *vh  = (struct vlan_hdr *)(eth + 1);
 
-    __u64 nh_off;
-    __u16 h_proto;
+    if ((void *)(eth + 1) > data_end) {
+        stat_inc(STAT_DROP);
+        return XDP_DROP;
+    }
+    //push时内层协议恰好位于原h_proto, pop时原内层协议恰好位于新h_proto
+    if (elem->vout) {
+        if ((void *)(vh + 1) > data_end) {
+            stat_inc(STAT_DROP);
+            return XDP_DROP;
+        }
+        __be16 pcp = vid ? vh->h_vlan_TCI & bpf_htons(0xf000) : 0;
+        eth->h_proto   = bpf_htons(ETH_P_8021Q);
+        vh->h_vlan_TCI = pcp | bpf_htons(elem->vout & VLAN_VID_MASK);
+    }
+    memcpy(eth->h_dest, elem->dmac, ETH_ALEN);
+    memcpy(eth->h_source, elem->smac, ETH_ALEN);
+    return bpf_redirect(elem->ifindex, 0);
+}
 
-    nh_off = (char*)(eth + 1) - (char*)eth;
-    if (data + nh_off > data_end) {
+static __inline __u16 ip_csum(struct iphdr *iph) {
+    __u16 *p   = (__u16 *)iph;
+    __u32 sum  = 0;
+    int   i;
+
+    #pragma unroll
+    for (i = 0; i < (int)sizeof(*iph) / 2; i++) {
+        sum += p[i];
+    }
+    sum = (sum & 0xffff) + (sum >> 16);
+    sum = (sum & 0xffff) + (sum >> 16);
+    return ~sum;
+}
+
+//在内层L3前写入外层以太头及外层头后重定向, 原L2(含VLAN标签)被覆盖
+//proto 内层L3协议(网络字节序)
+static __inline int tunnel_xmit(struct xdp_md *ctx, struct tunnel *tun, struct fwd *elem, __u64 nh_off, __be16 proto, __u32 hash) {
+    void *data_end = (void *)(long)ctx->data_end;
+	void *data = (void *)(long)ctx->data;
+
+    __u64 inner = data_end - data - nh_off;
+    __u32 olen  = sizeof(struct iphdr);
+    __u8  ipproto;
+
+    switch (tun->type) {
+    case TUN_IPIP:
+        ipproto = proto == bpf_htons(ETH_P_IP) ? IPPROTO_IPIP : IPPROTO_IPV6;
+        break;
+    case TUN_GRE:
+        ipproto = IPPROTO_GRE;
+        olen   += sizeof(struct gre_hdr) + (tun->flags & TUN_F_KEY ? sizeof(__be32) : 0);
+        break;
+    case TUN_VXLAN:
+        ipproto = IPPROTO_UDP;
+        olen   += sizeof(struct udphdr) + sizeof(struct vxlan_hdr) + sizeof(struct ethhdr);
+        break;
+    default:
+        return XDP_PASS;
+    }
+    if (bpf_xdp_adjust_head(ctx, (int)nh_off - (int)(sizeof(struct ethhdr) + olen))) {
+        stat_inc(STAT_DROP);
         return XDP_DROP;
     }
+    data_end = (void *)(long)ctx->data_end;
+    data     = (void *)(long)ctx->data;
 
-    h_proto = eth->h_proto;  //L3 协议类型
+    struct ethhdr *eth = data;
+    struct iphdr  *iph = (struct iphdr *)(eth + 1);
 
+    if ((void *)(iph + 1) > data_end) {
+        stat_inc(STAT_DROP);
+        return XDP_DROP;
+    }
+    memcpy(eth->h_dest, tun->dmac, ETH_ALEN);
+    memcpy(eth->h_source, tun->smac, ETH_ALEN);
+    eth->h_proto = bpf_htons(ETH_P_IP);
 
-    switch (h_proto) {
-    case bpf_htons(ETH_P_IP):
+    __builtin_memset(iph, 0, sizeof(*iph));
+    iph->version  = 4;
+    iph->ihl      = 5;
+    iph->ttl      = 64;
+    iph->protocol = ipproto;
+    iph->tot_len  = bpf_htons(olen + inner);
+    iph->saddr    = tun->saddr;
+    iph->daddr    = tun->daddr;
+    iph->check    = ip_csum(iph);
+
+    if (tun->type == TUN_GRE) {
+        struct gre_hdr *gre = (struct gre_hdr *)(iph + 1);
+        __be32         *key = (__be32 *)(gre + 1);
+
+        if ((void *)(key + 1) > data_end) {
+            stat_inc(STAT_DROP);
+            return XDP_DROP;
+        }
+        gre->flags    = 0;
+        gre->protocol = proto;
+        if (tun->flags & TUN_F_KEY) {
+            gre->flags = bpf_htons(GRE_KEY);
+            *key       = bpf_htonl(tun->key);
+        }
+    }
+    if (tun->type == TUN_VXLAN) {
+        struct udphdr    *udp  = (struct udphdr *)(iph + 1);
+        struct vxlan_hdr *vx   = (struct vxlan_hdr *)(udp + 1);
+        struct ethhdr    *ieth = (struct ethhdr *)(vx + 1);
+
+        if ((void *)(ieth + 1) > data_end) {
+            stat_inc(STAT_DROP);
+            return XDP_DROP;
+        }
+        //源端口取流哈希, 便于底层网络ECMP
+        udp->source = bpf_htons(49152 | (hash & 0x3fff));
+        udp->dest   = bpf_htons(tun->port ? tun->port : VXLAN_PORT);
+        udp->len    = bpf_htons(olen - sizeof(struct iphdr) + inner);
+        udp->check  = 0;
+        vx->flags   = bpf_htonl(VXLAN_FLAG_I);
+        vx->vni     = bpf_htonl(tun->key << 8);
+        memcpy(ieth->h_dest, elem->dmac, ETH_ALEN);
+        memcpy(ieth->h_source, elem->smac, ETH_ALEN);
+        ieth->h_proto = proto;
+    }
+    stat_inc(STAT_ENCAP);
+    return bpf_redirect(tun->ifindex, 0);
+}
+
+//报文目的为本端隧道端点且命中dfwd时剥离外层
+//IPIP/GRE补齐以太头(沿用外层MAC, 转发时改写), VXLAN以内层以太帧替换
+//返回-1表示继续按当前报文转发, 否则为XDP动作
+static __inline int tunnel_decap(struct xdp_md *ctx) {
+    void *data_end = (void *)(long)ctx->data_end;
+	void *data = (void *)(long)ctx->data;
+
+    struct ethhdr    *eth = data;
+    struct iphdr     *iph;
+    struct decap_key key;
+    __u64  nh_off = sizeof(struct ethhdr);
+    __u32  hlen   = sizeof(struct iphdr);
+    __be16 h_proto;
+    __be16 proto  = 0;
+
+    if ((void *)(eth + 1) > data_end) {
+        return -1;
+    }
+    h_proto = eth->h_proto;
+    if (h_proto == bpf_htons(ETH_P_8021Q)) {
+        struct vlan_hdr *vh = (struct vlan_hdr *)(eth + 1);
+        if ((void *)(vh + 1) > data_end) {
+            return -1;
+        }
+        h_proto = vh->h_vlan_encapsulated_proto;
+        nh_off += sizeof(struct vlan_hdr);
+    }
+    if (h_proto != bpf_htons(ETH_P_IP)) {
+        return -1;
+    }
+    iph = data + nh_off;
+    if ((void *)(iph + 1) > data_end) {
+        return -1;
+    }
+    //外层带选项或分片交由内核重组
+    if (iph->ihl != 5 || (iph->frag_off & bpf_htons(0x3fff))) {
+        return -1;
+    }
+    __builtin_memset(&key, 0, sizeof(key));
+    key.daddr = iph->daddr;
+
+    switch (iph->protocol) {
+    case IPPROTO_IPIP:
+        key.type = TUN_IPIP;
+        proto    = bpf_htons(ETH_P_IP);
+        break;
+    case IPPROTO_IPV6:
+        key.type = TUN_IPIP;
+        proto    = bpf_htons(ETH_P_IPV6);
         break;
+    case IPPROTO_GRE: {
+        struct gre_hdr *gre = (struct gre_hdr *)(iph + 1);
+        if ((void *)(gre + 1) > data_end) {
+            return -1;
+        }
+        //仅支持携带key, 校验和/序号/版本非0交由内核
+        if (gre->flags & bpf_htons((__u16)~GRE_KEY)) {
+            return -1;
+        }
+        key.type = TUN_GRE;
+        proto    = gre->protocol;
+        hlen    += sizeof(struct gre_hdr);
+        if (gre->flags & bpf_htons(GRE_KEY)) {
+            __be32 *k = (__be32 *)(gre + 1);
+            if ((void *)(k + 1) > data_end) {
+                return -1;
+            }
+            key.key = bpf_ntohl(*k);
+            hlen   += sizeof(__be32);
+        }
+        break;
+    }
+    case IPPROTO_UDP: {
+        struct udphdr    *udp  = (struct udphdr *)(iph + 1);
+        struct vxlan_hdr *vx   = (struct vxlan_hdr *)(udp + 1);
+        struct ethhdr    *ieth = (struct ethhdr *)(vx + 1);
+        if ((void *)(ieth + 1) > data_end) {
+            return -1;
+        }
+        if (!(vx->flags & bpf_htonl(VXLAN_FLAG_I))) {
+            return -1;
+        }
+        key.type = TUN_VXLAN;
+        key.key  = bpf_ntohl(vx->vni) >> 8;
+        key.port = bpf_ntohs(udp->dest);
+        hlen    += sizeof(struct udphdr) + sizeof(struct vxlan_hdr);
+        break;
+    }
     default:
+        return -1;
+    }
+    if (key.type != TUN_VXLAN && proto != bpf_htons(ETH_P_IP) && proto != bpf_htons(ETH_P_IPV6)) {
+        return -1;
+    }
+    struct cnt *c = (struct cnt *)bpf_map_lookup_elem(&dfwd, &key);
+    if (!c) {
+        return -1;
+    }
+    __sync_fetch_and_add(&c->pkts, 1);
+    __sync_fetch_and_add(&c->bytes, data_end - data);
+
+    unsigned char dmac[ETH_ALEN];
+    unsigned char smac[ETH_ALEN];
+
+    memcpy(dmac, eth->h_dest, ETH_ALEN);
+    memcpy(smac, eth->h_source, ETH_ALEN);
+    if (key.type == TUN_VXLAN) {
+        nh_off += hlen;
+    } else {
+        nh_off += hlen - sizeof(struct ethhdr);
+    }
+    if (bpf_xdp_adjust_head(ctx, (int)nh_off)) {
+        stat_inc(STAT_DROP);
+        return XDP_DROP;
+    }
+    stat_inc(STAT_DECAP);
+    if (key.type == TUN_VXLAN) {
+        return -1;
+    }
+    data_end = (void *)(long)ctx->data_end;
+    data     = (void *)(long)ctx->data;
+    eth      = data;
+    if ((void *)(eth + 1) > data_end) {
+        stat_inc(STAT_DROP);
+        return XDP_DROP;
+    }
+    memcpy(eth->h_dest, dmac, ETH_ALEN);
+    memcpy(eth->h_source, smac, ETH_ALEN);
+    eth->h_proto = proto;
+    return -1;
+}
+
+static __inline int handle_ipv6(struct xdp_md *ctx, __u64 nh_off, __u16 vid) {
+    void *data_end = (void *)(long)ctx->data_end;
+	void *data = (void *)(long)ctx->data;
+
+    struct fwd            elem;
+	struct ipv6hdr        *ip6h;        //L3
+
+    ip6h = data + nh_off;
+    nh_off += (char*)(ip6h + 1) - (char*)ip6h;
+
+    if (data + nh_off > data_end) {
+        stat_inc(STAT_DROP);
+        return XDP_DROP;
+    }
+    //跳数耗尽交由内核回复ICMPv6
+    if (ip6h->hop_limit <= 1) {
         return XDP_PASS;
     }
 
-    //2. 解析L3
+    __builtin_memset(&elem, 0, sizeof(elem));
+
+    __u8 rc;
+    //1. 源地址黑名单
+    rc = src_check6(ip6h);
+    if (rc) {
+        stat_inc(STAT_BLOCKED);
+        return XDP_DROP;
+    }
+    //2. fast_fwd
+    rc = fast_fwd6(&elem, ip6h);
+    if (!rc) {
+        stat_inc(STAT_FAST);
+    }
+    //3. lpm_fwd
+    if (rc) {
+        rc = lpm_fwd6(&elem, ip6h);
+        if (!rc) {
+            stat_inc(STAT_LPM);
+        }
+    }
+    //4. slow_fwd, 带标签报文由内核经VLAN子接口转发, 不学习
+    if (rc && vid) {
+        stat_inc(STAT_VLAN);
+        return XDP_PASS;
+    }
+    if (rc) {
+        rc = slow_fwd6(&elem, ctx, ip6h);
+        stat_inc(rc ? STAT_MISS : STAT_SLOW);
+    }
+    if (rc) {
+        return XDP_PASS;
+    }
+    //5. 入VLAN
+    if (elem.vin != vid) {
+        stat_inc(STAT_VLAN);
+        return XDP_PASS;
+    }
+    //6. 表项动作, 黑洞路由计入表项计数
+    switch (elem.act) {
+    case FWD_DROP:
+        fwd_cnt6(&elem, ip6h, data_end - data);
+        stat_inc(STAT_BLACKHOLE);
+        return XDP_DROP;
+    case FWD_PASS:
+        stat_inc(STAT_PASS);
+        return XDP_PASS;
+    }
+    //7. 表项限速
+    int act = fwd_police6(&elem, ip6h, data_end - data);
+    if (act >= 0) {
+        stat_inc(STAT_POLICED);
+        return act;
+    }
+    //8. 隧道或下一跳组
+    struct tunnel *tun = NULL;
+    if (elem.tid) {
+        tun = (struct tunnel *)bpf_map_lookup_elem(&tfwd, &elem.tid);
+        if (!tun) {
+            return XDP_PASS;
+        }
+        elem.ifindex = tun->ifindex;
+    } else {
+        rc = select_nh(&elem, flow_hash6(ip6h, data_end));
+        if (rc) {
+            return XDP_PASS;
+        }
+    }
+
+    fwd_cnt6(&elem, ip6h, data_end - data);
+    ipv6_decrease_hop_limit(ip6h);
+    //9. 隧道封装或出VLAN
+    if (tun) {
+        return tunnel_xmit(ctx, tun, &elem, nh_off - sizeof(*ip6h), bpf_htons(ETH_P_IPV6), flow_hash6(ip6h, data_end));
+    }
+    return vlan_xmit(ctx, &elem, vid);
+}
+
+static __inline int handle_ipv4(struct xdp_md *ctx, __u64 nh_off, __u16 vid) {
+    void *data_end = (void *)(long)ctx->data_end;
+	void *data = (void *)(long)ctx->data;
+
+    struct fwd            elem;
+	struct iphdr          *iph;         //L3
+
     iph = data + nh_off;
     nh_off += (char*)(iph + 1) - (char*)iph;
 
     if (data + nh_off > data_end) {
+        stat_inc(STAT_DROP);
         return XDP_DROP;
     }
 
+    __builtin_memset(&elem, 0, sizeof(elem));
+
     __u8 rc;
-    //3. fast_fwd
-    rc = fast_fwd(&elem, iph);
-    if (!rc) {
-        memcpy(eth->h_dest, elem.dmac, ETH_ALEN);
-        memcpy(eth->h_source, elem.smac, ETH_ALEN);
-        return bpf_redirect(elem.ifindex, 0);
+    //2. 源地址黑名单
+    rc = src_check(iph);
+    if (rc) {
+        stat_inc(STAT_BLOCKED);
+        return XDP_DROP;
     }
-    //4. slow_fwd
-    rc = slow_fwd(&elem, ctx, iph);
+    //3. ACL
+    rc = acl_check(ctx, iph, data_end, data_end - data);
+    if (rc == ACL_DENY) {
+        stat_inc(STAT_ACL_DENY);
+        return XDP_DROP;
+    }
+    //4. 策略路由, 命中时跳过目的地址查找
+    __u8 pbr = 0;
+    rc = policy_fwd(&elem, ctx, iph, data_end);
     if (!rc) {
-        memcpy(eth->h_dest, elem.dmac, ETH_ALEN);
-        memcpy(eth->h_source, elem.smac, ETH_ALEN);
-        return bpf_redirect(elem.ifindex, 0);
+        stat_inc(STAT_POLICY);
+        pbr = 1;
+    }
+    //5. fast_fwd
+    if (rc) {
+        rc = fast_fwd(&elem, iph);
+        if (!rc) {
+            stat_inc(STAT_FAST);
+        }
+    }
+    //6. lpm_fwd
+    if (rc) {
+        rc = lpm_fwd(&elem, iph);
+        if (!rc) {
+            stat_inc(STAT_LPM);
+        }
+    }
+    //7. slow_fwd, 带标签报文由内核经VLAN子接口转发, 不学习
+    if (rc && vid) {
+        stat_inc(STAT_VLAN);
+        return XDP_PASS;
+    }
+    if (rc) {
+        rc = slow_fwd(&elem, ctx, iph);
+        stat_inc(rc ? STAT_MISS : STAT_SLOW);
+    }
+    if (rc) {
+        return XDP_PASS;
+    }
+    //8. 入VLAN
+    if (elem.vin != vid) {
+        stat_inc(STAT_VLAN);
+        return XDP_PASS;
+    }
+    //9. 表项动作, 黑洞路由计入表项计数, 策略路由不计表项计数
+    switch (elem.act) {
+    case FWD_DROP:
+        if (!pbr) {
+            fwd_cnt(&elem, iph, data_end - data);
+        }
+        stat_inc(STAT_BLACKHOLE);
+        return XDP_DROP;
+    case FWD_PASS:
+        stat_inc(STAT_PASS);
+        return XDP_PASS;
+    }
+    //10. 表项限速
+    int act = fwd_police(&elem, iph, data_end - data);
+    if (act >= 0) {
+        stat_inc(STAT_POLICED);
+        return act;
+    }
+    //11. 隧道或下一跳组
+    struct tunnel *tun = NULL;
+    if (elem.tid) {
+        tun = (struct tunnel *)bpf_map_lookup_elem(&tfwd, &elem.tid);
+        if (!tun) {
+            return XDP_PASS;
+        }
+        elem.ifindex = tun->ifindex;
+    } else {
+        rc = select_nh(&elem, flow_hash(iph, data_end));
+        if (rc) {
+            return XDP_PASS;
+        }
+    }
+
+    if (pbr) {
+        cnt_add(&ifwd, &elem.ifindex, data_end - data);
+    } else {
+        fwd_cnt(&elem, iph, data_end - data);
+    }
+    ipv4_decrease_ttl(iph);
+    //12. 隧道封装或出VLAN
+    if (tun) {
+        return tunnel_xmit(ctx, tun, &elem, nh_off - sizeof(*iph), bpf_htons(ETH_P_IP), flow_hash(iph, data_end));
+    }
+    return vlan_xmit(ctx, &elem, vid);
+}
+
+//refer https://github.com/torvalds/linux/blob/master/samples/bpf/xdp_fwd_kern.c
+SEC("xdp_fwd")
+int xpd_handle_fwd(struct xdp_md *ctx) {
+    //0. 隧道解封装, 之后按内层报文解析
+    int act = tunnel_decap(ctx);
+    if (act >= 0) {
+        return act;
     }
 
-    return XDP_PASS;
+    void *data_end = (void *)(long)ctx->data_end;
+	void *data = (void *)(long)ctx->data;
+
+    //1. 解析L2
+	struct ethhdr         *eth = data;  //L2
+
+    __u64 nh_off;
+    __u16 h_proto;
+    __u16 vid = 0;
+
+    nh_off = (char*)(eth + 1) - (char*)eth;
+    if (data + nh_off > data_end) {
+        stat_inc(STAT_DROP);
+        return XDP_DROP;
+    }
+
+    h_proto = eth->h_proto;  //L3 协议类型
+
+    //802.1Q标签, 记录VID后按内层协议解析
+    if (h_proto == bpf_htons(ETH_P_8021Q)) {
+        struct vlan_hdr *vh = data + nh_off;
+
+        nh_off += sizeof(struct vlan_hdr);
+        if (data + nh_off > data_end) {
+            stat_inc(STAT_DROP);
+            return XDP_DROP;
+        }
+        vid     = bpf_ntohs(vh->h_vlan_TCI) & VLAN_VID_MASK;
+        h_proto = vh->h_vlan_encapsulated_proto;
+        if (!vid) {
+            return XDP_PASS;
+        }
+    }
+
+    //2. 解析L3
+    switch (h_proto) {
+    case bpf_htons(ETH_P_IP):
+        return handle_ipv4(ctx, nh_off, vid);
+    case bpf_htons(ETH_P_IPV6):
+        return handle_ipv6(ctx, nh_off, vid);
+    default:
+        return XDP_PASS;
+    }
 }
 
 char _license []SEC("license") = "GPL";
@@ -23,6 +23,14 @@ func main() {
 		fmt.Println(err.Error())
 		return
 	}
+	//2. fwd snapshot save|restore [file]
+	if flag.NArg() > 0 {
+		err = command(cfg, flag.Args())
+		if err != nil {
+			fmt.Println(err.Error())
+		}
+		return
+	}
 	srv, err := fwd.NewSrv(cfg)
 	if err != nil {
 		fmt.Println(err.Error())
@@ -62,5 +70,73 @@ func checkCfg(cfg *fwd.SrvCfg) error {
 	if cfg.HttpCfg.Port <= 0 || cfg.HttpCfg.Port >= 65535 {
 		return errors.New("httpCfg.Port is invalid")
 	}
+	switch cfg.BpfCfg.Backend {
+	case "":
+		cfg.BpfCfg.Backend = "bpftool"
+	case "bpftool", "syscall":
+	default:
+		return errors.New("bpfCfg.Backend is invalid")
+	}
+	switch cfg.XdpCfg.Mode {
+	case "":
+		cfg.XdpCfg.Mode = "auto"
+	case "auto", "native", "generic":
+	case "offload":
+		if len(cfg.XdpCfg.Ifaces) > 1 {
+			return errors.New("xdpCfg.Ifaces offload mode only support one iface")
+		}
+	default:
+		return errors.New("xdpCfg.Mode is invalid")
+	}
+	if len(cfg.XdpCfg.Ifaces) > 0 && len(cfg.XdpCfg.Obj) <= 0 {
+		return errors.New("xdpCfg.Obj is invalid")
+	}
+	if cfg.GrpcCfg.Port < 0 || cfg.GrpcCfg.Port >= 65535 {
+		return errors.New("grpcCfg.Port is invalid")
+	}
+	if cfg.GrpcCfg.Port > 0 && len(cfg.GrpcCfg.Host) <= 0 {
+		cfg.GrpcCfg.Host = cfg.HttpCfg.Host
+	}
+	if cfg.GrpcCfg.Port > 0 && nil == net.ParseIP(cfg.GrpcCfg.Host) {
+		return errors.New("grpcCfg.Host is invalid")
+	}
+	if cfg.MetricsCfg.Port < 0 || cfg.MetricsCfg.Port >= 65535 {
+		return errors.New("metricsCfg.Port is invalid")
+	}
+	if cfg.MetricsCfg.Port > 0 && len(cfg.MetricsCfg.Host) <= 0 {
+		cfg.MetricsCfg.Host = cfg.HttpCfg.Host
+	}
+	if cfg.MetricsCfg.Port > 0 && nil == net.ParseIP(cfg.MetricsCfg.Host) {
+		return errors.New("metricsCfg.Host is invalid")
+	}
+	if cfg.MetricsCfg.UsageInterval < 0 {
+		return errors.New("metricsCfg.UsageInterval is invalid")
+	}
+	if cfg.SnapshotCfg.Interval < 0 {
+		return errors.New("snapshotCfg.Interval is invalid")
+	}
+	if (cfg.SnapshotCfg.Interval > 0 || cfg.SnapshotCfg.Restore) && len(cfg.SnapshotCfg.File) <= 0 {
+		return errors.New("snapshotCfg.File is invalid")
+	}
+	if cfg.WatchCfg.Interval < 0 {
+		return errors.New("watchCfg.Interval is invalid")
+	}
+	if cfg.RoutesCfg.Interval < 0 {
+		return errors.New("routesCfg.Interval is invalid")
+	}
+	if len(cfg.RoutesCfg.File) > 0 && cfg.RoutesCfg.Interval == 0 {
+		cfg.RoutesCfg.Interval = 30
+	}
 	return nil
 }
+
+func command(cfg *fwd.SrvCfg, args []string) error {
+	switch {
+	case args[0] == "snapshot" && len(args) == 2:
+		return fwd.Snapshot(cfg, args[1], "")
+	case args[0] == "snapshot" && len(args) == 3:
+		return fwd.Snapshot(cfg, args[1], args[2])
+	default:
+		return errors.New("usage: fwd [-c conf/fwd.json] snapshot save|restore [file]")
+	}
+}
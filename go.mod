@@ -5,7 +5,10 @@ go 1.16
 require (
 	github.com/advancevillage/3rd v0.0.8
 	github.com/stretchr/testify v1.7.0
+	golang.org/x/sys v0.0.0-20210510120138-977fb7262007
+	google.golang.org/grpc v1.43.0
 	google.golang.org/protobuf v1.26.0
+	gopkg.in/yaml.v2 v2.3.0
 )
 
 require (
@@ -26,7 +29,5 @@ require (
 	go.uber.org/atomic v1.7.0 // indirect
 	go.uber.org/multierr v1.6.0 // indirect
 	go.uber.org/zap v1.19.1 // indirect
-	golang.org/x/sys v0.0.0-20210510120138-977fb7262007 // indirect
-	gopkg.in/yaml.v2 v2.3.0 // indirect
 	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b // indirect
 )
@@ -0,0 +1,242 @@
+package fwd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/advancevillage/3rd/logx"
+	"github.com/advancevillage/fwd/pkg/fwd"
+	"github.com/advancevillage/fwd/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+//gRPC与HTTP共用处理逻辑, 响应中的业务码一致
+type grpcSrv struct {
+	proto.UnimplementedFwdServer
+	s *Srv
+}
+
+func newGrpcSrv(s *Srv) *grpc.Server {
+	var g = grpc.NewServer()
+	proto.RegisterFwdServer(g, &grpcSrv{s: s})
+	return g
+}
+
+func (g *grpcSrv) UpdateForward(ctx context.Context, req *proto.UpdateForwardRequest) (*proto.UpdateForwardResponse, error) {
+	var (
+		sctx     = context.WithValue(ctx, logx.TraceId, req.GetTraceId())
+		request  = &batchUpdateRequest{}
+		response = &batchResponse{}
+	)
+	for _, e := range req.GetEntries() {
+		request.Entries = append(request.Entries, &updateEntry{
+			Ip:        g.ip(e),
+			Iface:     e.GetIface(),
+			SrcMac:    e.GetSrcMac(),
+			DstMac:    e.GetDstMac(),
+			Group:     e.GetGroup(),
+			Gateway:   e.GetGateway(),
+			IfName:    e.GetIfName(),
+			Ttl:       int(e.GetTtl()),
+			FwdAction: e.GetAction(),
+			Policer:   g.policer(e.GetPolicer()),
+			VlanIn:    g.vlan(e.GetVlanIn()),
+			VlanOut:   g.vlan(e.GetVlanOut()),
+			Tunnel:    e.GetTunnel(),
+			Table:     e.GetTable(),
+		})
+	}
+	response.TraceId = req.GetTraceId()
+	response.Code = SrvOk
+	g.s.batchUpdateForward(sctx, response, request)
+	g.s.metrics.request("UpdateForward", response.Code)
+
+	return &proto.UpdateForwardResponse{Status: &response.ActionResponse, Results: response.Results}, nil
+}
+
+func (g *grpcSrv) DeleteForward(ctx context.Context, req *proto.DeleteForwardRequest) (*proto.DeleteForwardResponse, error) {
+	var (
+		sctx     = context.WithValue(ctx, logx.TraceId, req.GetTraceId())
+		response = &batchResponse{}
+	)
+	response.TraceId = req.GetTraceId()
+	response.Code = SrvOk
+	g.s.delete(sctx, response, req.GetTable(), req.GetIps())
+	g.s.metrics.request("DeleteForward", response.Code)
+
+	return &proto.DeleteForwardResponse{Status: &response.ActionResponse, Results: response.Results}, nil
+}
+
+func (g *grpcSrv) RenewForward(ctx context.Context, req *proto.RenewForwardRequest) (*proto.RenewForwardResponse, error) {
+	var (
+		sctx     = context.WithValue(ctx, logx.TraceId, req.GetTraceId())
+		request  = &renewRequest{Ips: req.GetIps(), Ttl: int(req.GetTtl())}
+		response = &batchResponse{}
+	)
+	response.TraceId = req.GetTraceId()
+	response.Code = SrvOk
+	g.s.renewForward(sctx, response, request)
+	g.s.metrics.request("RenewForward", response.Code)
+
+	return &proto.RenewForwardResponse{Status: &response.ActionResponse, Results: response.Results}, nil
+}
+
+func (g *grpcSrv) QueryForward(ctx context.Context, req *proto.QueryForwardRequest) (*proto.QueryForwardResponse, error) {
+	var (
+		sctx     = context.WithValue(ctx, logx.TraceId, req.GetTraceId())
+		response = &queryResponse{}
+	)
+	response.TraceId = req.GetTraceId()
+	response.Code = SrvOk
+	g.s.queryForward(sctx, response, &queryRequest{Origin: req.GetOrigin()})
+	g.s.metrics.request("QueryForward", response.Code)
+
+	var r = &proto.QueryForwardResponse{Status: &response.ActionResponse}
+	for _, e := range response.Tables {
+		r.Entries = append(r.Entries, g.entry(e))
+	}
+	return r, nil
+}
+
+func (g *grpcSrv) FlushLearned(ctx context.Context, req *proto.FlushLearnedRequest) (*proto.FlushLearnedResponse, error) {
+	var (
+		sctx     = context.WithValue(ctx, logx.TraceId, req.GetTraceId())
+		response = &flushResponse{}
+	)
+	response.TraceId = req.GetTraceId()
+	response.Code = SrvOk
+	g.s.flushLearned(sctx, response, &queryRequest{})
+	g.s.metrics.request("FlushLearned", response.Code)
+
+	return &proto.FlushLearnedResponse{Status: &response.ActionResponse, Ips: response.Ips}, nil
+}
+
+//seq为0时先推送全量表项及EVENT_SYNC, 之后持续推送变更事件
+//续订的seq已过期时返回OutOfRange, 需以seq为0重新订阅
+func (g *grpcSrv) WatchForward(req *proto.WatchForwardRequest, stream proto.Fwd_WatchForwardServer) error {
+	var (
+		ctx      = context.WithValue(stream.Context(), logx.TraceId, req.GetTraceId())
+		seq      = req.GetSeq()
+		snapshot = seq == 0
+	)
+	g.s.metrics.request("WatchForward", SrvOk)
+	defer g.s.watcher.subscribe()()
+
+	for {
+		var events, elems, cur, wait, err = g.s.watcher.since(seq, snapshot)
+		if err != nil {
+			g.s.logger.Warnw(ctx, "watch forward fail", "seq", seq, "err", err)
+			return status.Error(codes.OutOfRange, err.Error())
+		}
+		if snapshot {
+			for _, e := range elems {
+				err = stream.Send(&proto.FwdEvent{Type: proto.EventType_EVENT_ADD, Entry: g.entry(e), Seq: cur})
+				if err != nil {
+					return err
+				}
+			}
+			err = stream.Send(&proto.FwdEvent{Type: proto.EventType_EVENT_SYNC, Seq: cur})
+			if err != nil {
+				return err
+			}
+			snapshot = false
+		}
+		for _, ev := range events {
+			err = stream.Send(g.event(ev))
+			if err != nil {
+				return err
+			}
+		}
+		seq = cur
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-g.s.ctx.Done():
+			return status.Error(codes.Unavailable, "server exit")
+		case <-wait:
+		}
+	}
+}
+
+func (g *grpcSrv) ip(e *proto.FwdEntry) string {
+	if e.GetPrefix() <= 0 || strings.Contains(e.GetIp(), "/") {
+		return e.GetIp()
+	}
+	return fmt.Sprintf("%s/%d", e.GetIp(), e.GetPrefix())
+}
+
+func (g *grpcSrv) entry(e *fwd.FwdElem) *proto.FwdEntry {
+	return &proto.FwdEntry{
+		Ip:       e.Ip,
+		Prefix:   int32(e.Prefix),
+		Iface:    e.Iface,
+		IfName:   e.IfName,
+		SrcMac:   e.SrcMac,
+		DstMac:   e.DstMac,
+		Group:    e.Group,
+		Packets:  e.Packets,
+		Bytes:    e.Bytes,
+		Orphaned: e.Orphaned,
+		Deadline: e.Deadline,
+		Origin:   e.Origin,
+		Action:   e.Action,
+		Policer:  g.pol(e.Policer),
+		VlanIn:   uint32(e.VlanIn),
+		VlanOut:  uint32(e.VlanOut),
+		Tunnel:   e.Tunnel,
+		Table:    e.Table,
+	}
+}
+
+//超出uint16的VID按非法值下发, 由UptFwdBatch返回ErrFwdVlan
+func (g *grpcSrv) vlan(v uint32) uint16 {
+	if v > 0xffff {
+		return 0xffff
+	}
+	return uint16(v)
+}
+
+func (g *grpcSrv) policer(p *proto.Policer) *policerEntry {
+	if p == nil {
+		return nil
+	}
+	return &policerEntry{
+		Pps:        p.GetPps(),
+		Bps:        p.GetBps(),
+		BurstPkts:  p.GetBurstPkts(),
+		BurstBytes: p.GetBurstBytes(),
+		Exceed:     p.GetExceed(),
+	}
+}
+
+func (g *grpcSrv) pol(p fwd.Policer) *proto.Policer {
+	if p.Pps <= 0 && p.Bps <= 0 {
+		return nil
+	}
+	return &proto.Policer{
+		Pps:         p.Pps,
+		Bps:         p.Bps,
+		BurstPkts:   p.BurstPkts,
+		BurstBytes:  p.BurstBytes,
+		Exceed:      p.Exceed,
+		ExceedPkts:  p.ExceedPkts,
+		ExceedBytes: p.ExceedBytes,
+	}
+}
+
+func (g *grpcSrv) event(ev *fwdEvent) *proto.FwdEvent {
+	var r = &proto.FwdEvent{Entry: g.entry(ev.Entry), Seq: ev.Seq}
+	switch ev.Type {
+	case EventAdd:
+		r.Type = proto.EventType_EVENT_ADD
+	case EventUpdate:
+		r.Type = proto.EventType_EVENT_UPDATE
+	case EventDelete:
+		r.Type = proto.EventType_EVENT_DELETE
+	}
+	return r
+}
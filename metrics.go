@@ -0,0 +1,134 @@
+package fwd
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/advancevillage/3rd/netx"
+	"github.com/advancevillage/fwd/pkg/fwd"
+	"github.com/advancevillage/fwd/pkg/metrics"
+)
+
+var (
+	usageInterval = time.Minute
+)
+
+//守护进程及转发面指标, 通过 GET /metrics 以Prometheus文本格式导出
+type srvMetrics struct {
+	registry   metrics.IRegistry
+	requests   metrics.ICounter
+	latency    metrics.IHistogram
+	errors     metrics.ICounter
+	entries    metrics.IGauge
+	capacity   metrics.IGauge
+	xdp        metrics.ICounter
+	redirects  metrics.ICounter
+	redirectsB metrics.ICounter
+}
+
+func newSrvMetrics() *srvMetrics {
+	var r = metrics.NewRegistry()
+	return &srvMetrics{
+		registry:   r,
+		requests:   r.Counter("fwd_api_requests_total", "API requests by action and result code.", "action", "code"),
+		latency:    r.Histogram("fwd_bpf_op_duration_seconds", "Latency of BPF map operations by backend.", metrics.DefBuckets, "backend", "op"),
+		errors:     r.Counter("fwd_bpf_op_errors_total", "Failed BPF map operations by table.", "table", "op"),
+		entries:    r.Gauge("fwd_table_entries", "Current number of entries in a forwarding table.", "table"),
+		capacity:   r.Gauge("fwd_table_capacity", "Maximum number of entries in a forwarding table.", "table"),
+		xdp:        r.Counter("fwd_xdp_packets_total", "XDP packets by forwarding path.", "path"),
+		redirects:  r.Counter("fwd_xdp_redirect_packets_total", "XDP redirected packets by egress ifindex.", "iface"),
+		redirectsB: r.Counter("fwd_xdp_redirect_bytes_total", "XDP redirected bytes by egress ifindex.", "iface"),
+	}
+}
+
+//bpf.Observer
+func (m *srvMetrics) observe(table string, backend string, op string, cost time.Duration, err error) {
+	m.latency.Observe(cost.Seconds(), backend, op)
+	if err != nil {
+		m.errors.Inc(table, op)
+	}
+}
+
+func (m *srvMetrics) request(action string, code uint32) {
+	if len(action) <= 0 {
+		action = "unknown"
+	}
+	m.requests.Inc(action, strconv.FormatUint(uint64(code), 10))
+}
+
+//采集时从BPF Map读取转发面计数, 表项数由usages周期刷新
+func (m *srvMetrics) collect(s *Srv) func(ctx context.Context) {
+	return func(ctx context.Context) {
+		var stats, err = s.fwdCli.QryStats(ctx)
+		if err != nil {
+			s.logger.Errorw(ctx, "collect xdp stats fail", "err", err)
+			return
+		}
+		m.stats(stats)
+	}
+}
+
+func (m *srvMetrics) usage(usage []*fwd.TableUsage) {
+	for _, u := range usage {
+		m.entries.Set(float64(u.Size), u.Name)
+		m.capacity.Set(float64(u.Capacity), u.Name)
+	}
+}
+
+//统计表项数需遍历多张表, 不随每次采集进行
+func (s *Srv) usages() {
+	var interval = usageInterval
+	if s.cfg.MetricsCfg.UsageInterval > 0 {
+		interval = time.Duration(s.cfg.MetricsCfg.UsageInterval) * time.Second
+	}
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		var usage, err = s.fwdCli.QryUsage(s.ctx)
+		if err != nil {
+			s.logger.Errorw(s.ctx, "collect table usage fail", "err", err)
+		}
+		s.metrics.usage(usage)
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *srvMetrics) stats(stats *fwd.StatsElem) {
+	m.xdp.Set(float64(stats.FastHit), "fast")
+	m.xdp.Set(float64(stats.LpmHit), "lpm")
+	m.xdp.Set(float64(stats.SlowHit), "slow")
+	m.xdp.Set(float64(stats.FibMiss), "miss")
+	m.xdp.Set(float64(stats.Drop), "drop")
+	m.xdp.Set(float64(stats.Blackhole), "blackhole")
+	m.xdp.Set(float64(stats.Pass), "pass")
+	m.xdp.Set(float64(stats.AclDeny), "acl_deny")
+	m.xdp.Set(float64(stats.Blocked), "blocked")
+	m.xdp.Set(float64(stats.Policed), "policed")
+	m.xdp.Set(float64(stats.VlanMiss), "vlan_miss")
+	m.xdp.Set(float64(stats.Encap), "encap")
+	m.xdp.Set(float64(stats.Decap), "decap")
+	m.xdp.Set(float64(stats.Policy), "policy")
+	for _, i := range stats.Ifaces {
+		var iface = strconv.FormatUint(uint64(i.Iface), 10)
+		m.redirects.Set(float64(i.Packets), iface)
+		m.redirectsB.Set(float64(i.Bytes), iface)
+	}
+}
+
+//记录响应中的业务码
+type metricWriter struct {
+	netx.IHTTPWriteReader
+	code uint32
+}
+
+func (w *metricWriter) Write(code int, body interface{}) {
+	if r, ok := body.(interface{ GetCode() uint32 }); ok {
+		w.code = r.GetCode()
+	}
+	w.IHTTPWriteReader.Write(code, body)
+}
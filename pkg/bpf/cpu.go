@@ -0,0 +1,54 @@
+package bpf
+
+import (
+	"io/ioutil"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	cpuOnce sync.Once
+	cpuNum  int
+)
+
+//内核按possible CPU个数分配Per-CPU Map的value
+//
+// eg: /sys/devices/system/cpu/possible
+//
+// 0-3
+// 0,2-5
+func PossibleCPUs() int {
+	cpuOnce.Do(func() {
+		var b, err = ioutil.ReadFile("/sys/devices/system/cpu/possible")
+		if err != nil {
+			cpuNum = runtime.NumCPU()
+			return
+		}
+		cpuNum = parseCPUs(strings.TrimSpace(string(b)))
+		if cpuNum <= 0 {
+			cpuNum = runtime.NumCPU()
+		}
+	})
+	return cpuNum
+}
+
+//返回最大CPU编号+1
+func parseCPUs(s string) int {
+	var n = 0
+	for _, r := range strings.Split(s, ",") {
+		var hi = r
+		if i := strings.Index(r, "-"); i >= 0 {
+			hi = r[i+1:]
+		}
+		var v, err = strconv.Atoi(hi)
+		if err != nil {
+			return 0
+		}
+		if v+1 > n {
+			n = v + 1
+		}
+	}
+	return n
+}
@@ -56,6 +56,18 @@ func withMap() bpftoolOption {
 	}
 }
 
+func withProg() bpftoolOption {
+	return func(a *bpftool) {
+		a.object = "prog"
+	}
+}
+
+func withNet() bpftoolOption {
+	return func(a *bpftool) {
+		a.object = "net"
+	}
+}
+
 func withLog(l logx.ILogger) bpftoolOption {
 	return func(a *bpftool) {
 		a.logger = l
@@ -68,10 +80,6 @@ func withCmd(cmd string) bpftoolOption {
 	}
 }
 
-func withShowMapCmd() bpftoolOption {
-	return withCmd("show")
-}
-
 func withCreateMapCmd(name string, file string, tYpe string, keySize int, valueSize int, entries int, flags int) bpftoolOption {
 	//mount bpffs /sys/fs/bpf -t bpf
 	file = fmt.Sprintf("%s/%s", BPFFS, file)
@@ -153,6 +161,39 @@ func withUpdateMapInMapCmd(file string, key []byte, inner string, flag string) b
 	return withCmd(cmd)
 }
 
+//maps 复用已固定(pin)的Map, 保证控制面与转发面操作同一张表
+//dev  offload模式需要在加载时绑定设备
+func withLoadProgCmd(obj string, file string, tYpe string, maps []string, dev string) bpftoolOption {
+	file = fmt.Sprintf("%s/%s", BPFFS, file)
+	var cmd = fmt.Sprintf("load %s %s type %s", obj, file, tYpe)
+
+	for i := range maps {
+		cmd = fmt.Sprintf("%s map name %s pinned %s/%s", cmd, maps[i], BPFFS, maps[i])
+	}
+	if len(dev) > 0 {
+		cmd = fmt.Sprintf("%s dev %s", cmd, dev)
+	}
+	return withCmd(cmd)
+}
+
+func withRunProgCmd(file string, in string) bpftoolOption {
+	file = fmt.Sprintf("%s/%s", BPFFS, file)
+	var cmd = fmt.Sprintf("run pinned %s data_in %s repeat 1", file, in)
+	return withCmd(cmd)
+}
+
+//attach: xdp | xdpgeneric | xdpdrv | xdpoffload
+func withAttachNetCmd(attach string, file string, dev string) bpftoolOption {
+	file = fmt.Sprintf("%s/%s", BPFFS, file)
+	var cmd = fmt.Sprintf("attach %s pinned %s dev %s overwrite", attach, file, dev)
+	return withCmd(cmd)
+}
+
+func withDetachNetCmd(attach string, dev string) bpftoolOption {
+	var cmd = fmt.Sprintf("detach %s dev %s", attach, dev)
+	return withCmd(cmd)
+}
+
 func (a *bpftool) run(ctx context.Context, reply interface{}, errs interface{}) error {
 	var (
 		args   = fmt.Sprintf("%s %s %s", a.options, a.object, a.cmd)
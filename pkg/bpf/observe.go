@@ -0,0 +1,105 @@
+package bpf
+
+import (
+	"context"
+	"time"
+)
+
+//表操作观测回调, 用于统计时延和错误
+// table   表名
+// backend bpftool or syscall
+// op      create update delete lookup query update_batch delete_batch
+type Observer func(table string, backend string, op string, cost time.Duration, err error)
+
+func WithObserver(o Observer) TableOption {
+	return func(t *table) {
+		t.observer = o
+	}
+}
+
+type observedTable struct {
+	ITable
+	t *table
+}
+
+func (o *observedTable) observe(op string, start time.Time, err error) {
+	o.t.observer(o.t.file, o.t.backend, op, time.Since(start), err)
+}
+
+//批量操作任一条目失败视为失败
+func (o *observedTable) first(errs []error) error {
+	for i := range errs {
+		if errs[i] != nil {
+			return errs[i]
+		}
+	}
+	return nil
+}
+
+func (o *observedTable) CreateTable(ctx context.Context) error {
+	var start = time.Now()
+	var err = o.ITable.CreateTable(ctx)
+	o.observe("create", start, err)
+	return err
+}
+
+func (o *observedTable) CreateMapInMapTable(ctx context.Context, inner string) error {
+	var start = time.Now()
+	var err = o.ITable.CreateMapInMapTable(ctx, inner)
+	o.observe("create", start, err)
+	return err
+}
+
+func (o *observedTable) QueryTable(ctx context.Context) ([]*KV, error) {
+	var start = time.Now()
+	var r, err = o.ITable.QueryTable(ctx)
+	o.observe("query", start, err)
+	return r, err
+}
+
+//key不存在属于正常结果, 不计为错误
+func (o *observedTable) LookupTable(ctx context.Context, key []byte) ([]byte, error) {
+	var start = time.Now()
+	var r, err = o.ITable.LookupTable(ctx, key)
+	if err == ErrKeyNotExist {
+		o.observe("lookup", start, nil)
+	} else {
+		o.observe("lookup", start, err)
+	}
+	return r, err
+}
+
+func (o *observedTable) DeleteTable(ctx context.Context, key []byte) error {
+	var start = time.Now()
+	var err = o.ITable.DeleteTable(ctx, key)
+	o.observe("delete", start, err)
+	return err
+}
+
+func (o *observedTable) UpdateTable(ctx context.Context, key []byte, value []byte) error {
+	var start = time.Now()
+	var err = o.ITable.UpdateTable(ctx, key, value)
+	o.observe("update", start, err)
+	return err
+}
+
+func (o *observedTable) DeleteBatchTable(ctx context.Context, keys [][]byte) []error {
+	var start = time.Now()
+	var errs = o.ITable.DeleteBatchTable(ctx, keys)
+	o.observe("delete_batch", start, o.first(errs))
+	return errs
+}
+
+func (o *observedTable) UpdateBatchTable(ctx context.Context, kvs []*KV) []error {
+	var start = time.Now()
+	var errs = o.ITable.UpdateBatchTable(ctx, kvs)
+	o.observe("update_batch", start, o.first(errs))
+	return errs
+}
+
+func (o *observedTable) UpdateMapInMapTable(ctx context.Context, key []byte, inner string) error {
+	var start = time.Now()
+	var err = o.ITable.UpdateMapInMapTable(ctx, key, inner)
+	o.observe("update", start, err)
+	return err
+}
@@ -0,0 +1,170 @@
+package bpf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/advancevillage/3rd/logx"
+)
+
+type IProg interface {
+	GCProg(ctx context.Context) error
+	LoadProg(ctx context.Context, maps []string) error
+	AttachProg(ctx context.Context, iface string) error
+	DetachProg(ctx context.Context, iface string) error
+	RunProg(ctx context.Context, data []byte) (uint32, error)
+}
+
+//XDP挂载模式
+// native   驱动模式 xdpdrv
+// generic  通用模式 xdpgeneric
+// offload  网卡卸载 xdpoffload
+// auto     由内核选择 xdp
+const (
+	ModeAuto    = "auto"
+	ModeNative  = "native"
+	ModeGeneric = "generic"
+	ModeOffload = "offload"
+)
+
+type prog struct {
+	obj    string
+	file   string
+	attach string
+	dev    string
+	logger logx.ILogger
+}
+
+//obj   编译后的ELF文件 eg: xdp/fwd.bpf.o
+//file  程序固定(pin)在bpffs上的文件名
+//mode  挂载模式
+//dev   offload模式绑定的设备
+func NewProgClient(logger logx.ILogger, obj string, file string, mode string, dev string) (IProg, error) {
+	var p = &prog{
+		obj:    obj,
+		file:   file,
+		logger: logger,
+	}
+	switch strings.ToLower(mode) {
+	case ModeAuto, "":
+		p.attach = "xdp"
+	case ModeNative:
+		p.attach = "xdpdrv"
+	case ModeGeneric:
+		p.attach = "xdpgeneric"
+	case ModeOffload:
+		if len(dev) <= 0 {
+			return nil, fmt.Errorf("offload mode need bind dev")
+		}
+		p.attach = "xdpoffload"
+		p.dev = dev
+	default:
+		return nil, fmt.Errorf("don't support %s mode", mode)
+	}
+	if len(obj) <= 0 || len(file) <= 0 {
+		return nil, fmt.Errorf("obj or file param are invalid")
+	}
+	return p, nil
+}
+
+func (p *prog) LoadProg(ctx context.Context, maps []string) error {
+	var ebpf = newBpfTool(
+		withLog(p.logger),
+		withExec(),
+		withJSON(),
+		withProg(),
+		withLoadProgCmd(p.obj, p.file, "xdp", maps, p.dev),
+	)
+	var r string
+	var errs = new(bpfErr)
+	var err = ebpf.run(ctx, &r, errs)
+	if err != nil {
+		return err
+	}
+	if len(errs.Err) > 0 {
+		err = errors.New(errs.Err)
+	}
+	return err
+}
+
+func (p *prog) AttachProg(ctx context.Context, iface string) error {
+	var ebpf = newBpfTool(
+		withLog(p.logger),
+		withExec(),
+		withJSON(),
+		withNet(),
+		withAttachNetCmd(p.attach, p.file, iface),
+	)
+	var r string
+	var errs = new(bpfErr)
+	var err = ebpf.run(ctx, &r, errs)
+	if err != nil {
+		return err
+	}
+	if len(errs.Err) > 0 {
+		err = errors.New(errs.Err)
+	}
+	return err
+}
+
+func (p *prog) DetachProg(ctx context.Context, iface string) error {
+	var ebpf = newBpfTool(
+		withLog(p.logger),
+		withExec(),
+		withJSON(),
+		withNet(),
+		withDetachNetCmd(p.attach, iface),
+	)
+	var r string
+	var errs = new(bpfErr)
+	var err = ebpf.run(ctx, &r, errs)
+	if err != nil {
+		return err
+	}
+	if len(errs.Err) > 0 {
+		err = errors.New(errs.Err)
+	}
+	return err
+}
+
+//以data为输入报文执行一次已加载的程序, 返回XDP动作, 供测试转发面逻辑
+func (p *prog) RunProg(ctx context.Context, data []byte) (uint32, error) {
+	var f, err = ioutil.TempFile("", "xdp")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	f.Close()
+	if err != nil {
+		return 0, err
+	}
+	var ebpf = newBpfTool(
+		withLog(p.logger),
+		withExec(),
+		withJSON(),
+		withProg(),
+		withRunProgCmd(p.file, f.Name()),
+	)
+	var r = new(bpfRun)
+	var errs = new(bpfErr)
+	err = ebpf.run(ctx, r, errs)
+	if err != nil {
+		return 0, err
+	}
+	if len(errs.Err) > 0 {
+		return 0, errors.New(errs.Err)
+	}
+	return r.Retval, nil
+}
+
+func (p *prog) GCProg(ctx context.Context) error {
+	var ebpf = newBpfTool(
+		withLog(p.logger),
+	)
+	return ebpf.unlink(ctx, p.file)
+}
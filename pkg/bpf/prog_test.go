@@ -0,0 +1,64 @@
+package bpf
+
+import (
+	"testing"
+
+	"github.com/advancevillage/3rd/logx"
+	"github.com/stretchr/testify/assert"
+)
+
+var testProg = map[string]struct {
+	mode   string
+	dev    string
+	attach string
+	err    bool
+}{
+	"case-auto": {
+		mode:   ModeAuto,
+		attach: "xdp",
+	},
+	"case-native": {
+		mode:   ModeNative,
+		attach: "xdpdrv",
+	},
+	"case-generic": {
+		mode:   ModeGeneric,
+		attach: "xdpgeneric",
+	},
+	"case-offload": {
+		mode:   ModeOffload,
+		dev:    "eth0",
+		attach: "xdpoffload",
+	},
+	"case-offload-nodev": {
+		mode: ModeOffload,
+		err:  true,
+	},
+	"case-unknown": {
+		mode: "skb",
+		err:  true,
+	},
+}
+
+func Test_prog_mode(t *testing.T) {
+	logger, err := logx.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	for n, p := range testProg {
+		f := func(t *testing.T) {
+			var cli, err = NewProgClient(logger, "xdp/fwd.bpf.o", randStr(8), p.mode, p.dev)
+			if p.err {
+				assert.NotNil(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+				return
+			}
+			assert.Equal(t, p.attach, cli.(*prog).attach)
+		}
+		t.Run(n, f)
+	}
+}
@@ -0,0 +1,488 @@
+package bpf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+//基于bpf(2)系统调用直接操作内核Map, 不依赖bpftool
+//
+// 每次操作仅需一次系统调用, 避免fork进程带来的开销
+var sysMapTypes = map[string]uint32{
+	"hash":         unix.BPF_MAP_TYPE_HASH,
+	"array":        unix.BPF_MAP_TYPE_ARRAY,
+	"lpm_trie":     unix.BPF_MAP_TYPE_LPM_TRIE,
+	"hash_of_maps": unix.BPF_MAP_TYPE_HASH_OF_MAPS,
+	"lru_hash":     unix.BPF_MAP_TYPE_LRU_HASH,
+
+	"percpu_hash":     unix.BPF_MAP_TYPE_PERCPU_HASH,
+	"percpu_array":    unix.BPF_MAP_TYPE_PERCPU_ARRAY,
+	"lru_percpu_hash": unix.BPF_MAP_TYPE_LRU_PERCPU_HASH,
+}
+
+//union bpf_attr 中 BPF_MAP_CREATE 对应的结构
+type sysMapCreateAttr struct {
+	mapType    uint32
+	keySize    uint32
+	valueSize  uint32
+	maxEntries uint32
+	mapFlags   uint32
+	innerMapFd uint32
+	numaNode   uint32
+	mapName    [16]byte
+}
+
+//bpf_attr指针字段固定为8字节
+var _ = [1]struct{}{}[unsafe.Sizeof(sysPointer{})-8]
+
+//union bpf_attr 中 BPF_MAP_*_ELEM 对应的结构
+type sysMapElemAttr struct {
+	mapFd uint32
+	_     uint32
+	key   sysPointer
+	value sysPointer //value or next_key
+	flags uint64
+}
+
+//union bpf_attr 中 BPF_MAP_*_BATCH 对应的结构
+type sysMapBatchAttr struct {
+	inBatch   sysPointer
+	outBatch  sysPointer
+	keys      sysPointer
+	values    sysPointer
+	count     uint32
+	mapFd     uint32
+	elemFlags uint64
+	flags     uint64
+}
+
+//union bpf_attr 中 BPF_OBJ_* 对应的结构
+type sysObjAttr struct {
+	pathname  sysPointer
+	bpfFd     uint32
+	fileFlags uint32
+}
+
+//fd按固定文件的inode缓存, 表被其他进程删除重建后重新打开
+type sysTable struct {
+	t   *table
+	mu  sync.Mutex
+	fd  int
+	ino uint64
+}
+
+func newSysTable(t *table) (ITable, error) {
+	if _, ok := sysMapTypes[t.tYpe]; !ok {
+		return nil, fmt.Errorf("syscall backend don't support %s map type", t.tYpe)
+	}
+	return &sysTable{t: t, fd: -1}, nil
+}
+
+func (s *sysTable) CreateTable(ctx context.Context) error {
+	return s.create(ctx, -1)
+}
+
+func (s *sysTable) CreateMapInMapTable(ctx context.Context, inner string) error {
+	var innerFd, err = sysObjGet(fmt.Sprintf("%s/%s", BPFFS, inner))
+	if err != nil {
+		return err
+	}
+	defer unix.Close(innerFd)
+	return s.create(ctx, innerFd)
+}
+
+func (s *sysTable) UpdateTable(ctx context.Context, key []byte, value []byte) error {
+	if len(key) != s.t.keySize {
+		return fmt.Errorf("key len is not %d", s.t.keySize)
+	}
+	if len(value) != s.t.size() {
+		return fmt.Errorf("value len is not %d", s.t.size())
+	}
+	var fd, err = s.open()
+	if err != nil {
+		return err
+	}
+	return sysMapUpdate(fd, key, value, unix.BPF_ANY)
+}
+
+//内核支持时使用BPF_MAP_UPDATE_BATCH, 否则逐条更新
+func (s *sysTable) UpdateBatchTable(ctx context.Context, kvs []*KV) []error {
+	var (
+		errs   = make([]error, len(kvs))
+		idx    = make([]int, 0, len(kvs))
+		keys   = make([]byte, 0, len(kvs)*s.t.keySize)
+		size   = s.t.size()
+		values = make([]byte, 0, len(kvs)*size)
+	)
+	for i := range kvs {
+		switch {
+		case len(kvs[i].Key) != s.t.keySize:
+			errs[i] = fmt.Errorf("key len is not %d", s.t.keySize)
+		case len(kvs[i].Value) != size:
+			errs[i] = fmt.Errorf("value len is not %d", size)
+		default:
+			idx = append(idx, i)
+			keys = append(keys, kvs[i].Key...)
+			values = append(values, kvs[i].Value...)
+		}
+	}
+	var fd, err = s.open()
+	if err != nil {
+		for _, i := range idx {
+			errs[i] = err
+		}
+		return errs
+	}
+	var batch = func(start int) (int, error) {
+		return sysMapBatch(unix.BPF_MAP_UPDATE_BATCH, fd, keys[start*s.t.keySize:], values[start*size:], len(idx)-start)
+	}
+	var single = func(start int) error {
+		return sysMapUpdate(fd, keys[start*s.t.keySize:(start+1)*s.t.keySize], values[start*size:(start+1)*size], unix.BPF_ANY)
+	}
+	s.batch(idx, errs, batch, single)
+	return errs
+}
+
+func (s *sysTable) DeleteBatchTable(ctx context.Context, keys [][]byte) []error {
+	var (
+		errs = make([]error, len(keys))
+		idx  = make([]int, 0, len(keys))
+		kk   = make([]byte, 0, len(keys)*s.t.keySize)
+	)
+	for i := range keys {
+		if len(keys[i]) != s.t.keySize {
+			errs[i] = fmt.Errorf("key len is not %d", s.t.keySize)
+			continue
+		}
+		idx = append(idx, i)
+		kk = append(kk, keys[i]...)
+	}
+	var fd, err = s.open()
+	if err != nil {
+		for _, i := range idx {
+			errs[i] = err
+		}
+		return errs
+	}
+	var batch = func(start int) (int, error) {
+		return sysMapBatch(unix.BPF_MAP_DELETE_BATCH, fd, kk[start*s.t.keySize:], nil, len(idx)-start)
+	}
+	var single = func(start int) error {
+		return sysMapDelete(fd, kk[start*s.t.keySize:(start+1)*s.t.keySize])
+	}
+	s.batch(idx, errs, batch, single)
+	for i := range errs {
+		if errors.Is(errs[i], unix.ENOENT) {
+			errs[i] = ErrKeyNotExist
+		}
+	}
+	return errs
+}
+
+//批量操作遇到错误时内核返回已处理条数, 记录失败条目后从下一条继续
+//内核或Map类型不支持批量操作时回退为逐条操作
+func (s *sysTable) batch(idx []int, errs []error, batch func(int) (int, error), single func(int) error) {
+	var start = 0
+	for start < len(idx) {
+		var n, err = batch(start)
+		if err == nil {
+			return
+		}
+		start += n
+		if s.unsupported(err) {
+			break
+		}
+		if start < len(idx) {
+			errs[idx[start]] = err
+			start++
+		}
+	}
+	for ; start < len(idx); start++ {
+		errs[idx[start]] = single(start)
+	}
+}
+
+func (s *sysTable) unsupported(err error) bool {
+	//ENOTSUPP(524) 内核内部错误码, unix包未导出
+	return errors.Is(err, unix.EINVAL) || errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.Errno(524))
+}
+
+func (s *sysTable) UpdateMapInMapTable(ctx context.Context, key []byte, inner string) error {
+	if len(key) != s.t.keySize {
+		return fmt.Errorf("key len is not %d", s.t.keySize)
+	}
+	var fd, err = s.open()
+	if err != nil {
+		return err
+	}
+	innerFd, err := sysObjGet(fmt.Sprintf("%s/%s", BPFFS, inner))
+	if err != nil {
+		return err
+	}
+	defer unix.Close(innerFd)
+
+	var value = make([]byte, 4)
+	*(*uint32)(unsafe.Pointer(&value[0])) = uint32(innerFd)
+
+	return sysMapUpdate(fd, key, value, unix.BPF_ANY)
+}
+
+func (s *sysTable) QueryTable(ctx context.Context) ([]*KV, error) {
+	var fd, err = s.open()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		rr   = make([]*KV, 0, 2)
+		key  []byte
+		next = make([]byte, s.t.keySize)
+	)
+	for {
+		err = sysMapNextKey(fd, key, next)
+		if errors.Is(err, unix.ENOENT) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var v = &KV{
+			Key:   make([]byte, s.t.keySize),
+			Value: make([]byte, s.t.size()),
+		}
+		copy(v.Key, next)
+		err = sysMapLookup(fd, v.Key, v.Value)
+		switch {
+		case errors.Is(err, unix.ENOENT):
+			//遍历过程中被删除
+		case err != nil:
+			return nil, err
+		default:
+			rr = append(rr, v)
+		}
+		key = v.Key
+	}
+	return rr, nil
+}
+
+func (s *sysTable) LookupTable(ctx context.Context, key []byte) ([]byte, error) {
+	if len(key) != s.t.keySize {
+		return nil, fmt.Errorf("key len is not %d", s.t.keySize)
+	}
+	var fd, err = s.open()
+	if err != nil {
+		return nil, err
+	}
+	var v = make([]byte, s.t.size())
+	err = sysMapLookup(fd, key, v)
+	if errors.Is(err, unix.ENOENT) {
+		return nil, ErrKeyNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (s *sysTable) ExistTable(ctx context.Context) bool {
+	var _, err = s.open()
+	return err == nil
+}
+
+func (s *sysTable) DeleteTable(ctx context.Context, key []byte) error {
+	if len(key) != s.t.keySize {
+		return fmt.Errorf("key len is not %d", s.t.keySize)
+	}
+	var fd, err = s.open()
+	if err != nil {
+		return err
+	}
+	err = sysMapDelete(fd, key)
+	if errors.Is(err, unix.ENOENT) {
+		err = ErrKeyNotExist
+	}
+	return err
+}
+
+func (s *sysTable) GCTable(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.close()
+	return unix.Unlink(s.path())
+}
+
+func (s *sysTable) create(ctx context.Context, innerFd int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var attr = sysMapCreateAttr{
+		mapType:    sysMapTypes[s.t.tYpe],
+		keySize:    uint32(s.t.keySize),
+		valueSize:  uint32(s.t.valueSize),
+		maxEntries: uint32(s.t.maxEntries),
+		mapFlags:   uint32(s.t.flags),
+	}
+	if innerFd >= 0 {
+		attr.innerMapFd = uint32(innerFd)
+	}
+	//map名称最大16个字符(包含结束符)
+	copy(attr.mapName[:len(attr.mapName)-1], s.t.file)
+
+	var fd, err = sysBpf(unix.BPF_MAP_CREATE, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	if err != nil {
+		return err
+	}
+	err = sysObjPin(int(fd), s.path())
+	if err != nil {
+		unix.Close(int(fd))
+		return err
+	}
+	var st unix.Stat_t
+	err = unix.Stat(s.path(), &st)
+	if err != nil {
+		unix.Close(int(fd))
+		return err
+	}
+	s.close()
+	s.fd, s.ino = int(fd), uint64(st.Ino)
+	s.t.logger.Infow(ctx, "bpf syscall", "create", s.path())
+	return nil
+}
+
+//打开并缓存固定(pin)在bpffs上的Map
+//固定文件被删除或替换(inode变化)时关闭旧fd, 避免继续操作已脱离bpffs的Map
+func (s *sysTable) open() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var st unix.Stat_t
+	var err = unix.Stat(s.path(), &st)
+	if err != nil {
+		s.close()
+		return -1, err
+	}
+	if s.fd >= 0 && s.ino == uint64(st.Ino) {
+		return s.fd, nil
+	}
+	s.close()
+	fd, err := sysObjGet(s.path())
+	if err != nil {
+		return -1, err
+	}
+	s.fd, s.ino = fd, uint64(st.Ino)
+	return s.fd, nil
+}
+
+func (s *sysTable) close() {
+	if s.fd >= 0 {
+		unix.Close(s.fd)
+	}
+	s.fd, s.ino = -1, 0
+}
+
+func (s *sysTable) path() string {
+	return fmt.Sprintf("%s/%s", BPFFS, s.t.file)
+}
+
+//空切片对应NULL
+func sysPtr(b []byte) sysPointer {
+	if len(b) <= 0 {
+		return sysPointer{}
+	}
+	return sysPointer{ptr: unsafe.Pointer(&b[0])}
+}
+
+func sysBpf(cmd int, attr unsafe.Pointer, size uintptr) (uintptr, error) {
+	var r, _, errno = unix.Syscall(unix.SYS_BPF, uintptr(cmd), uintptr(attr), size)
+	if errno != 0 {
+		return r, errno
+	}
+	return r, nil
+}
+
+func sysObjPin(fd int, path string) error {
+	var p, err = unix.BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+	var attr = sysObjAttr{
+		pathname: sysPointer{ptr: unsafe.Pointer(p)},
+		bpfFd:    uint32(fd),
+	}
+	_, err = sysBpf(unix.BPF_OBJ_PIN, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	return err
+}
+
+func sysObjGet(path string) (int, error) {
+	var p, err = unix.BytePtrFromString(path)
+	if err != nil {
+		return -1, err
+	}
+	var attr = sysObjAttr{
+		pathname: sysPointer{ptr: unsafe.Pointer(p)},
+	}
+	fd, err := sysBpf(unix.BPF_OBJ_GET, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	if err != nil {
+		return -1, err
+	}
+	return int(fd), nil
+}
+
+func sysMapUpdate(fd int, key []byte, value []byte, flags uint64) error {
+	var attr = sysMapElemAttr{
+		mapFd: uint32(fd),
+		key:   sysPtr(key),
+		value: sysPtr(value),
+		flags: flags,
+	}
+	var _, err = sysBpf(unix.BPF_MAP_UPDATE_ELEM, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	return err
+}
+
+func sysMapLookup(fd int, key []byte, value []byte) error {
+	var attr = sysMapElemAttr{
+		mapFd: uint32(fd),
+		key:   sysPtr(key),
+		value: sysPtr(value),
+	}
+	var _, err = sysBpf(unix.BPF_MAP_LOOKUP_ELEM, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	return err
+}
+
+func sysMapDelete(fd int, key []byte) error {
+	var attr = sysMapElemAttr{
+		mapFd: uint32(fd),
+		key:   sysPtr(key),
+	}
+	var _, err = sysBpf(unix.BPF_MAP_DELETE_ELEM, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	return err
+}
+
+//返回内核已处理的条数
+func sysMapBatch(cmd int, fd int, keys []byte, values []byte, count int) (int, error) {
+	var attr = sysMapBatchAttr{
+		mapFd: uint32(fd),
+		keys:  sysPtr(keys),
+		count: uint32(count),
+	}
+	attr.values = sysPtr(values)
+	var _, err = sysBpf(cmd, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	if err != nil {
+		return int(attr.count), err
+	}
+	return count, nil
+}
+
+//key为空时返回第一个key
+func sysMapNextKey(fd int, key []byte, next []byte) error {
+	var attr = sysMapElemAttr{
+		mapFd: uint32(fd),
+		value: sysPtr(next),
+	}
+	attr.key = sysPtr(key)
+	var _, err = sysBpf(unix.BPF_MAP_GET_NEXT_KEY, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	return err
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package bpf
+
+import (
+	"errors"
+)
+
+func newSysTable(t *table) (ITable, error) {
+	return nil, errors.New("syscall backend only support linux")
+}
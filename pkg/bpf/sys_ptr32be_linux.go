@@ -0,0 +1,14 @@
+//go:build linux && mips
+// +build linux,mips
+
+package bpf
+
+import (
+	"unsafe"
+)
+
+//bpf_attr中的64位指针字段, 32位大端平台高位补0
+type sysPointer struct {
+	_   uint32
+	ptr unsafe.Pointer
+}
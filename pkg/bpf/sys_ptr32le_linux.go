@@ -0,0 +1,15 @@
+//go:build linux && (386 || arm || mipsle)
+// +build linux
+// +build 386 arm mipsle
+
+package bpf
+
+import (
+	"unsafe"
+)
+
+//bpf_attr中的64位指针字段, 32位小端平台高位补0
+type sysPointer struct {
+	ptr unsafe.Pointer
+	_   uint32
+}
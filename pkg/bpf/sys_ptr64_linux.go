@@ -0,0 +1,13 @@
+//go:build linux && !386 && !arm && !mips && !mipsle
+// +build linux,!386,!arm,!mips,!mipsle
+
+package bpf
+
+import (
+	"unsafe"
+)
+
+//bpf_attr中的64位指针字段, 以unsafe.Pointer保存使GC可追踪所指内存
+type sysPointer struct {
+	ptr unsafe.Pointer
+}
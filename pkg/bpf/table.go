@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/advancevillage/3rd/logx"
@@ -14,10 +15,19 @@ type ITable interface {
 	ExistTable(ctx context.Context) bool
 	CreateTable(ctx context.Context) error
 	QueryTable(ctx context.Context) ([]*KV, error)
+	LookupTable(ctx context.Context, key []byte) ([]byte, error)
 	DeleteTable(ctx context.Context, key []byte) error
 	UpdateTable(ctx context.Context, key []byte, value []byte) error
+	DeleteBatchTable(ctx context.Context, keys [][]byte) []error
+	UpdateBatchTable(ctx context.Context, kvs []*KV) []error
+	CreateMapInMapTable(ctx context.Context, inner string) error
+	UpdateMapInMapTable(ctx context.Context, key []byte, inner string) error
 }
 
+var (
+	ErrKeyNotExist = errors.New("key does not exist")
+)
+
 type KV struct {
 	Key   []byte `json:"key"`
 	Value []byte `json:"Value"`
@@ -28,6 +38,22 @@ const (
 	bpf_f_no_prealloc = 1
 )
 
+//表操作后端
+// bpftool  通过bpftool命令操作Map
+// syscall  通过bpf(2)系统调用操作Map
+const (
+	BackendBpftool = "bpftool"
+	BackendSyscall = "syscall"
+)
+
+type TableOption func(*table)
+
+func WithBackend(backend string) TableOption {
+	return func(t *table) {
+		t.backend = strings.ToLower(backend)
+	}
+}
+
 type table struct {
 	tYpe       string
 	file       string
@@ -35,13 +61,20 @@ type table struct {
 	valueSize  int
 	maxEntries int
 	flags      int
+	cpus       int
+	backend    string
+	observer   Observer
 	logger     logx.ILogger
 }
 
-func NewTableClient(logger logx.ILogger, file string, tYpe string, keySize int, valueSize int, maxEntries int) (ITable, error) {
+func NewTableClient(logger logx.ILogger, file string, tYpe string, keySize int, valueSize int, maxEntries int, opts ...TableOption) (ITable, error) {
 	//1. 预设类型对应的Flags
 	var t = &table{
-		logger: logger,
+		logger:  logger,
+		backend: BackendBpftool,
+	}
+	for _, opt := range opts {
+		opt(t)
 	}
 	tYpe = strings.ToLower(tYpe)
 	switch tYpe {
@@ -73,6 +106,24 @@ func NewTableClient(logger logx.ILogger, file string, tYpe string, keySize int,
 		if keySize < 1 || valueSize < 1 {
 			return nil, fmt.Errorf("keySize or valueSize param are invalid")
 		}
+	case "percpu_hash":
+		t.flags = bpf_f_no_prealloc
+		t.cpus = PossibleCPUs()
+		if keySize < 1 || valueSize < 1 {
+			return nil, fmt.Errorf("keySize or valueSize param are invalid")
+		}
+	case "lru_percpu_hash":
+		t.flags = bpf_f_prealloc
+		t.cpus = PossibleCPUs()
+		if keySize < 1 || valueSize < 1 {
+			return nil, fmt.Errorf("keySize or valueSize param are invalid")
+		}
+	case "percpu_array":
+		if keySize != 4 {
+			return nil, fmt.Errorf("keySize param is invalid")
+		}
+		t.flags = bpf_f_prealloc
+		t.cpus = PossibleCPUs()
 	default:
 		return nil, fmt.Errorf("don't support %s map type", tYpe)
 	}
@@ -81,7 +132,26 @@ func NewTableClient(logger logx.ILogger, file string, tYpe string, keySize int,
 	t.keySize = keySize
 	t.valueSize = valueSize
 	t.maxEntries = maxEntries
-	return t, nil
+	//2. 选择后端
+	var it ITable
+	var err error
+	switch t.backend {
+	case BackendBpftool, "":
+		t.backend = BackendBpftool
+		it = t
+	case BackendSyscall:
+		it, err = newSysTable(t)
+	default:
+		err = fmt.Errorf("don't support %s backend", t.backend)
+	}
+	if err != nil {
+		return nil, err
+	}
+	//3. 观测
+	if t.observer != nil {
+		it = &observedTable{ITable: it, t: t}
+	}
+	return it, nil
 }
 
 func (t *table) CreateTable(ctx context.Context) error {
@@ -104,12 +174,32 @@ func (t *table) CreateTable(ctx context.Context) error {
 	return err
 }
 
+func (t *table) CreateMapInMapTable(ctx context.Context, inner string) error {
+	var ebpf = newBpfTool(
+		withLog(t.logger),
+		withExec(),
+		withJSON(),
+		withMap(),
+		withCreateMapInMapCmd(t.file, t.file, inner, t.tYpe, t.keySize, t.valueSize, t.maxEntries, t.flags),
+	)
+	var r string
+	var errs = new(bpfErr)
+	var err = ebpf.run(ctx, &r, errs)
+	if err != nil {
+		return err
+	}
+	if len(errs.Err) > 0 {
+		err = errors.New(errs.Err)
+	}
+	return err
+}
+
 func (t *table) UpdateTable(ctx context.Context, key []byte, value []byte) error {
 	if len(key) != t.keySize {
 		return fmt.Errorf("key len is not %d", t.keySize)
 	}
-	if len(value) != t.valueSize {
-		return fmt.Errorf("value len is not %d", t.valueSize)
+	if len(value) != t.size() {
+		return fmt.Errorf("value len is not %d", t.size())
 	}
 	var ebpf = newBpfTool(
 		withLog(t.logger),
@@ -130,6 +220,46 @@ func (t *table) UpdateTable(ctx context.Context, key []byte, value []byte) error
 	return err
 }
 
+//bpftool不支持批量操作, 逐条更新
+func (t *table) UpdateBatchTable(ctx context.Context, kvs []*KV) []error {
+	var errs = make([]error, len(kvs))
+	for i := range kvs {
+		errs[i] = t.UpdateTable(ctx, kvs[i].Key, kvs[i].Value)
+	}
+	return errs
+}
+
+func (t *table) DeleteBatchTable(ctx context.Context, keys [][]byte) []error {
+	var errs = make([]error, len(keys))
+	for i := range keys {
+		errs[i] = t.DeleteTable(ctx, keys[i])
+	}
+	return errs
+}
+
+func (t *table) UpdateMapInMapTable(ctx context.Context, key []byte, inner string) error {
+	if len(key) != t.keySize {
+		return fmt.Errorf("key len is not %d", t.keySize)
+	}
+	var ebpf = newBpfTool(
+		withLog(t.logger),
+		withExec(),
+		withJSON(),
+		withMap(),
+		withUpdateMapInMapCmd(t.file, key, inner, "any"),
+	)
+	var r string
+	var errs = new(bpfErr)
+	var err = ebpf.run(ctx, &r, errs)
+	if err != nil {
+		return err
+	}
+	if len(errs.Err) > 0 {
+		err = errors.New(errs.Err)
+	}
+	return err
+}
+
 func (t *table) QueryTable(ctx context.Context) ([]*KV, error) {
 	var ebpf = newBpfTool(
 		withLog(t.logger),
@@ -138,11 +268,7 @@ func (t *table) QueryTable(ctx context.Context) ([]*KV, error) {
 		withMap(),
 		withDumpMapCmd(t.file),
 	)
-	type kv struct {
-		Key   []string `json:"key"`
-		Value []string `json:"value"`
-	}
-	type kvList []kv
+	type kvList []bpfKV
 
 	var r = new(kvList)
 	var errs = new(bpfErr)
@@ -168,43 +294,54 @@ func (t *table) QueryTable(ctx context.Context) ([]*KV, error) {
 	for i := range *r {
 		var v = &KV{
 			Key:   make([]byte, t.keySize),
-			Value: make([]byte, t.valueSize),
+			Value: t.value(&(*r)[i]),
 		}
 		for ii := range (*r)[i].Key {
 			v.Key[ii] = t.hex((*r)[i].Key[ii])
 		}
-		for ii := range (*r)[i].Value {
-			v.Value[ii] = t.hex((*r)[i].Value[ii])
-		}
 		rr = append(rr, v)
 	}
 	return rr, nil
 }
 
-func (t *table) ExistTable(ctx context.Context) bool {
+func (t *table) LookupTable(ctx context.Context, key []byte) ([]byte, error) {
+	if len(key) != t.keySize {
+		return nil, fmt.Errorf("key len is not %d", t.keySize)
+	}
 	var ebpf = newBpfTool(
 		withLog(t.logger),
 		withExec(),
 		withJSON(),
 		withMap(),
-		withShowMapCmd(),
+		withLookUpMapCmd(t.file, key),
 	)
-	var r = new(bpfMaps)
+	var r = new(bpfKV)
 	var errs = new(bpfErr)
+	//eg: 正常
+	//
+	// {"key":["0x12","0x34","0x56","0x78"],"value":["0x87","0x65","0x43","0x21"]}
+	//
+	//eg:  key不存在
+	//
+	// null
+	//
 	var err = ebpf.run(ctx, r, errs)
 	if err != nil {
-		return true
+		return nil, err
 	}
-	var exist = false
-	for i := range *r {
-		if (*r)[i].Name == t.file {
-			exist = true
-			break
-		} else {
-			continue
-		}
+	if len(errs.Err) > 0 {
+		return nil, errors.New(errs.Err)
 	}
-	return exist
+	if len(r.Value) <= 0 && len(r.Values) <= 0 {
+		return nil, ErrKeyNotExist
+	}
+	return t.value(r), nil
+}
+
+//直接检查固定路径, 避免周期调用时fork bpftool map show
+func (t *table) ExistTable(ctx context.Context) bool {
+	var _, err = os.Stat(fmt.Sprintf("%s/%s", BPFFS, t.file))
+	return err == nil
 }
 
 func (t *table) DeleteTable(ctx context.Context, key []byte) error {
@@ -221,7 +358,14 @@ func (t *table) DeleteTable(ctx context.Context, key []byte) error {
 	var r = make(map[string]interface{})
 	var errs = new(bpfErr)
 
+	//eg:  key不存在
+	//
+	// {"error":"delete failed: No such file or directory"}
+	//
 	var err = ebpf.run(ctx, &r, errs)
+	if strings.HasPrefix(errs.Err, "delete failed") && strings.Contains(errs.Err, "No such file or directory") {
+		return ErrKeyNotExist
+	}
 	if err != nil {
 		return err
 	}
@@ -238,6 +382,51 @@ func (t *table) GCTable(ctx context.Context) error {
 	return ebpf.unlink(ctx, t.file)
 }
 
+//Per-CPU Map的value为每个CPU的值按8字节对齐后依次拼接
+// len = cpus * round_up(valueSize, 8)
+func (t *table) size() int {
+	if t.cpus <= 0 {
+		return t.valueSize
+	}
+	return t.cpus * t.stride()
+}
+
+func (t *table) stride() int {
+	return (t.valueSize + 7) &^ 7
+}
+
+//eg: Per-CPU Map
+//
+// {"key":["0x0","0x0","0x0","0x0"],"values":[{"cpu":0,"value":["0x1","0x0"]},{"cpu":1,"value":["0x2","0x0"]}]}
+type bpfKV struct {
+	Key    []string `json:"key"`
+	Value  []string `json:"value"`
+	Values []struct {
+		Cpu   int      `json:"cpu"`
+		Value []string `json:"value"`
+	} `json:"values"`
+}
+
+func (t *table) value(r *bpfKV) []byte {
+	var v = make([]byte, t.size())
+	if t.cpus <= 0 {
+		for i := 0; i < len(r.Value) && i < len(v); i++ {
+			v[i] = t.hex(r.Value[i])
+		}
+		return v
+	}
+	for _, c := range r.Values {
+		if c.Cpu < 0 || c.Cpu >= t.cpus {
+			continue
+		}
+		var off = c.Cpu * t.stride()
+		for i := 0; i < len(c.Value) && i < t.valueSize; i++ {
+			v[off+i] = t.hex(c.Value[i])
+		}
+	}
+	return v
+}
+
 func (t *table) hex(s string) byte {
 	var v = byte(0)
 	var vv = byte(0)
@@ -5,10 +5,12 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/advancevillage/3rd/logx"
+	"github.com/stretchr/testify/assert"
 )
 
 var testTable = map[string]struct {
@@ -17,6 +19,7 @@ var testTable = map[string]struct {
 	keySize    int
 	valueSize  int
 	maxEntries int
+	backend    string
 }{
 	//普通普通类型
 	"case1": {
@@ -24,6 +27,15 @@ var testTable = map[string]struct {
 		keySize:    4,
 		valueSize:  4,
 		maxEntries: 64,
+		backend:    BackendBpftool,
+	},
+	//系统调用
+	"case2": {
+		tYpe:       "hash",
+		keySize:    4,
+		valueSize:  4,
+		maxEntries: 64,
+		backend:    BackendSyscall,
 	},
 	//结构体
 }
@@ -37,7 +49,7 @@ func Test_table(t *testing.T) {
 	for n, p := range testTable {
 		f := func(t *testing.T) {
 			p.file = randStr(8)
-			var ta, err = NewTableClient(logger, p.file, p.tYpe, p.keySize, p.valueSize, p.maxEntries)
+			var ta, err = NewTableClient(logger, p.file, p.tYpe, p.keySize, p.valueSize, p.maxEntries, WithBackend(p.backend))
 			if err != nil {
 				t.Fatal(err)
 				return
@@ -176,6 +188,82 @@ func Test_table(t *testing.T) {
 	}
 }
 
+//两种后端均以固定路径判断表是否存在
+func Test_exist_table(t *testing.T) {
+	logger, err := logx.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var (
+		ctx  = context.TODO()
+		file = randStr(8)
+	)
+	sys, err := NewTableClient(logger, file, "hash", 4, 4, 16, WithBackend(BackendSyscall))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	tool, err := NewTableClient(logger, file, "hash", 4, 4, 16, WithBackend(BackendBpftool))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	assert.False(t, sys.ExistTable(ctx))
+	assert.False(t, tool.ExistTable(ctx))
+	assert.Nil(t, sys.CreateTable(ctx))
+	assert.True(t, sys.ExistTable(ctx))
+	assert.True(t, tool.ExistTable(ctx))
+	assert.Nil(t, sys.GCTable(ctx))
+	assert.False(t, sys.ExistTable(ctx))
+	assert.False(t, tool.ExistTable(ctx))
+}
+
+//表被其他客户端删除重建后, 缓存的fd需重新打开
+func Test_sys_table_reopen(t *testing.T) {
+	logger, err := logx.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var (
+		ctx  = context.TODO()
+		file = randStr(8)
+		key  = []byte{1, 0, 0, 0}
+	)
+	a, err := NewTableClient(logger, file, "hash", 4, 4, 16, WithBackend(BackendSyscall))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	b, err := NewTableClient(logger, file, "hash", 4, 4, 16, WithBackend(BackendSyscall))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	assert.Nil(t, a.CreateTable(ctx))
+	assert.Nil(t, a.UpdateTable(ctx, key, []byte{1, 0, 0, 0}))
+	v, err := b.LookupTable(ctx, key)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{1, 0, 0, 0}, v)
+
+	assert.Nil(t, a.GCTable(ctx))
+	_, err = b.LookupTable(ctx, key)
+	assert.NotNil(t, err)
+	assert.False(t, b.ExistTable(ctx))
+
+	assert.Nil(t, a.CreateTable(ctx))
+	assert.Nil(t, a.UpdateTable(ctx, key, []byte{2, 0, 0, 0}))
+	v, err = b.LookupTable(ctx, key)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{2, 0, 0, 0}, v)
+	assert.Nil(t, b.UpdateTable(ctx, key, []byte{3, 0, 0, 0}))
+	v, err = a.LookupTable(ctx, key)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{3, 0, 0, 0}, v)
+	assert.Nil(t, a.GCTable(ctx))
+}
+
 var testinnerTable = map[string]struct {
 	inner           string
 	outer           string
@@ -187,6 +275,7 @@ var testinnerTable = map[string]struct {
 	outerValueSzie  int
 	innerMaxEntries int
 	outerMaxEntries int
+	backend         string
 	debug           bool
 }{
 	"case-lpm-hashinmap": {
@@ -200,6 +289,21 @@ var testinnerTable = map[string]struct {
 		outerValueSzie:  4,
 		innerMaxEntries: 16,
 		outerMaxEntries: 16,
+		backend:         BackendBpftool,
+		debug:           false,
+	},
+	"case-lpm-hashinmap-syscall": {
+		inner:           randStr(4),
+		outer:           randStr(4),
+		innerType:       "lpm_trie",
+		outerType:       "hash_of_maps",
+		innerKeySize:    8,
+		innerValueSzie:  16,
+		outterKeySize:   48,
+		outerValueSzie:  4,
+		innerMaxEntries: 16,
+		outerMaxEntries: 16,
+		backend:         BackendSyscall,
 		debug:           false,
 	},
 }
@@ -212,7 +316,7 @@ func Test_hash_in_map(t *testing.T) {
 	}
 	for n, p := range testinnerTable {
 		f := func(t *testing.T) {
-			var inner, err = NewTableClient(logger, p.inner, p.innerType, p.innerKeySize, p.innerValueSzie, p.innerMaxEntries)
+			var inner, err = NewTableClient(logger, p.inner, p.innerType, p.innerKeySize, p.innerValueSzie, p.innerMaxEntries, WithBackend(p.backend))
 			if err != nil {
 				t.Fatal(err)
 				return
@@ -228,7 +332,7 @@ func Test_hash_in_map(t *testing.T) {
 			}
 
 			//2. 创建外表
-			outer, err := NewTableClient(logger, p.outer, p.outerType, p.outterKeySize, p.outerValueSzie, p.outerMaxEntries)
+			outer, err := NewTableClient(logger, p.outer, p.outerType, p.outterKeySize, p.outerValueSzie, p.outerMaxEntries, WithBackend(p.backend))
 			if err != nil {
 				t.Fatal(err)
 				return
@@ -317,6 +421,140 @@ func Test_hex(t *testing.T) {
 	}
 }
 
+var testBatchTable = map[string]struct {
+	tYpe       string
+	keySize    int
+	valueSize  int
+	maxEntries int
+	backend    string
+}{
+	"case-lru-syscall": {
+		tYpe:       "lru_hash",
+		keySize:    4,
+		valueSize:  16,
+		maxEntries: 128,
+		backend:    BackendSyscall,
+	},
+	//不支持批量操作, 回退逐条操作
+	"case-lpm-syscall": {
+		tYpe:       "lpm_trie",
+		keySize:    8,
+		valueSize:  16,
+		maxEntries: 128,
+		backend:    BackendSyscall,
+	},
+	//value按CPU个数展开
+	"case-percpu-syscall": {
+		tYpe:       "lru_percpu_hash",
+		keySize:    4,
+		valueSize:  12,
+		maxEntries: 128,
+		backend:    BackendSyscall,
+	},
+}
+
+func Test_table_batch(t *testing.T) {
+	logger, err := logx.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	for n, p := range testBatchTable {
+		f := func(t *testing.T) {
+			var ta, err = NewTableClient(logger, randStr(8), p.tYpe, p.keySize, p.valueSize, p.maxEntries, WithBackend(p.backend))
+			if err != nil {
+				t.Fatal(err)
+				return
+			}
+			var ctx, cancel = context.WithTimeout(context.Background(), time.Second*20)
+			defer cancel()
+
+			err = ta.CreateTable(ctx)
+			if err != nil {
+				t.Fatal("create table fail", err)
+				return
+			}
+			defer ta.GCTable(ctx)
+			//1. 准备数据 第二条长度非法
+			var data = make([]*KV, 0, p.maxEntries/2)
+			var keys = make([][]byte, 0, p.maxEntries/2)
+			var size = p.valueSize
+			if strings.Contains(p.tYpe, "percpu") {
+				size = PossibleCPUs() * ((p.valueSize + 7) &^ 7)
+			}
+			for i := 0; i < p.maxEntries/2; i++ {
+				var kv = &KV{
+					Key:   make([]byte, p.keySize),
+					Value: make([]byte, size),
+				}
+				if p.tYpe == "lpm_trie" {
+					kv.Key[0] = 32
+				}
+				kv.Key[p.keySize-1] = byte(i)
+				kv.Value[0] = byte(i)
+				data = append(data, kv)
+				keys = append(keys, kv.Key)
+			}
+			data[1] = &KV{Key: []byte{0x01}, Value: data[1].Value}
+			//2. 批量更新
+			var errs = ta.UpdateBatchTable(ctx, data)
+			for i := range errs {
+				if i == 1 {
+					assert.NotNil(t, errs[i])
+				} else {
+					assert.Nil(t, errs[i])
+				}
+			}
+			act, err := ta.QueryTable(ctx)
+			if err != nil {
+				t.Fatal(err)
+				return
+			}
+			assert.Equal(t, len(data)-1, len(act))
+			v, err := ta.LookupTable(ctx, data[0].Key)
+			assert.Nil(t, err)
+			assert.Equal(t, data[0].Value, v)
+			//3. 批量删除 第二条不存在
+			errs = ta.DeleteBatchTable(ctx, keys)
+			for i := range errs {
+				if i == 1 {
+					assert.NotNil(t, errs[i])
+				} else {
+					assert.Nil(t, errs[i])
+				}
+			}
+			act, err = ta.QueryTable(ctx)
+			if err != nil {
+				t.Fatal(err)
+				return
+			}
+			assert.Equal(t, 0, len(act))
+			_, err = ta.LookupTable(ctx, data[0].Key)
+			assert.Equal(t, ErrKeyNotExist, err)
+		}
+		t.Run(n, f)
+	}
+}
+
+var testCPUs = map[string]struct {
+	s string
+	n int
+}{
+	"case1": {s: "0", n: 1},
+	"case2": {s: "0-3", n: 4},
+	"case3": {s: "0,2-5", n: 6},
+	"case4": {s: "x", n: 0},
+}
+
+func Test_parse_cpus(t *testing.T) {
+	for n, p := range testCPUs {
+		f := func(t *testing.T) {
+			assert.Equal(t, p.n, parseCPUs(p.s))
+		}
+		t.Run(n, f)
+	}
+}
+
 func randStr(length int) string {
 	str := "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
 	bytes := []byte(str)
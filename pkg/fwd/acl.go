@@ -0,0 +1,282 @@
+package fwd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/advancevillage/fwd/pkg/bpf"
+)
+
+var (
+	ErrAclRule  = errors.New("acl rule is invalid")
+	ErrAclLimit = errors.New("acl rule count exceeds limit")
+)
+
+//ACL 有序规则集, 仅IPv4, 首条匹配的规则生效, 无匹配时放行
+// afwd  BPF_MAP_TYPE_ARRAY        规则       key: 槽位 value: acl_rule
+// acfg  BPF_MAP_TYPE_ARRAY        生效规则集 key: 0    value: set << 16 | count
+// acnt  BPF_MAP_TYPE_PERCPU_HASH  命中计数   key: 槽位 value: pkts(8) + bytes(8)
+//
+// afwd 双缓冲, 写入备用规则集后更新acfg切换, XDP始终看到完整的一套规则
+// acl_rule src(4) smask(4) dst(4) dmask(4) sport(2+2) dport(2+2) ifindex(4) proto(1) action(1) pad(2)
+var (
+	aclMaxRules  = int(64)
+	aclRuleSize  = int(0x20)
+	aclCfgSize   = int(0x04)
+	aclName      = "afwd"
+	aclCfgName   = "acfg"
+	aclCntName   = "acnt"
+	aclCntMaxKey = aclMaxRules * 2
+)
+
+//规则动作, 与fwd.bpf.c ACL_ALLOW/ACL_DENY保持一致
+const (
+	AclAllow = "allow"
+	AclDeny  = "deny"
+)
+
+//Src/Dst     源/目的前缀 为空表示任意 eg: 10.0.0.0/8 10.1.1.1
+//Proto       IP协议号 0表示任意, 指定端口时须为TCP(6)或UDP(17)
+//Sport/Dport 端口闭区间 Max为0表示任意
+//            分片或L4头不完整的报文端口未知, 指定端口的deny规则命中, allow规则不命中
+//Iface       入接口 IfName非空时优先, 均为空表示任意
+//Packets/Bytes 命中计数 仅查询
+type AclRule struct {
+	Src      string
+	Dst      string
+	Proto    uint8
+	SportMin uint16
+	SportMax uint16
+	DportMin uint16
+	DportMax uint16
+	Iface    uint32
+	IfName   string
+	Action   string
+	Packets  uint64
+	Bytes    uint64
+}
+
+//整体替换规则集, 任一规则无效时不修改已生效的规则
+func (d *fwdCli) UptAcl(ctx context.Context, rules []*AclRule) error {
+	if len(rules) > aclMaxRules {
+		return ErrAclLimit
+	}
+	var values = make([][]byte, 0, len(rules))
+	for i, r := range rules {
+		var v, err = d.aclValue(ctx, r)
+		if err != nil {
+			return fmt.Errorf("acl rule %d: %w", i, err)
+		}
+		values = append(values, v)
+	}
+	var set, _, err = d.aclCfg(ctx)
+	if err != nil {
+		return err
+	}
+	//写入备用规则集, 清理其旧计数
+	var (
+		next = 1 - set
+		kvs  = make([]*bpf.KV, 0, len(values))
+	)
+	d.clearAclCounters(ctx, next)
+	for i := range values {
+		kvs = append(kvs, &bpf.KV{Key: d.u32Key(uint32(next*aclMaxRules + i)), Value: values[i]})
+	}
+	for _, e := range d.aclCli.UpdateBatchTable(ctx, kvs) {
+		if e != nil {
+			return e
+		}
+	}
+	return d.setAclCfg(ctx, next, len(values))
+}
+
+//清空规则集, 全部放行
+func (d *fwdCli) DelAcl(ctx context.Context) error {
+	var set, _, err = d.aclCfg(ctx)
+	if err != nil {
+		return err
+	}
+	err = d.setAclCfg(ctx, set, 0)
+	if err != nil {
+		return err
+	}
+	d.clearAclCounters(ctx, set)
+	return nil
+}
+
+//按生效顺序返回规则及命中计数
+func (d *fwdCli) QryAcl(ctx context.Context) ([]*AclRule, error) {
+	var r = make([]*AclRule, 0, 2)
+	var set, count, err = d.aclCfg(ctx)
+	if err != nil || count <= 0 {
+		return r, err
+	}
+	var cnt = make(map[uint32][]byte)
+	if d.aclCntCli.ExistTable(ctx) {
+		var kv, err = d.aclCntCli.QueryTable(ctx)
+		if err != nil {
+			return r, err
+		}
+		for i := range kv {
+			cnt[d.u32(kv[i].Key)] = kv[i].Value
+		}
+	}
+	for i := 0; i < count; i++ {
+		var slot = uint32(set*aclMaxRules + i)
+		var v, err = d.aclCli.LookupTable(ctx, d.u32Key(slot))
+		if err != nil {
+			return r, err
+		}
+		var rule = d.aclRule(v)
+		if c, ok := cnt[slot]; ok {
+			rule.Packets = d.sum(c, cntValueSize, 0)
+			rule.Bytes = d.sum(c, cntValueSize, 8)
+		}
+		if rule.Iface > 0 && d.resolver != nil {
+			rule.IfName, _ = d.resolver.Name(ctx, rule.Iface)
+		}
+		r = append(r, rule)
+	}
+	return r, nil
+}
+
+//返回生效规则集及规则数, 表未创建时为0
+func (d *fwdCli) aclCfg(ctx context.Context) (int, int, error) {
+	var err = d.prepare(ctx, d.aclCli)
+	if err != nil {
+		return 0, 0, err
+	}
+	err = d.prepare(ctx, d.aclCfgCli)
+	if err != nil {
+		return 0, 0, err
+	}
+	v, err := d.aclCfgCli.LookupTable(ctx, d.u32Key(0))
+	if err != nil {
+		return 0, 0, err
+	}
+	var cfg = d.u32(v)
+	if cfg>>16 > 0 {
+		return 1, int(cfg & 0xffff), nil
+	}
+	return 0, int(cfg & 0xffff), nil
+}
+
+func (d *fwdCli) setAclCfg(ctx context.Context, set int, count int) error {
+	return d.aclCfgCli.UpdateTable(ctx, d.u32Key(0), d.u32Key(uint32(set<<16|count)))
+}
+
+func (d *fwdCli) clearAclCounters(ctx context.Context, set int) {
+	if !d.aclCntCli.ExistTable(ctx) {
+		return
+	}
+	var keys = make([][]byte, 0, aclMaxRules)
+	for i := 0; i < aclMaxRules; i++ {
+		keys = append(keys, d.u32Key(uint32(set*aclMaxRules+i)))
+	}
+	_ = d.aclCntCli.DeleteBatchTable(ctx, keys)
+}
+
+func (d *fwdCli) aclValue(ctx context.Context, r *AclRule) ([]byte, error) {
+	if r == nil {
+		return nil, ErrAclRule
+	}
+	var v = make([]byte, aclRuleSize)
+	src, smask, err := d.aclPrefix(r.Src)
+	if err != nil {
+		return nil, err
+	}
+	dst, dmask, err := d.aclPrefix(r.Dst)
+	if err != nil {
+		return nil, err
+	}
+	copy(v[0:4], src)
+	copy(v[4:8], smask)
+	copy(v[8:12], dst)
+	copy(v[12:16], dmask)
+	//端口
+	var ports = []uint16{r.SportMin, r.SportMax, r.DportMin, r.DportMax}
+	for i := 0; i < len(ports); i += 2 {
+		if ports[i+1] == 0 {
+			if ports[i] > 0 {
+				return nil, fmt.Errorf("port range is invalid: %w", ErrAclRule)
+			}
+			ports[i+1] = 0xffff
+		}
+		if ports[i] > ports[i+1] {
+			return nil, fmt.Errorf("port range is invalid: %w", ErrAclRule)
+		}
+		if (ports[i] > 0 || ports[i+1] < 0xffff) && r.Proto != 6 && r.Proto != 17 {
+			return nil, fmt.Errorf("port requires tcp or udp: %w", ErrAclRule)
+		}
+	}
+	for i := range ports {
+		v[16+2*i] = byte(ports[i])
+		v[17+2*i] = byte(ports[i] >> 8)
+	}
+	//入接口
+	var iface = r.Iface
+	if iface > 0 || len(r.IfName) > 0 {
+		iface, err = d.iface(ctx, r.Iface, r.IfName)
+		if err != nil {
+			return nil, err
+		}
+	}
+	copy(v[24:28], d.u32Key(iface))
+	v[28] = r.Proto
+	switch r.Action {
+	case AclAllow:
+	case AclDeny:
+		v[29] = 1
+	default:
+		return nil, fmt.Errorf("action %s is invalid: %w", r.Action, ErrAclRule)
+	}
+	return v, nil
+}
+
+func (d *fwdCli) aclRule(v []byte) *AclRule {
+	var r = &AclRule{
+		Src:      d.aclString(v[0:4], v[4:8]),
+		Dst:      d.aclString(v[8:12], v[12:16]),
+		SportMin: uint16(v[16]) | uint16(v[17])<<8,
+		SportMax: uint16(v[18]) | uint16(v[19])<<8,
+		DportMin: uint16(v[20]) | uint16(v[21])<<8,
+		DportMax: uint16(v[22]) | uint16(v[23])<<8,
+		Iface:    d.u32(v[24:28]),
+		Proto:    v[28],
+		Action:   AclAllow,
+	}
+	if v[29] == 1 {
+		r.Action = AclDeny
+	}
+	return r
+}
+
+//eg: 10.0.0.0/8 10.1.1.1 空表示任意
+func (d *fwdCli) aclPrefix(s string) ([]byte, []byte, error) {
+	if len(s) <= 0 {
+		return make([]byte, 4), make([]byte, 4), nil
+	}
+	if !strings.Contains(s, "/") {
+		s += "/32"
+	}
+	var _, ipnet, err = net.ParseCIDR(s)
+	if err != nil || ipnet.IP.To4() == nil || len(ipnet.Mask) != net.IPv4len {
+		return nil, nil, fmt.Errorf("prefix %s is invalid: %w", s, ErrAclRule)
+	}
+	return ipnet.IP.To4(), ipnet.Mask, nil
+}
+
+func (d *fwdCli) aclString(ip []byte, mask []byte) string {
+	var ones, _ = net.IPMask(mask).Size()
+	if ones <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s/%d", net.IP(ip).String(), ones)
+}
+
+func (d *fwdCli) u32Key(v uint32) []byte {
+	return []byte{byte(v), byte(v >> 8), byte(v >> 16), byte(v >> 24)}
+}
@@ -0,0 +1,235 @@
+package fwd
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/advancevillage/3rd/logx"
+	"github.com/advancevillage/fwd/pkg/bpf"
+	"github.com/stretchr/testify/assert"
+)
+
+var aclTest = map[string]struct {
+	rules []*AclRule
+	exp   []*AclRule
+	err   error
+}{
+	"case-ok": {
+		rules: []*AclRule{
+			{Src: "10.1.2.3/8", Dst: "192.0.2.1", Proto: 6, DportMin: 22, DportMax: 22, IfName: "eth3", Action: AclDeny},
+			{Proto: 17, SportMin: 1024, SportMax: 2048, Action: AclAllow},
+			{Dst: "198.51.100.0/24", Action: AclDeny},
+		},
+		exp: []*AclRule{
+			{Src: "10.0.0.0/8", Dst: "192.0.2.1/32", Proto: 6, SportMax: 0xffff, DportMin: 22, DportMax: 22, Iface: 3, IfName: "eth3", Action: AclDeny},
+			{Proto: 17, SportMin: 1024, SportMax: 2048, DportMax: 0xffff, Action: AclAllow},
+			{Dst: "198.51.100.0/24", SportMax: 0xffff, DportMax: 0xffff, Action: AclDeny},
+		},
+	},
+	"case-empty": {
+		rules: []*AclRule{},
+		exp:   []*AclRule{},
+	},
+	"case-ipv6": {
+		rules: []*AclRule{{Src: "2001:db8::/32", Action: AclDeny}},
+		err:   ErrAclRule,
+	},
+	"case-port-without-proto": {
+		rules: []*AclRule{{DportMin: 80, DportMax: 80, Action: AclDeny}},
+		err:   ErrAclRule,
+	},
+	"case-port-range": {
+		rules: []*AclRule{{Proto: 6, DportMin: 81, DportMax: 80, Action: AclDeny}},
+		err:   ErrAclRule,
+	},
+	"case-action": {
+		rules: []*AclRule{{Action: "reject"}},
+		err:   ErrAclRule,
+	},
+	"case-iface": {
+		rules: []*AclRule{{IfName: "bond0", Action: AclDeny}},
+		err:   ErrIfaceNotExist,
+	},
+	"case-limit": {
+		rules: make([]*AclRule, 65),
+		err:   ErrAclLimit,
+	},
+}
+
+func Test_fwd_acl(t *testing.T) {
+	logger, err := logx.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	c, err := NewFwdClient(logger, WithBackend(bpf.BackendSyscall), WithResolver(&fakeSource{}))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var (
+		ctx  = context.TODO()
+		last = []*AclRule{{Dst: "203.0.113.0/24", SportMax: 0xffff, DportMax: 0xffff, Action: AclDeny}}
+	)
+	for n, p := range aclTest {
+		f := func(t *testing.T) {
+			assert.Nil(t, c.UptAcl(ctx, last))
+			var err = c.UptAcl(ctx, p.rules)
+			assert.ErrorIs(t, err, p.err)
+			r, qerr := c.QryAcl(ctx)
+			assert.Nil(t, qerr)
+			//替换失败时保持原规则集
+			if err != nil {
+				assert.Equal(t, last, r)
+				return
+			}
+			assert.Equal(t, p.exp, r)
+		}
+		t.Run(n, f)
+	}
+	assert.Nil(t, c.DelAcl(ctx))
+	r, err := c.QryAcl(ctx)
+	assert.Nil(t, err)
+	assert.Empty(t, r)
+}
+
+//规则命中计数, 替换规则集后清零
+func Test_fwd_acl_counter(t *testing.T) {
+	logger, err := logx.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	c, err := NewFwdClient(logger, WithBackend(bpf.BackendSyscall), WithResolver(&fakeSource{}))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var (
+		ctx   = context.TODO()
+		d     = c.(*fwdCli)
+		rules = []*AclRule{{Dst: "203.0.113.0/24", Action: AclDeny}}
+	)
+	_, err = c.Tables(ctx)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	assert.Nil(t, c.UptAcl(ctx, rules))
+	set, _, err := d.aclCfg(ctx)
+	assert.Nil(t, err)
+	var v = make([]byte, bpf.PossibleCPUs()*cntValueSize)
+	v[0], v[8] = 3, 0xc8
+	assert.Nil(t, d.aclCntCli.UpdateTable(ctx, d.u32Key(uint32(set*aclMaxRules)), v))
+
+	r, err := c.QryAcl(ctx)
+	assert.Nil(t, err)
+	if assert.Len(t, r, 1) {
+		assert.Equal(t, uint64(3), r[0].Packets)
+		assert.Equal(t, uint64(200), r[0].Bytes)
+	}
+	assert.Nil(t, c.UptAcl(ctx, rules))
+	assert.Nil(t, c.UptAcl(ctx, rules))
+	r, err = c.QryAcl(ctx)
+	assert.Nil(t, err)
+	if assert.Len(t, r, 1) {
+		assert.Equal(t, uint64(0), r[0].Packets)
+	}
+	assert.Nil(t, c.DelAcl(ctx))
+}
+
+//XDP动作 与linux/bpf.h保持一致
+const (
+	xdpDrop = uint32(1)
+)
+
+//构造以太网+IPv4报文 opts为IP选项字数(4字节) frag为frag_off l4为携带的L4字节数
+func aclPkt(proto uint8, opts int, frag uint16, dport uint16, l4 int) []byte {
+	var (
+		hl  = 20 + opts*4
+		pkt = make([]byte, 14+hl+l4)
+		ip  = pkt[14:]
+	)
+	binary.BigEndian.PutUint16(pkt[12:], 0x0800)
+	ip[0] = 0x40 | byte(hl/4)
+	binary.BigEndian.PutUint16(ip[2:], uint16(hl+l4))
+	binary.BigEndian.PutUint16(ip[6:], frag)
+	ip[8], ip[9] = 64, proto
+	copy(ip[12:16], []byte{10, 30, 0, 1})
+	copy(ip[16:20], []byte{203, 0, 113, 30})
+	for i := 20; i < hl; i++ {
+		ip[i] = 0x01
+	}
+	var tcp = ip[hl:]
+	if l4 >= 4 {
+		binary.BigEndian.PutUint16(tcp[0:], 40000)
+		binary.BigEndian.PutUint16(tcp[2:], dport)
+	}
+	return pkt
+}
+
+var aclXdpTest = map[string]struct {
+	pkt  []byte
+	drop bool
+}{
+	"case-plain":       {pkt: aclPkt(6, 0, 0, 22, 20), drop: true},
+	"case-options":     {pkt: aclPkt(6, 1, 0, 22, 20), drop: true},
+	"case-options-max": {pkt: aclPkt(6, 10, 0, 22, 20), drop: true},
+	"case-other-port":  {pkt: aclPkt(6, 1, 0, 80, 20)},
+	"case-udp":         {pkt: aclPkt(17, 1, 0, 22, 8)},
+	"case-first-frag":  {pkt: aclPkt(6, 0, 0x2000, 80, 20), drop: true},
+	"case-later-frag":  {pkt: aclPkt(6, 0, 0x0010, 80, 20), drop: true},
+	"case-truncated":   {pkt: aclPkt(6, 1, 0, 80, 2), drop: true},
+}
+
+//deny tcp dport 22: 带IP选项的报文按ihl定位端口, 分片及L4不完整的报文命中指定端口的deny规则
+func Test_fwd_acl_xdp(t *testing.T) {
+	var obj = "../../xdp/fwd.bpf.o"
+	if _, err := os.Stat(obj); err != nil {
+		t.Skip("xdp object not built")
+	}
+	if _, err := exec.LookPath("bpftool"); err != nil {
+		t.Skip("bpftool not found")
+	}
+	logger, err := logx.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	c, err := NewFwdClient(logger, WithBackend(bpf.BackendSyscall), WithResolver(&fakeSource{}))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var ctx = context.TODO()
+	maps, err := c.Tables(ctx)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	p, err := bpf.NewProgClient(logger, obj, "tfwdacl", bpf.ModeAuto, "")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	_ = p.GCProg(ctx)
+	if err = p.LoadProg(ctx, maps); err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer p.GCProg(ctx)
+	assert.Nil(t, c.UptAcl(ctx, []*AclRule{{Proto: 6, DportMin: 22, DportMax: 22, Action: AclDeny}}))
+	defer c.DelAcl(ctx)
+
+	for n, q := range aclXdpTest {
+		f := func(t *testing.T) {
+			var act, err = p.RunProg(ctx, q.pkt)
+			assert.Nil(t, err)
+			assert.Equal(t, q.drop, act == xdpDrop)
+		}
+		t.Run(n, f)
+	}
+}
@@ -0,0 +1,106 @@
+package fwd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/advancevillage/3rd/logx"
+)
+
+//Pps      源地址报文速率阈值, 超过则封禁
+//Ttl      封禁时长 0表示永久
+//Interval 检测周期 默认1s
+type BanPolicy struct {
+	Pps      uint64
+	Ttl      time.Duration
+	Interval time.Duration
+}
+
+type IBanDetector interface {
+	Run(ctx context.Context) error
+}
+
+//按周期读取源地址计数, 两次采样的差值换算为pps
+//
+// 首次出现的源地址仅记录计数, 下一周期再判定
+// LRU淘汰后计数回退的源地址重新采样
+// 每周期遍历整张pfwd, 开销随源地址数(上限srcMaxSize)与CPU数增长, 源地址较多时应调大Interval
+type banDetector struct {
+	logger logx.ILogger
+	fwdCli IFwd
+	policy *BanPolicy
+	last   time.Time
+	pkts   map[string]uint64
+}
+
+func NewBanDetector(logger logx.ILogger, fwdCli IFwd, policy *BanPolicy) (IBanDetector, error) {
+	if policy == nil || policy.Pps <= 0 {
+		return nil, errors.New("ban pps threshold is invalid")
+	}
+	var p = *policy
+	if p.Interval <= 0 {
+		p.Interval = time.Second
+	}
+	return &banDetector{
+		logger: logger,
+		fwdCli: fwdCli,
+		policy: &p,
+		pkts:   make(map[string]uint64),
+	}, nil
+}
+
+func (b *banDetector) Run(ctx context.Context) error {
+	var ticker = time.NewTicker(b.policy.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			var _, err = b.detect(ctx, now)
+			if err != nil {
+				b.logger.Errorw(ctx, "detect source fail", "err", err)
+			}
+		}
+	}
+}
+
+//返回本周期封禁的源地址
+func (b *banDetector) detect(ctx context.Context, now time.Time) ([]string, error) {
+	var srcs, err = b.fwdCli.QrySources(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		r       = make([]string, 0, 2)
+		pkts    = make(map[string]uint64, len(srcs))
+		elapsed = now.Sub(b.last).Seconds()
+	)
+	for _, s := range srcs {
+		pkts[s.Ip] = s.Packets
+		var prev, ok = b.pkts[s.Ip]
+		if b.last.IsZero() || elapsed <= 0 || !ok || s.Packets < prev {
+			continue
+		}
+		var pps = float64(s.Packets-prev) / elapsed
+		if pps <= float64(b.policy.Pps) {
+			continue
+		}
+		var src = s.Ip + "/32"
+		if net.ParseIP(s.Ip).To4() == nil {
+			src = s.Ip + "/128"
+		}
+		err = b.fwdCli.AddBlock(ctx, src, b.policy.Ttl, true)
+		if err != nil {
+			b.logger.Errorw(ctx, "ban source fail", "src", src, "pps", pps, "err", err)
+			continue
+		}
+		b.logger.Warnw(ctx, "ban source", "src", src, "pps", pps, "ttl", b.policy.Ttl.String())
+		r = append(r, src)
+	}
+	b.pkts = pkts
+	b.last = now
+	return r, nil
+}
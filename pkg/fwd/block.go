@@ -0,0 +1,179 @@
+package fwd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/advancevillage/fwd/pkg/bpf"
+)
+
+var (
+	ErrBlockNotExist = errors.New("block entry does not exist")
+)
+
+//源地址黑名单 BPF_MAP_TYPE_LPM_TRIE, XDP解析L3后最先检查, 命中即丢弃
+// bfwd/bfwd6  key: prefixlen(4) + addr  value: deadline(8) + flags(1) + pad(7)
+//源地址计数 BPF_MAP_TYPE_LRU_PERCPU_HASH, 未命中黑名单的报文按源地址累加
+// pfwd        key: IPv6地址, IPv4按::ffff:a.b.c.d映射 value: pkts(8)
+//源地址计数开关 BPF_MAP_TYPE_ARRAY, 默认关闭, 开启自动封禁时打开
+// pcfg        key: 0 value: enable(4)
+var (
+	blockValueSize = int(0x10)
+	blockName      = "bfwd"
+	blockName6     = "bfwd6"
+	blockAuto      = byte(0x01)
+	srcKeySize     = int(0x10)
+	srcValueSize   = int(0x08)
+	srcMaxSize     = int(65536)
+	srcName        = "pfwd"
+	srcCfgName     = "pcfg"
+)
+
+//Ip/Prefix 源网络地址及前缀长度
+//Deadline  到期时间 unix秒, 0表示永久
+//Auto      超过pps阈值自动封禁
+type BlockElem struct {
+	Ip       string
+	Prefix   int
+	Deadline int64
+	Auto     bool
+}
+
+//Packets 源地址累计报文数, 各CPU汇总
+type SourceElem struct {
+	Ip      string
+	Packets uint64
+}
+
+//封禁源地址或前缀, 已存在时覆盖
+//ttl 为0时永久封禁
+func (d *fwdCli) AddBlock(ctx context.Context, src string, ttl time.Duration, auto bool) error {
+	var k, err = d.checkkey(src)
+	if err != nil {
+		return err
+	}
+	var t = d.blockTable(k)
+	err = d.prepare(ctx, t)
+	if err != nil {
+		return err
+	}
+	var v = make([]byte, blockValueSize)
+	if ttl > 0 {
+		var dl = uint64(time.Now().Add(ttl).Unix())
+		for i := 0; i < 8; i++ {
+			v[i] = byte(dl >> (8 * i))
+		}
+	}
+	if auto {
+		v[8] = blockAuto
+	}
+	return t.UpdateTable(ctx, d.cntKey(k), v)
+}
+
+func (d *fwdCli) DelBlock(ctx context.Context, src string) error {
+	var k, err = d.checkkey(src)
+	if err != nil {
+		return err
+	}
+	var t = d.blockTable(k)
+	if !t.ExistTable(ctx) {
+		return ErrBlockNotExist
+	}
+	err = t.DeleteTable(ctx, d.cntKey(k))
+	if errors.Is(err, bpf.ErrKeyNotExist) {
+		return ErrBlockNotExist
+	}
+	return err
+}
+
+func (d *fwdCli) QryBlock(ctx context.Context) ([]*BlockElem, error) {
+	var r = make([]*BlockElem, 0, 2)
+	for _, t := range []bpf.ITable{d.blockCli, d.blockCli6} {
+		if !t.ExistTable(ctx) {
+			continue
+		}
+		var kv, err = t.QueryTable(ctx)
+		if err != nil {
+			return r, err
+		}
+		for i := range kv {
+			r = append(r, &BlockElem{
+				Ip:       net.IP(kv[i].Key[4:]).String(),
+				Prefix:   int(d.u32(kv[i].Key)),
+				Deadline: int64(d.u64(kv[i].Value)),
+				Auto:     kv[i].Value[8]&blockAuto > 0,
+			})
+		}
+	}
+	return r, nil
+}
+
+//删除到期的黑名单条目, 返回已删除的源地址
+func (d *fwdCli) ExpireBlock(ctx context.Context) ([]string, error) {
+	var (
+		now = time.Now().Unix()
+		r   = make([]string, 0, 2)
+	)
+	var elems, err = d.QryBlock(ctx)
+	if err != nil {
+		return r, err
+	}
+	for _, e := range elems {
+		if e.Deadline <= 0 || e.Deadline > now {
+			continue
+		}
+		var src = d.prefix(&FwdElem{Ip: e.Ip, Prefix: e.Prefix})
+		err = d.DelBlock(ctx, src)
+		if err != nil && !errors.Is(err, ErrBlockNotExist) {
+			d.logger.Errorw(ctx, "expire block fail", "src", src, "err", err)
+			continue
+		}
+		r = append(r, src)
+	}
+	return r, nil
+}
+
+//开启或关闭XDP源地址计数, 关闭后已有计数由LRU淘汰
+func (d *fwdCli) CountSources(ctx context.Context, enable bool) error {
+	var err = d.prepare(ctx, d.srcCfgCli)
+	if err != nil {
+		return err
+	}
+	var v = uint32(0)
+	if enable {
+		v = 1
+	}
+	return d.srcCfgCli.UpdateTable(ctx, d.u32Key(0), d.u32Key(v))
+}
+
+//返回各源地址的累计报文数, 按地址排序
+func (d *fwdCli) QrySources(ctx context.Context) ([]*SourceElem, error) {
+	var r = make([]*SourceElem, 0, 2)
+	if !d.srcCli.ExistTable(ctx) {
+		return r, nil
+	}
+	var kv, err = d.srcCli.QueryTable(ctx)
+	if err != nil {
+		return r, err
+	}
+	for i := range kv {
+		r = append(r, &SourceElem{
+			Ip:      net.IP(kv[i].Key).String(),
+			Packets: d.sum(kv[i].Value, srcValueSize, 0),
+		})
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i].Ip < r[j].Ip })
+	return r, nil
+}
+
+func (d *fwdCli) blockTable(k []byte) bpf.ITable {
+	switch len(k) {
+	case key6Size, lpmKey6Size:
+		return d.blockCli6
+	default:
+		return d.blockCli
+	}
+}
@@ -0,0 +1,211 @@
+package fwd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/advancevillage/3rd/logx"
+	"github.com/advancevillage/fwd/pkg/bpf"
+	"github.com/stretchr/testify/assert"
+)
+
+var blockTest = map[string]struct {
+	src string
+	ttl time.Duration
+	exp *BlockElem
+	err bool
+}{
+	"case-host": {
+		src: "192.0.2.1",
+		exp: &BlockElem{Ip: "192.0.2.1", Prefix: 32},
+	},
+	"case-prefix": {
+		src: "198.51.100.7/24",
+		exp: &BlockElem{Ip: "198.51.100.0", Prefix: 24},
+	},
+	"case-ipv6": {
+		src: "2001:db8::/32",
+		exp: &BlockElem{Ip: "2001:db8::", Prefix: 32},
+	},
+	"case-ttl": {
+		src: "203.0.113.9",
+		ttl: time.Hour,
+		exp: &BlockElem{Ip: "203.0.113.9", Prefix: 32},
+	},
+	"case-invalid": {
+		src: "192.0.2.300",
+		err: true,
+	},
+}
+
+func Test_fwd_block(t *testing.T) {
+	logger, err := logx.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	c, err := NewFwdClient(logger, WithBackend(bpf.BackendSyscall), WithResolver(&fakeSource{}))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var ctx = context.TODO()
+	for n, p := range blockTest {
+		f := func(t *testing.T) {
+			var err = c.AddBlock(ctx, p.src, p.ttl, false)
+			if p.err {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			elems, err := c.QryBlock(ctx)
+			assert.Nil(t, err)
+			var found *BlockElem
+			for _, e := range elems {
+				if e.Ip == p.exp.Ip && e.Prefix == p.exp.Prefix {
+					found = e
+				}
+			}
+			if assert.NotNil(t, found) && p.ttl > 0 {
+				assert.InDelta(t, time.Now().Add(p.ttl).Unix(), found.Deadline, 2)
+			}
+			if found != nil && p.ttl <= 0 {
+				assert.Equal(t, int64(0), found.Deadline)
+			}
+			assert.Nil(t, c.DelBlock(ctx, p.src))
+			assert.ErrorIs(t, c.DelBlock(ctx, p.src), ErrBlockNotExist)
+		}
+		t.Run(n, f)
+	}
+}
+
+//到期条目被清理, 永久条目保留
+func Test_fwd_block_expire(t *testing.T) {
+	logger, err := logx.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	c, err := NewFwdClient(logger, WithBackend(bpf.BackendSyscall), WithResolver(&fakeSource{}))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var ctx = context.TODO()
+	//ttl不大于0视为永久
+	assert.Nil(t, c.AddBlock(ctx, "192.0.2.11", -time.Second, false))
+	assert.Nil(t, c.AddBlock(ctx, "192.0.2.12", 0, false))
+	srcs, err := c.ExpireBlock(ctx)
+	assert.Nil(t, err)
+	assert.NotContains(t, srcs, "192.0.2.11/32")
+	assert.NotContains(t, srcs, "192.0.2.12/32")
+
+	var d = c.(*fwdCli)
+	k, _ := d.checkkey("192.0.2.13")
+	var v = make([]byte, blockValueSize)
+	v[0] = 1
+	assert.Nil(t, d.blockCli.UpdateTable(ctx, d.cntKey(k), v))
+	srcs, err = c.ExpireBlock(ctx)
+	assert.Nil(t, err)
+	assert.Contains(t, srcs, "192.0.2.13/32")
+	assert.ErrorIs(t, c.DelBlock(ctx, "192.0.2.13"), ErrBlockNotExist)
+	assert.Nil(t, c.DelBlock(ctx, "192.0.2.11"))
+	assert.Nil(t, c.DelBlock(ctx, "192.0.2.12"))
+}
+
+var banTest = map[string]struct {
+	prev uint64
+	cur  uint64
+	ban  bool
+}{
+	"case-over": {
+		prev: 100,
+		cur:  400,
+		ban:  true,
+	},
+	"case-under": {
+		prev: 100,
+		cur:  200,
+	},
+	"case-reset": {
+		prev: 400,
+		cur:  100,
+	},
+}
+
+//模拟XDP源地址计数, 2s内增量超过100pps时封禁
+func Test_fwd_ban(t *testing.T) {
+	logger, err := logx.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	c, err := NewFwdClient(logger, WithBackend(bpf.BackendSyscall), WithResolver(&fakeSource{}))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var ctx = context.TODO()
+	_, err = c.Tables(ctx)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var (
+		d    = c.(*fwdCli)
+		cpus = bpf.PossibleCPUs()
+		key  = []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 192, 0, 2, 21}
+	)
+	for n, p := range banTest {
+		f := func(t *testing.T) {
+			det, err := NewBanDetector(logger, c, &BanPolicy{Pps: 100, Ttl: time.Minute})
+			assert.Nil(t, err)
+			var (
+				b   = det.(*banDetector)
+				now = time.Now()
+				v   = make([]byte, cpus*srcValueSize)
+			)
+			v[0], v[1] = byte(p.prev), byte(p.prev>>8)
+			assert.Nil(t, d.srcCli.UpdateTable(ctx, key, v))
+			srcs, err := b.detect(ctx, now)
+			assert.Nil(t, err)
+			assert.Empty(t, srcs)
+
+			v[0], v[1] = byte(p.cur), byte(p.cur>>8)
+			assert.Nil(t, d.srcCli.UpdateTable(ctx, key, v))
+			srcs, err = b.detect(ctx, now.Add(time.Second*2))
+			assert.Nil(t, err)
+			if !p.ban {
+				assert.Empty(t, srcs)
+				return
+			}
+			assert.Equal(t, []string{"192.0.2.21/32"}, srcs)
+			elems, err := c.QryBlock(ctx)
+			assert.Nil(t, err)
+			var found *BlockElem
+			for _, e := range elems {
+				if e.Ip == "192.0.2.21" {
+					found = e
+				}
+			}
+			if assert.NotNil(t, found) {
+				assert.True(t, found.Auto)
+				assert.InDelta(t, now.Add(time.Minute).Unix(), found.Deadline, 2)
+			}
+			assert.Nil(t, c.DelBlock(ctx, "192.0.2.21"))
+		}
+		t.Run(n, f)
+	}
+	_ = d.srcCli.DeleteTable(ctx, key)
+	_, err = NewBanDetector(logger, c, &BanPolicy{})
+	assert.NotNil(t, err)
+
+	//计数开关
+	for _, enable := range []bool{true, false} {
+		assert.Nil(t, c.CountSources(ctx, enable))
+		v, err := d.srcCfgCli.LookupTable(ctx, d.u32Key(0))
+		assert.Nil(t, err)
+		assert.Equal(t, enable, d.u32(v) > 0)
+	}
+}
@@ -4,59 +4,361 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/advancevillage/3rd/logx"
 	"github.com/advancevillage/fwd/pkg/bpf"
 )
 
+var (
+	ErrFwdNotExist = errors.New("forward entry does not exist")
+	ErrFwdAction   = errors.New("forward action is invalid")
+	ErrFwdVlan     = errors.New("forward vlan is invalid")
+)
+
+//表项来源, 静态表项由控制面下发, 学习表项由XDP slow_fwd回写hfwd/hfwd6
+const (
+	OriginStatic  = "static"
+	OriginLearned = "learned"
+)
+
+//表项动作, 为空等同redirect
+//drop 黑洞路由, XDP丢弃并计入表项计数
+//pass 交由内核协议栈处理
+const (
+	ActionRedirect = "redirect"
+	ActionDrop     = "drop"
+	ActionPass     = "pass"
+)
+
+//与fwd.bpf.c FWD_REDIRECT/FWD_DROP/FWD_PASS保持一致
+var actions = []string{ActionRedirect, ActionDrop, ActionPass}
+
 var (
 	keySize   = int(0x04)
-	valueSize = int(0x10)
+	key6Size  = int(0x10)
+	valueSize = int(0x20)
+	originOff = int(0x15)
+	actionOff = int(0x16)
+	vlanOff   = int(0x18)
+	vlanMax   = uint16(4094)
 	maxSize   = int(10000)
 	name      = "hfwd"
+	name6     = "hfwd6"
+	//前缀路由 key: prefixlen(4) + addr
+	lpmKeySize  = int(0x08)
+	lpmKey6Size = int(0x14)
+	lpmName     = "lfwd"
+	lpmName6    = "lfwd6"
 )
 
 type fwdCli struct {
 	tableCli  bpf.ITable
+	tableCli6 bpf.ITable
+	lpmCli    bpf.ITable
+	lpmCli6   bpf.ITable
+	groupCli  bpf.ITable
+	statCli   bpf.ITable
+	ifaceCli  bpf.ITable
+	cntCli    bpf.ITable
+	cntCli6   bpf.ITable
+	leaseCli  bpf.ITable
+	leaseCli6 bpf.ITable
+	aclCli    bpf.ITable
+	aclCfgCli bpf.ITable
+	aclCntCli bpf.ITable
+	blockCli  bpf.ITable
+	blockCli6 bpf.ITable
+	srcCli    bpf.ITable
+	srcCfgCli bpf.ITable
+	polCli    bpf.ITable
+	polCli6   bpf.ITable
+	tunCli    bpf.ITable
+	decapCli  bpf.ITable
+	pbrCli    bpf.ITable
+	pbrCfgCli bpf.ITable
+	pbrTblCli bpf.ITable
 	logger    logx.ILogger
 	keySize   int
+	key6Size  int
 	valueSize int
+	backend   string
+	observer  bpf.Observer
+	resolver  IResolver
+	//下一跳组增删与表项、策略对组的引用检查及写入串行
+	//避免DelGroup检查通过后新写入的表项引用已删除的组
+	groupMu sync.Mutex
+}
+
+type FwdOption func(*fwdCli)
+
+//bpftool or syscall
+func WithBackend(backend string) FwdOption {
+	return func(d *fwdCli) {
+		d.backend = backend
+	}
 }
 
+//观测表操作时延及错误
+func WithObserver(o bpf.Observer) FwdOption {
+	return func(d *fwdCli) {
+		d.observer = o
+	}
+}
+
+//Ip     目的地址 主机路由或前缀路由的网络地址
+//Prefix 前缀长度 主机路由为32或128
+//IfName 出接口名 更新时非空则优先于Iface
+//Group  下一跳组 非0时Iface/SrcMac/DstMac无效
+//Packets/Bytes XDP转发计数, 各CPU汇总
+//Action 表项动作 redirect/drop/pass, 非redirect时Iface/SrcMac/DstMac/Group/Tunnel无效
+//Origin 表项来源 static或learned
+//Orphaned 出接口已不存在
+//Deadline 租约到期时间 unix秒, 0表示永久有效
+//Policer  限速 Pps/Bps均为0表示不限速, 仅redirect表项有效
+//VlanIn   入VLAN 报文802.1Q VID与之一致时表项生效, 0表示不带标签
+//VlanOut  出VLAN 0表示不带标签, 与入VLAN不同时push/pop/rewrite, 仅redirect表项有效
+//Tunnel   隧道 非0时按隧道封装, Iface无效, SrcMac/DstMac为VXLAN内层MAC
+//Table    策略表 非0时写入该策略表, 仅IPv4, 不支持限速及租约
 type FwdElem struct {
-	Ip     string
-	Iface  uint32
-	SrcMac string
-	DstMac string
+	Ip       string
+	Prefix   int
+	Iface    uint32
+	IfName   string
+	SrcMac   string
+	DstMac   string
+	Group    uint32
+	Action   string
+	Origin   string
+	Packets  uint64
+	Bytes    uint64
+	Orphaned bool
+	Deadline int64
+	Policer  Policer
+	VlanIn   uint16
+	VlanOut  uint16
+	Tunnel   uint32
+	Table    uint32
 }
 
 type IFwd interface {
+	Tables(ctx context.Context) ([]string, error)
 	QryFwd(ctx context.Context) ([]*FwdElem, error)
+	ScanFwd(ctx context.Context) ([]*FwdElem, error)
 	DelFwd(ctx context.Context, dstIp string) error
+	DelFwdTable(ctx context.Context, table uint32, dstIp string) error
 	UptFwd(ctx context.Context, dstIp string, ifaceIndex uint32, srcmac string, dstmac string) error
+	DelFwdBatch(ctx context.Context, dstIps []string) []error
+	UptFwdBatch(ctx context.Context, elems []*FwdElem) []error
+	UptFwdGroup(ctx context.Context, dstIp string, group uint32) error
+	UptFwdAction(ctx context.Context, dstIp string, action string) error
+	FlushLearned(ctx context.Context) ([]string, error)
+	UptFwdVia(ctx context.Context, dstIp string, gateway string, ifName string) error
+	ResolveHop(ctx context.Context, gateway string, ifName string) (*NextHop, error)
+
+	QryGroup(ctx context.Context) ([]*GroupElem, error)
+	DelGroup(ctx context.Context, group uint32) error
+	AddGroup(ctx context.Context, group uint32, hops []*NextHop) error
+	UptGroup(ctx context.Context, group uint32, hops []*NextHop) error
+
+	QryStats(ctx context.Context) (*StatsElem, error)
+	QryUsage(ctx context.Context) ([]*TableUsage, error)
+
+	Snapshot(ctx context.Context, w io.Writer) (int, error)
+	Restore(ctx context.Context, r io.Reader) (int, error)
+
+	QryAcl(ctx context.Context) ([]*AclRule, error)
+	UptAcl(ctx context.Context, rules []*AclRule) error
+	DelAcl(ctx context.Context) error
+
+	QryTunnel(ctx context.Context) ([]*TunnelElem, error)
+	AddTunnel(ctx context.Context, t *TunnelElem) error
+	UptTunnel(ctx context.Context, t *TunnelElem) error
+	DelTunnel(ctx context.Context, tunnel uint32) error
+	QryDecap(ctx context.Context) ([]*DecapElem, error)
+	AddDecap(ctx context.Context, e *DecapElem) error
+	DelDecap(ctx context.Context, e *DecapElem) error
+
+	QryPolicy(ctx context.Context) ([]*PolicyElem, error)
+	AddPolicy(ctx context.Context, p *PolicyElem) error
+	DelPolicy(ctx context.Context, priority uint32) error
+
+	QryBlock(ctx context.Context) ([]*BlockElem, error)
+	AddBlock(ctx context.Context, src string, ttl time.Duration, auto bool) error
+	DelBlock(ctx context.Context, src string) error
+	ExpireBlock(ctx context.Context) ([]string, error)
+	QrySources(ctx context.Context) ([]*SourceElem, error)
+	CountSources(ctx context.Context, enable bool) error
+
+	LeaseFwd(ctx context.Context, dstIp string, ttl time.Duration) error
+	RenewFwd(ctx context.Context, dstIps []string, ttl time.Duration) []error
+	ExpireFwd(ctx context.Context) ([]string, error)
+	RunExpiry(ctx context.Context)
 }
 
-func NewFwdClient(logger logx.ILogger) (IFwd, error) {
-	var cli, err = bpf.NewTableClient(logger, name, "lru_hash", keySize, valueSize, maxSize)
-	if err != nil {
-		return nil, err
-	}
-	return &fwdCli{
-		tableCli:  cli,
+func NewFwdClient(logger logx.ILogger, opts ...FwdOption) (IFwd, error) {
+	var d = &fwdCli{
 		keySize:   keySize,
+		key6Size:  key6Size,
 		valueSize: valueSize,
 		logger:    logger,
-	}, nil
+		backend:   bpf.BackendBpftool,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	//非Linux无netlink, UptFwdVia返回ErrResolverAbsent
+	if d.resolver == nil {
+		d.resolver, _ = NewNetlinkSource(logger)
+	}
+	var cli, err = bpf.NewTableClient(logger, name, "lru_hash", keySize, valueSize, maxSize, d.tableOpts()...)
+	if err != nil {
+		return nil, err
+	}
+	cli6, err := bpf.NewTableClient(logger, name6, "lru_hash", key6Size, valueSize, maxSize, d.tableOpts()...)
+	if err != nil {
+		return nil, err
+	}
+	lpm, err := bpf.NewTableClient(logger, lpmName, "lpm_trie", lpmKeySize, valueSize, maxSize, d.tableOpts()...)
+	if err != nil {
+		return nil, err
+	}
+	lpm6, err := bpf.NewTableClient(logger, lpmName6, "lpm_trie", lpmKey6Size, valueSize, maxSize, d.tableOpts()...)
+	if err != nil {
+		return nil, err
+	}
+	d.tableCli = cli
+	d.tableCli6 = cli6
+	group, err := bpf.NewTableClient(logger, groupName, "hash", groupKeySize, groupValueSize, groupMaxSize, d.tableOpts()...)
+	if err != nil {
+		return nil, err
+	}
+	d.lpmCli = lpm
+	d.lpmCli6 = lpm6
+	d.groupCli = group
+	stat, err := bpf.NewTableClient(logger, statName, "percpu_array", statKeySize, statValueSize, statMaxSize, d.tableOpts()...)
+	if err != nil {
+		return nil, err
+	}
+	iface, err := bpf.NewTableClient(logger, ifaceName, "percpu_hash", ifaceKeySize, cntValueSize, ifaceMaxSize, d.tableOpts()...)
+	if err != nil {
+		return nil, err
+	}
+	cnt, err := bpf.NewTableClient(logger, cntName, "lru_percpu_hash", lpmKeySize, cntValueSize, maxSize, d.tableOpts()...)
+	if err != nil {
+		return nil, err
+	}
+	cnt6, err := bpf.NewTableClient(logger, cntName6, "lru_percpu_hash", lpmKey6Size, cntValueSize, maxSize, d.tableOpts()...)
+	if err != nil {
+		return nil, err
+	}
+	d.statCli = stat
+	d.ifaceCli = iface
+	d.cntCli = cnt
+	d.cntCli6 = cnt6
+	lease, err := bpf.NewTableClient(logger, leaseName, "hash", lpmKeySize, leaseValueSize, maxSize, d.tableOpts()...)
+	if err != nil {
+		return nil, err
+	}
+	lease6, err := bpf.NewTableClient(logger, leaseName6, "hash", lpmKey6Size, leaseValueSize, maxSize, d.tableOpts()...)
+	if err != nil {
+		return nil, err
+	}
+	d.leaseCli = lease
+	d.leaseCli6 = lease6
+	acl, err := bpf.NewTableClient(logger, aclName, "array", keySize, aclRuleSize, aclMaxRules*2, d.tableOpts()...)
+	if err != nil {
+		return nil, err
+	}
+	aclCfg, err := bpf.NewTableClient(logger, aclCfgName, "array", keySize, aclCfgSize, 1, d.tableOpts()...)
+	if err != nil {
+		return nil, err
+	}
+	aclCnt, err := bpf.NewTableClient(logger, aclCntName, "percpu_hash", keySize, cntValueSize, aclCntMaxKey, d.tableOpts()...)
+	if err != nil {
+		return nil, err
+	}
+	d.aclCli = acl
+	d.aclCfgCli = aclCfg
+	d.aclCntCli = aclCnt
+	block, err := bpf.NewTableClient(logger, blockName, "lpm_trie", lpmKeySize, blockValueSize, maxSize, d.tableOpts()...)
+	if err != nil {
+		return nil, err
+	}
+	block6, err := bpf.NewTableClient(logger, blockName6, "lpm_trie", lpmKey6Size, blockValueSize, maxSize, d.tableOpts()...)
+	if err != nil {
+		return nil, err
+	}
+	src, err := bpf.NewTableClient(logger, srcName, "lru_percpu_hash", srcKeySize, srcValueSize, srcMaxSize, d.tableOpts()...)
+	if err != nil {
+		return nil, err
+	}
+	srcCfg, err := bpf.NewTableClient(logger, srcCfgName, "array", keySize, keySize, 1, d.tableOpts()...)
+	if err != nil {
+		return nil, err
+	}
+	d.blockCli = block
+	d.blockCli6 = block6
+	d.srcCli = src
+	d.srcCfgCli = srcCfg
+	policer, err := bpf.NewTableClient(logger, policerName, "hash", lpmKeySize, policerValueSize, maxSize, d.tableOpts()...)
+	if err != nil {
+		return nil, err
+	}
+	policer6, err := bpf.NewTableClient(logger, policerName6, "hash", lpmKey6Size, policerValueSize, maxSize, d.tableOpts()...)
+	if err != nil {
+		return nil, err
+	}
+	d.polCli = policer
+	d.polCli6 = policer6
+	tun, err := bpf.NewTableClient(logger, tunnelName, "hash", tunnelKeySize, tunnelValueSize, tunnelMaxSize, d.tableOpts()...)
+	if err != nil {
+		return nil, err
+	}
+	decap, err := bpf.NewTableClient(logger, decapName, "hash", decapKeySize, cntValueSize, decapMaxSize, d.tableOpts()...)
+	if err != nil {
+		return nil, err
+	}
+	d.tunCli = tun
+	d.decapCli = decap
+	pbr, err := bpf.NewTableClient(logger, policyName, "array", keySize, policyRuleSize, policyMaxRules*2, d.tableOpts()...)
+	if err != nil {
+		return nil, err
+	}
+	pbrCfg, err := bpf.NewTableClient(logger, policyCfgName, "array", keySize, policyCfgSize, 1, d.tableOpts()...)
+	if err != nil {
+		return nil, err
+	}
+	pbrTbl, err := bpf.NewTableClient(logger, policyTableName, "lpm_trie", policyTableKeySize, valueSize, maxSize, d.tableOpts()...)
+	if err != nil {
+		return nil, err
+	}
+	d.pbrCli = pbr
+	d.pbrCfgCli = pbrCfg
+	d.pbrTblCli = pbrTbl
+	return d, nil
 }
 
-//设置转发表
+func (d *fwdCli) tableOpts() []bpf.TableOption {
+	var opts = []bpf.TableOption{bpf.WithBackend(d.backend)}
+	if d.observer != nil {
+		opts = append(opts, bpf.WithObserver(d.observer))
+	}
+	return opts
+}
+
+//设置转发表, 按目的地址族写入IPv4或IPv6转发表
+//dstIp       主机地址或CIDR前缀 eg: 10.1.1.1 10.1.0.0/16
 //ifaceIndx   网络设备标示，表示从哪张设备转发
 //srcmac	  源MAC
 //dstmac	  目的MAC
 func (d *fwdCli) UptFwd(ctx context.Context, dstIp string, ifaceIndex uint32, srcmac string, dstmac string) error {
 	//1. 参数检查
-	ip, err := d.checkip(dstIp)
+	ip, err := d.checkkey(dstIp)
 	if err != nil {
 		return err
 	}
@@ -68,8 +370,12 @@ func (d *fwdCli) UptFwd(ctx context.Context, dstIp string, ifaceIndex uint32, sr
 	if err != nil {
 		return err
 	}
+	ifaceIndex, err = d.iface(ctx, ifaceIndex, "")
+	if err != nil {
+		return err
+	}
 
-	k, v := d.kv(ip, ifaceIndex, src, dst)
+	k, v := d.kv(ip, ifaceIndex, src, dst, 0)
 
 	err = d.update(ctx, k, v)
 	if err != nil {
@@ -79,66 +385,468 @@ func (d *fwdCli) UptFwd(ctx context.Context, dstIp string, ifaceIndex uint32, sr
 	return nil
 }
 
+//批量设置转发表, 返回结果与elems一一对应
+func (d *fwdCli) UptFwdBatch(ctx context.Context, elems []*FwdElem) []error {
+	d.groupMu.Lock()
+	defer d.groupMu.Unlock()
+
+	var (
+		errs = make([]error, len(elems))
+		idx  = make([]int, 0, len(elems))
+		kvs  = make([]*bpf.KV, 0, len(elems))
+		pols = make(map[int][]byte)
+	)
+	for i := range elems {
+		ip, err := d.checkkey(d.prefix(elems[i]))
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		act, err := d.action(elems[i].Action)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		if elems[i].VlanIn > vlanMax || elems[i].VlanOut > vlanMax || ((act > 0 || elems[i].Tunnel > 0) && elems[i].VlanOut > 0) {
+			errs[i] = fmt.Errorf("vlan %d/%d: %w", elems[i].VlanIn, elems[i].VlanOut, ErrFwdVlan)
+			continue
+		}
+		if elems[i].Table > 0 && (elems[i].Policer.enabled() || elems[i].Deadline > 0) {
+			errs[i] = fmt.Errorf("table %d: %w", elems[i].Table, ErrPolicyTable)
+			continue
+		}
+		if elems[i].Policer.enabled() {
+			if act > 0 {
+				errs[i] = fmt.Errorf("policer requires redirect: %w", ErrPolicer)
+				continue
+			}
+			pols[i], err = d.policerValue(elems[i].Policer)
+			if err != nil {
+				errs[i] = err
+				continue
+			}
+		}
+		if act > 0 {
+			k, v := d.kv(ip, 0, make([]byte, 6), make([]byte, 6), 0)
+			v[actionOff] = act
+			idx = append(idx, i)
+			kvs = append(kvs, &bpf.KV{Key: k, Value: v})
+			continue
+		}
+		if elems[i].Tunnel > 0 {
+			k, v, err := d.tunnelKv(ctx, ip, elems[i])
+			if err != nil {
+				errs[i] = err
+				continue
+			}
+			idx = append(idx, i)
+			kvs = append(kvs, &bpf.KV{Key: k, Value: v})
+			continue
+		}
+		if elems[i].Group > 0 {
+			_, err = d.lookupGroup(ctx, elems[i].Group)
+			if err != nil {
+				errs[i] = err
+				continue
+			}
+			k, v := d.kv(ip, 0, make([]byte, 6), make([]byte, 6), elems[i].Group)
+			idx = append(idx, i)
+			kvs = append(kvs, &bpf.KV{Key: k, Value: v})
+			continue
+		}
+		src, err := d.checkmac(elems[i].SrcMac)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		dst, err := d.checkmac(elems[i].DstMac)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		iface, err := d.iface(ctx, elems[i].Iface, elems[i].IfName)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		k, v := d.kv(ip, iface, src, dst, 0)
+		idx = append(idx, i)
+		kvs = append(kvs, &bpf.KV{Key: k, Value: v})
+	}
+	for j := range kvs {
+		d.vlan(kvs[j].Value, elems[idx[j]].VlanIn, elems[idx[j]].VlanOut)
+	}
+	//策略表项转换key, 失败的表项不写入
+	var (
+		tidx = make([]int, 0, len(idx))
+		tkvs = make([]*bpf.KV, 0, len(kvs))
+	)
+	for j := range kvs {
+		if t := elems[idx[j]].Table; t > 0 {
+			var k, err = d.policyKey(t, kvs[j].Key)
+			if err != nil {
+				errs[idx[j]] = err
+				continue
+			}
+			kvs[j].Key = k
+		}
+		tidx = append(tidx, idx[j])
+		tkvs = append(tkvs, kvs[j])
+	}
+	idx, kvs = tidx, tkvs
+	//令牌桶先于表项写入, XDP查到限速标记时令牌桶已存在
+	var (
+		pidx = make([]int, 0, len(pols))
+		pkvs = make([]*bpf.KV, 0, len(pols))
+	)
+	for j := range kvs {
+		if pv, ok := pols[idx[j]]; ok {
+			kvs[j].Value[policerOff] = 1
+			pidx = append(pidx, j)
+			pkvs = append(pkvs, &bpf.KV{Key: kvs[j].Key, Value: pv})
+		}
+	}
+	var failed = make(map[int]bool)
+	for k, err := range d.setPolicers(ctx, pkvs) {
+		if err != nil {
+			errs[idx[pidx[k]]] = err
+			failed[pidx[k]] = true
+		}
+	}
+	if len(failed) > 0 {
+		var (
+			nidx = make([]int, 0, len(idx))
+			nkvs = make([]*bpf.KV, 0, len(kvs))
+		)
+		for j := range kvs {
+			if !failed[j] {
+				nidx = append(nidx, idx[j])
+				nkvs = append(nkvs, kvs[j])
+			}
+		}
+		idx, kvs = nidx, nkvs
+	}
+	var rr = d.updateBatch(ctx, kvs)
+	for i := range rr {
+		errs[idx[i]] = rr[i]
+	}
+	return errs
+}
+
+//批量删除转发表, 返回结果与dstIps一一对应
+func (d *fwdCli) DelFwdBatch(ctx context.Context, dstIps []string) []error {
+	var (
+		errs = make([]error, len(dstIps))
+		idx  = make([]int, 0, len(dstIps))
+		keys = make([][]byte, 0, len(dstIps))
+	)
+	for i := range dstIps {
+		ip, err := d.checkkey(dstIps[i])
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		idx = append(idx, i)
+		keys = append(keys, ip)
+	}
+	var rr = d.deleteBatch(ctx, keys)
+	for i := range rr {
+		if errors.Is(rr[i], bpf.ErrKeyNotExist) {
+			rr[i] = ErrFwdNotExist
+		}
+		errs[idx[i]] = rr[i]
+	}
+	return errs
+}
+
 func (d *fwdCli) DelFwd(ctx context.Context, dstIp string) error {
-	ip, err := d.checkip(dstIp)
+	ip, err := d.checkkey(dstIp)
 	if err != nil {
 		return err
 	}
 	return d.delete(ctx, ip)
 }
 
+//删除策略表项
+func (d *fwdCli) DelFwdTable(ctx context.Context, table uint32, dstIp string) error {
+	ip, err := d.checkkey(dstIp)
+	if err != nil {
+		return err
+	}
+	k, err := d.policyKey(table, ip)
+	if err != nil {
+		return err
+	}
+	return d.delete(ctx, k)
+}
+
+//设置黑洞路由或交由内核, redirect需通过UptFwd等指定下一跳
+func (d *fwdCli) UptFwdAction(ctx context.Context, dstIp string, action string) error {
+	if len(action) <= 0 || action == ActionRedirect {
+		return ErrFwdAction
+	}
+	return d.UptFwdBatch(ctx, []*FwdElem{{Ip: dstIp, Action: action}})[0]
+}
+
+//删除XDP学习的主机表项, 静态表项不受影响, 返回已删除的目的地址
+func (d *fwdCli) FlushLearned(ctx context.Context) ([]string, error) {
+	var ips = make([]string, 0, 2)
+	for _, t := range []bpf.ITable{d.tableCli, d.tableCli6} {
+		var elems, err = d.queryTable(ctx, t)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range elems {
+			if e.Origin == OriginLearned {
+				ips = append(ips, e.Ip)
+			}
+		}
+	}
+	if len(ips) <= 0 {
+		return ips, nil
+	}
+	var (
+		r    = make([]string, 0, len(ips))
+		errs = d.DelFwdBatch(ctx, ips)
+	)
+	for i := range errs {
+		switch {
+		case errs[i] == nil:
+			r = append(r, ips[i])
+		case errors.Is(errs[i], ErrFwdNotExist):
+			//已被LRU淘汰
+		default:
+			return r, errs[i]
+		}
+	}
+	return r, nil
+}
+
+//创建转发面依赖的表, 返回表名供XDP程序复用
+func (d *fwdCli) Tables(ctx context.Context) ([]string, error) {
+	var err = d.prepare(ctx, d.tableCli)
+	if err != nil {
+		return nil, err
+	}
+	err = d.prepare(ctx, d.tableCli6)
+	if err != nil {
+		return nil, err
+	}
+	err = d.prepare(ctx, d.lpmCli)
+	if err != nil {
+		return nil, err
+	}
+	err = d.prepare(ctx, d.lpmCli6)
+	if err != nil {
+		return nil, err
+	}
+	err = d.prepare(ctx, d.groupCli)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range []bpf.ITable{d.statCli, d.ifaceCli, d.cntCli, d.cntCli6, d.aclCli, d.aclCfgCli, d.aclCntCli, d.blockCli, d.blockCli6, d.srcCli, d.srcCfgCli, d.polCli, d.polCli6, d.tunCli, d.decapCli, d.pbrCli, d.pbrCfgCli, d.pbrTblCli} {
+		err = d.prepare(ctx, t)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return []string{name, name6, lpmName, lpmName6, groupName, statName, ifaceName, cntName, cntName6, aclName, aclCfgName, aclCntName, blockName, blockName6, srcName, srcCfgName, policerName, policerName6, tunnelName, decapName, policyName, policyCfgName, policyTableName}, nil
+}
+
+//返回转发表及各表项的转发计数、租约、限速
 func (d *fwdCli) QryFwd(ctx context.Context) ([]*FwdElem, error) {
+	var r, err = d.query(ctx)
+	if err != nil {
+		return r, err
+	}
+	err = d.counters(ctx, r)
+	if err != nil {
+		return r, err
+	}
+	err = d.leases(ctx, r)
+	if err != nil {
+		return r, err
+	}
+	err = d.policers(ctx, r)
+	if err != nil {
+		return r, err
+	}
+	d.ifnames(ctx, r)
+	return r, nil
+}
+
+//仅遍历转发表, 不读取计数等附加表, 供周期比对使用
+func (d *fwdCli) ScanFwd(ctx context.Context) ([]*FwdElem, error) {
 	return d.query(ctx)
 }
 
-func (i *fwdCli) update(ctx context.Context, key []byte, value []byte) error {
+//按key长度选择IPv4或IPv6, 主机或前缀转发表
+func (i *fwdCli) table(key []byte) (bpf.ITable, error) {
+	switch len(key) {
+	case i.keySize:
+		return i.tableCli, nil
+	case i.key6Size:
+		return i.tableCli6, nil
+	case lpmKeySize:
+		return i.lpmCli, nil
+	case lpmKey6Size:
+		return i.lpmCli6, nil
+	case policyTableKeySize:
+		return i.pbrTblCli, nil
+	default:
+		return nil, errors.New("key size is invalid")
+	}
+}
+
+func (i *fwdCli) prepare(ctx context.Context, t bpf.ITable) error {
 	var err error
-	if !i.tableCli.ExistTable(ctx) {
-		err = i.tableCli.CreateTable(ctx)
+	if !t.ExistTable(ctx) {
+		err = t.CreateTable(ctx)
 	}
+	return err
+}
+
+func (i *fwdCli) update(ctx context.Context, key []byte, value []byte) error {
+	var t, err = i.table(key)
 	if err != nil {
 		return err
 	}
-	if len(key) != i.keySize {
-		return errors.New("key size is invalid")
+	err = i.prepare(ctx, t)
+	if err != nil {
+		return err
 	}
 	if len(value) != i.valueSize {
 		return errors.New("value size is invalid")
 	}
-	err = i.tableCli.UpdateTable(ctx, key, value)
+	err = t.UpdateTable(ctx, key, value)
 	if err != nil {
 		return err
 	}
+	if value[policerOff] == 0 {
+		i.clearPolicers(ctx, [][]byte{key})
+	}
 	return nil
 }
 
 func (i *fwdCli) delete(ctx context.Context, key []byte) error {
-	var err error
-	if !i.tableCli.ExistTable(ctx) {
-		err = i.tableCli.CreateTable(ctx)
+	var t, err = i.table(key)
+	if err != nil {
+		return err
 	}
+	err = i.prepare(ctx, t)
 	if err != nil {
 		return err
 	}
-	if len(key) != i.keySize {
-		return errors.New("key size is invalid")
+	err = t.DeleteTable(ctx, key)
+	if errors.Is(err, bpf.ErrKeyNotExist) {
+		return ErrFwdNotExist
 	}
-	err = i.tableCli.DeleteTable(ctx, key)
 	if err != nil {
 		return err
 	}
+	i.clearCounters(ctx, [][]byte{key})
+	i.clearLeases(ctx, [][]byte{key})
+	i.clearPolicers(ctx, [][]byte{key})
 	return nil
 }
 
+//按地址族分组后批量更新
+func (i *fwdCli) updateBatch(ctx context.Context, kvs []*bpf.KV) []error {
+	var (
+		errs   = make([]error, len(kvs))
+		groups = make(map[bpf.ITable][]int)
+	)
+	for j := range kvs {
+		var t, err = i.table(kvs[j].Key)
+		if err != nil {
+			errs[j] = err
+			continue
+		}
+		groups[t] = append(groups[t], j)
+	}
+	for t, idx := range groups {
+		var err = i.prepare(ctx, t)
+		var sub = make([]*bpf.KV, 0, len(idx))
+		for _, j := range idx {
+			errs[j] = err
+			sub = append(sub, kvs[j])
+		}
+		if err != nil {
+			continue
+		}
+		var rr = t.UpdateBatchTable(ctx, sub)
+		var plain = make([][]byte, 0, len(rr))
+		for k := range rr {
+			errs[idx[k]] = rr[k]
+			if rr[k] == nil && sub[k].Value[policerOff] == 0 {
+				plain = append(plain, sub[k].Key)
+			}
+		}
+		i.clearPolicers(ctx, plain)
+	}
+	return errs
+}
+
+//按地址族分组后批量删除
+func (i *fwdCli) deleteBatch(ctx context.Context, keys [][]byte) []error {
+	var (
+		errs   = make([]error, len(keys))
+		groups = make(map[bpf.ITable][]int)
+	)
+	for j := range keys {
+		var t, err = i.table(keys[j])
+		if err != nil {
+			errs[j] = err
+			continue
+		}
+		groups[t] = append(groups[t], j)
+	}
+	for t, idx := range groups {
+		var err = i.prepare(ctx, t)
+		var sub = make([][]byte, 0, len(idx))
+		for _, j := range idx {
+			errs[j] = err
+			sub = append(sub, keys[j])
+		}
+		if err != nil {
+			continue
+		}
+		var rr = t.DeleteBatchTable(ctx, sub)
+		var ok = make([][]byte, 0, len(rr))
+		for k := range rr {
+			errs[idx[k]] = rr[k]
+			if rr[k] == nil {
+				ok = append(ok, sub[k])
+			}
+		}
+		i.clearCounters(ctx, ok)
+		i.clearLeases(ctx, ok)
+		i.clearPolicers(ctx, ok)
+	}
+	return errs
+}
+
 func (i *fwdCli) query(ctx context.Context) ([]*FwdElem, error) {
 	var r = make([]*FwdElem, 0, 2)
-	if i.tableCli == nil {
+	for _, t := range []bpf.ITable{i.tableCli, i.tableCli6, i.lpmCli, i.lpmCli6, i.pbrTblCli} {
+		var rr, err = i.queryTable(ctx, t)
+		if err != nil {
+			return r, err
+		}
+		r = append(r, rr...)
+	}
+	return r, nil
+}
+
+func (i *fwdCli) queryTable(ctx context.Context, t bpf.ITable) ([]*FwdElem, error) {
+	var r = make([]*FwdElem, 0, 2)
+	if t == nil {
 		return r, nil
 	}
-	if !i.tableCli.ExistTable(ctx) {
+	if !t.ExistTable(ctx) {
 		return r, nil
 	}
-	var kv, err = i.tableCli.QueryTable(ctx)
+	var kv, err = t.QueryTable(ctx)
 	if err != nil {
 		return r, err
 	}
@@ -148,21 +856,53 @@ func (i *fwdCli) query(ctx context.Context) ([]*FwdElem, error) {
 			vv = kv[i].Value
 			rr = new(FwdElem)
 		)
-		rr.Ip = net.IPv4(kk[0], kk[1], kk[2], kk[3]).String()
+		switch len(kk) {
+		case lpmKeySize, lpmKey6Size:
+			rr.Prefix |= int(kk[0])
+			rr.Prefix |= int(kk[1]) << 8
+			rr.Prefix |= int(kk[2]) << 16
+			rr.Prefix |= int(kk[3]) << 24
+			rr.Ip = net.IP(kk[4:]).String()
+		case policyTableKeySize:
+			rr.Prefix = (int(kk[0]) | int(kk[1])<<8 | int(kk[2])<<16 | int(kk[3])<<24) - 32
+			rr.Table = uint32(kk[4]) | uint32(kk[5])<<8 | uint32(kk[6])<<16 | uint32(kk[7])<<24
+			rr.Ip = net.IP(kk[8:]).String()
+		default:
+			rr.Prefix = len(kk) * 8
+			rr.Ip = net.IP(kk).String()
+		}
 		rr.Iface |= uint32(vv[0])
 		rr.Iface |= uint32(vv[1]) << 8
 		rr.Iface |= uint32(vv[2]) << 16
 		rr.Iface |= uint32(vv[3]) << 24
 		rr.SrcMac = fmt.Sprintf("%02x:%02x:%02x:%02x:%02x:%02x", vv[4], vv[5], vv[6], vv[7], vv[8], vv[9])
 		rr.DstMac = fmt.Sprintf("%02x:%02x:%02x:%02x:%02x:%02x", vv[0xa], vv[0xb], vv[0xc], vv[0xd], vv[0xe], vv[0xf])
+		rr.Group |= uint32(vv[0x10])
+		rr.Group |= uint32(vv[0x11]) << 8
+		rr.Group |= uint32(vv[0x12]) << 16
+		rr.Group |= uint32(vv[0x13]) << 24
+		rr.Action = ActionRedirect
+		if int(vv[actionOff]) < len(actions) {
+			rr.Action = actions[vv[actionOff]]
+		}
+		rr.Origin = OriginStatic
+		if vv[originOff] == 1 {
+			rr.Origin = OriginLearned
+		}
+		rr.VlanIn = uint16(vv[vlanOff]) | uint16(vv[vlanOff+1])<<8
+		rr.VlanOut = uint16(vv[vlanOff+2]) | uint16(vv[vlanOff+3])<<8
+		rr.Tunnel |= uint32(vv[tunnelOff])
+		rr.Tunnel |= uint32(vv[tunnelOff+1]) << 8
+		rr.Tunnel |= uint32(vv[tunnelOff+2]) << 16
+		rr.Tunnel |= uint32(vv[tunnelOff+3]) << 24
 
 		r = append(r, rr)
 	}
 	return r, nil
 }
 
-func (d *fwdCli) kv(ip []byte, ifaceIndex uint32, src []byte, dst []byte) ([]byte, []byte) {
-	var k = make([]byte, d.keySize)
+func (d *fwdCli) kv(ip []byte, ifaceIndex uint32, src []byte, dst []byte, group uint32) ([]byte, []byte) {
+	var k = make([]byte, len(ip))
 	var v = make([]byte, d.valueSize)
 
 	copy(k, ip)
@@ -173,10 +913,32 @@ func (d *fwdCli) kv(ip []byte, ifaceIndex uint32, src []byte, dst []byte) ([]byt
 	v[3] = byte(ifaceIndex >> 24)
 	copy(v[4:10], src)
 	copy(v[10:16], dst)
+	v[16] = byte(group)
+	v[17] = byte(group >> 8)
+	v[18] = byte(group >> 16)
+	v[19] = byte(group >> 24)
+	//前缀长度, XDP据此定位表项计数
+	switch len(ip) {
+	case keySize:
+		v[20] = 32
+	case key6Size:
+		v[20] = 128
+	default:
+		v[20] = ip[0]
+	}
+	//控制面下发的表项均为静态, v[originOff]保持0
 
 	return k, v
 }
 
+//入出VLAN 主机字节序
+func (d *fwdCli) vlan(v []byte, in uint16, out uint16) {
+	v[vlanOff] = byte(in)
+	v[vlanOff+1] = byte(in >> 8)
+	v[vlanOff+2] = byte(out)
+	v[vlanOff+3] = byte(out >> 8)
+}
+
 func (d *fwdCli) checkip(ip string) ([]byte, error) {
 	netip := net.ParseIP(ip)
 	if netip == nil {
@@ -184,7 +946,10 @@ func (d *fwdCli) checkip(ip string) ([]byte, error) {
 	}
 	addr := netip.To4()
 	if addr == nil {
-		return nil, errors.New("invalid ip format")
+		//IPv6
+		b := make([]byte, 16)
+		copy(b, netip.To16())
+		return b, nil
 	}
 	b := make([]byte, 4)
 
@@ -196,6 +961,54 @@ func (d *fwdCli) checkip(ip string) ([]byte, error) {
 	return b, nil
 }
 
+//主机路由key为目的地址, 前缀路由key为 prefixlen + 网络地址
+//eg: 10.1.1.1 10.1.0.0/16 2001:db8::/32
+func (d *fwdCli) checkkey(dst string) ([]byte, error) {
+	if !strings.Contains(dst, "/") {
+		return d.checkip(dst)
+	}
+	var _, ipnet, err = net.ParseCIDR(dst)
+	if err != nil {
+		return nil, errors.New("invalid prefix format")
+	}
+	ip, err := d.checkip(ipnet.IP.String())
+	if err != nil {
+		return nil, err
+	}
+	var ones, bits = ipnet.Mask.Size()
+	if ones == bits {
+		return ip, nil
+	}
+	var k = make([]byte, 4+len(ip))
+	k[0] = byte(ones)
+	k[1] = byte(ones >> 8)
+	k[2] = byte(ones >> 16)
+	k[3] = byte(ones >> 24)
+	copy(k[4:], ip)
+	return k, nil
+}
+
+//FwdElem 前缀长度单独指定时拼接为CIDR
+func (d *fwdCli) prefix(e *FwdElem) string {
+	if e.Prefix <= 0 || strings.Contains(e.Ip, "/") {
+		return e.Ip
+	}
+	return fmt.Sprintf("%s/%d", e.Ip, e.Prefix)
+}
+
+//动作名转换为fwd.bpf.c中的取值
+func (d *fwdCli) action(name string) (byte, error) {
+	if len(name) <= 0 {
+		return 0, nil
+	}
+	for i := range actions {
+		if actions[i] == name {
+			return byte(i), nil
+		}
+	}
+	return 0, fmt.Errorf("%s: %w", name, ErrFwdAction)
+}
+
 //eg: 08:00:27:f3:81:0e
 func (d *fwdCli) checkmac(mac string) ([]byte, error) {
 	var hw, err = net.ParseMAC(mac)
@@ -2,10 +2,13 @@ package fwd
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net"
 	"testing"
 
 	"github.com/advancevillage/3rd/logx"
+	"github.com/advancevillage/fwd/pkg/bpf"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -56,6 +59,58 @@ var ipTest = map[string]struct {
 		dstIp: "127.0.0.1",
 		exp:   []byte{0x7f, 0x00, 0x00, 0x01},
 	},
+	"case2": {
+		dstIp: "2001:db8::1",
+		exp:   []byte{0x20, 0x01, 0x0d, 0xb8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01},
+	},
+	"case3": {
+		dstIp: "2001:db8::zz",
+		err:   errors.New("invalid ip format"),
+	},
+}
+
+var keyTest = map[string]struct {
+	dst string
+	exp []byte
+	err error
+}{
+	"case-host": {
+		dst: "10.1.1.1",
+		exp: []byte{0x0a, 0x01, 0x01, 0x01},
+	},
+	"case-host-prefix": {
+		dst: "10.1.1.1/32",
+		exp: []byte{0x0a, 0x01, 0x01, 0x01},
+	},
+	"case-prefix": {
+		dst: "10.1.1.1/16",
+		exp: []byte{0x10, 0x00, 0x00, 0x00, 0x0a, 0x01, 0x00, 0x00},
+	},
+	"case-default": {
+		dst: "0.0.0.0/0",
+		exp: []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	},
+	"case-prefix6": {
+		dst: "2001:db8::/32",
+		exp: []byte{0x20, 0x00, 0x00, 0x00, 0x20, 0x01, 0x0d, 0xb8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	},
+	"case-invalid": {
+		dst: "10.1.1.1/33",
+		err: errors.New("invalid prefix format"),
+	},
+}
+
+func Test_key_check(t *testing.T) {
+	var c = &fwdCli{}
+
+	for n, p := range keyTest {
+		f := func(t *testing.T) {
+			var act, err = c.checkkey(p.dst)
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, p.exp, act)
+		}
+		t.Run(n, f)
+	}
 }
 
 func Test_ip_check(t *testing.T) {
@@ -79,6 +134,7 @@ var kvTest = map[string]struct {
 	src   []byte
 	dst   []byte
 	iface uint32
+	group uint32
 	k     []byte
 	v     []byte
 }{
@@ -88,7 +144,15 @@ var kvTest = map[string]struct {
 		dst:   []byte{0xf8, 0xf0, 0x27, 0xf3, 0x81, 0x0e},
 		iface: 4,
 		k:     []byte{0x01, 0x00, 0x00, 0x7f},
-		v:     []byte{0x04, 0x00, 0x00, 0x00, 0x08, 0x00, 0x27, 0xf3, 0x81, 0x0e, 0xf8, 0xf0, 0x27, 0xf3, 0x81, 0x0e},
+		v:     []byte{0x04, 0x00, 0x00, 0x00, 0x08, 0x00, 0x27, 0xf3, 0x81, 0x0e, 0xf8, 0xf0, 0x27, 0xf3, 0x81, 0x0e, 0x00, 0x00, 0x00, 0x00, 0x20, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	},
+	"case-group": {
+		ip:    []byte{0x01, 0x00, 0x00, 0x7f},
+		src:   []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		dst:   []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		group: 0x0102,
+		k:     []byte{0x01, 0x00, 0x00, 0x7f},
+		v:     []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x01, 0x00, 0x00, 0x20, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
 	},
 }
 
@@ -100,7 +164,7 @@ func Test_kv_check(t *testing.T) {
 
 	for n, p := range kvTest {
 		f := func(t *testing.T) {
-			var k, v = c.kv(p.ip, p.iface, p.src, p.dst)
+			var k, v = c.kv(p.ip, p.iface, p.src, p.dst, p.group)
 			assert.Equal(t, p.k, k)
 			assert.Equal(t, p.v, v)
 		}
@@ -130,7 +194,7 @@ func Test_fwd_update(t *testing.T) {
 		t.Fatal(err)
 		return
 	}
-	c, err := NewFwdClient(logger)
+	c, err := NewFwdClient(logger, WithResolver(&fakeSource{}))
 	if err != nil {
 		t.Fatal(err)
 		return
@@ -154,3 +218,239 @@ func Test_fwd_update(t *testing.T) {
 	}
 
 }
+
+var batchTest = map[string]struct {
+	elems []*FwdElem
+	errs  []bool
+}{
+	"case1": {
+		elems: []*FwdElem{
+			{Ip: "192.168.1.104", Prefix: 32, Iface: 4, IfName: "eth4", SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e", Action: ActionRedirect, Origin: OriginStatic},
+			{Ip: "192.168.1.300", Prefix: 32, Iface: 4, IfName: "eth4", SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e", Action: ActionRedirect, Origin: OriginStatic},
+			{Ip: "192.168.1.105", Prefix: 32, Iface: 4, IfName: "eth4", SrcMac: "08:00:27:f3:81", DstMac: "f8:ff:27:f3:81:0e", Action: ActionRedirect, Origin: OriginStatic},
+			{Ip: "192.168.1.106", Prefix: 32, Iface: 4, IfName: "eth4", SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e", Action: ActionRedirect, Origin: OriginStatic},
+			{Ip: "2001:db8::6", Prefix: 128, Iface: 4, IfName: "eth4", SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e", Action: ActionRedirect, Origin: OriginStatic},
+			{Ip: "10.1.0.0", Prefix: 16, Iface: 4, IfName: "eth4", SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e", Action: ActionRedirect, Origin: OriginStatic},
+			{Ip: "2001:db8:1::", Prefix: 48, Iface: 4, IfName: "eth4", SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e", Action: ActionRedirect, Origin: OriginStatic},
+		},
+		errs: []bool{false, true, true, false, false, false, false},
+	},
+}
+
+func Test_fwd_batch(t *testing.T) {
+	logger, err := logx.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	c, err := NewFwdClient(logger, WithBackend(bpf.BackendSyscall), WithResolver(&fakeSource{}))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	for n, p := range batchTest {
+		f := func(t *testing.T) {
+			var errs = c.UptFwdBatch(context.TODO(), p.elems)
+			var ips = make([]string, 0, len(p.elems))
+			for i := range errs {
+				assert.Equal(t, p.errs[i], errs[i] != nil)
+				ips = append(ips, fmt.Sprintf("%s/%d", p.elems[i].Ip, p.elems[i].Prefix))
+			}
+			r, err := c.QryFwd(context.TODO())
+			if err != nil {
+				t.Fatal(err)
+				return
+			}
+			for i := range p.elems {
+				var found = false
+				for j := range r {
+					if r[j].Ip == p.elems[i].Ip {
+						found = true
+						assert.Equal(t, p.elems[i], r[j])
+					}
+				}
+				assert.Equal(t, !p.errs[i], found)
+			}
+			errs = c.DelFwdBatch(context.TODO(), ips)
+			for i := range errs {
+				assert.Equal(t, p.errs[i], errs[i] != nil)
+			}
+		}
+		t.Run(n, f)
+	}
+}
+
+func Test_fwd_delete(t *testing.T) {
+	logger, err := logx.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	c, err := NewFwdClient(logger, WithBackend(bpf.BackendSyscall), WithResolver(&fakeSource{}))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	err = c.UptFwd(context.TODO(), "192.168.1.107", 4, "08:00:27:f3:81:0e", "f8:ff:27:f3:81:0e")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	assert.Nil(t, c.DelFwd(context.TODO(), "192.168.1.107"))
+	assert.Equal(t, ErrFwdNotExist, c.DelFwd(context.TODO(), "192.168.1.107"))
+	assert.Equal(t, []error{ErrFwdNotExist}, c.DelFwdBatch(context.TODO(), []string{"192.168.1.107"}))
+}
+
+//模拟XDP slow_fwd回写的学习表项
+func Test_fwd_flush_learned(t *testing.T) {
+	logger, err := logx.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	c, err := NewFwdClient(logger, WithBackend(bpf.BackendSyscall), WithResolver(&fakeSource{}))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var (
+		ctx     = context.TODO()
+		d       = c.(*fwdCli)
+		learned = []string{"10.10.1.1", "2001:db8:a::1"}
+	)
+	src, _ := d.checkmac("08:00:27:f3:81:0e")
+	dst, _ := d.checkmac("f8:ff:27:f3:81:0e")
+	for _, ip := range learned {
+		k, _ := d.checkkey(ip)
+		k, v := d.kv(k, 4, src, dst, 0)
+		v[originOff] = 1
+		assert.Nil(t, d.update(ctx, k, v))
+	}
+	assert.Nil(t, c.UptFwd(ctx, "10.10.1.2", 4, "08:00:27:f3:81:0e", "f8:ff:27:f3:81:0e"))
+
+	r, err := c.QryFwd(ctx)
+	assert.Nil(t, err)
+	assert.Contains(t, r, &FwdElem{Ip: "10.10.1.1", Prefix: 32, Iface: 4, IfName: "eth4", SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e", Action: ActionRedirect, Origin: OriginLearned})
+
+	ips, err := c.FlushLearned(ctx)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, learned, ips)
+	r, err = c.QryFwd(ctx)
+	assert.Nil(t, err)
+	for _, e := range r {
+		assert.NotEqual(t, OriginLearned, e.Origin)
+	}
+	assert.Contains(t, r, &FwdElem{Ip: "10.10.1.2", Prefix: 32, Iface: 4, IfName: "eth4", SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e", Action: ActionRedirect, Origin: OriginStatic})
+	assert.Nil(t, c.DelFwd(ctx, "10.10.1.2"))
+}
+
+var actionTest = map[string]struct {
+	dstIp  string
+	action string
+	exp    *FwdElem
+	err    error
+}{
+	"case-drop": {
+		dstIp:  "10.11.1.1",
+		action: ActionDrop,
+		exp:    &FwdElem{Ip: "10.11.1.1", Prefix: 32, SrcMac: "00:00:00:00:00:00", DstMac: "00:00:00:00:00:00", Action: ActionDrop, Origin: OriginStatic},
+	},
+	"case-pass-prefix": {
+		dstIp:  "fd11::/64",
+		action: ActionPass,
+		exp:    &FwdElem{Ip: "fd11::", Prefix: 64, SrcMac: "00:00:00:00:00:00", DstMac: "00:00:00:00:00:00", Action: ActionPass, Origin: OriginStatic},
+	},
+	"case-redirect": {
+		dstIp:  "10.11.1.2",
+		action: ActionRedirect,
+		err:    ErrFwdAction,
+	},
+	"case-invalid": {
+		dstIp:  "10.11.1.3",
+		action: "reject",
+		err:    ErrFwdAction,
+	},
+}
+
+func Test_fwd_action(t *testing.T) {
+	logger, err := logx.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	c, err := NewFwdClient(logger, WithBackend(bpf.BackendSyscall), WithResolver(&fakeSource{}))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var ctx = context.TODO()
+	for n, p := range actionTest {
+		f := func(t *testing.T) {
+			var err = c.UptFwdAction(ctx, p.dstIp, p.action)
+			assert.ErrorIs(t, err, p.err)
+			if err != nil {
+				return
+			}
+			r, err := c.QryFwd(ctx)
+			assert.Nil(t, err)
+			assert.Contains(t, r, p.exp)
+			assert.Nil(t, c.DelFwd(ctx, p.dstIp))
+		}
+		t.Run(n, f)
+	}
+}
+
+var vlanTest = map[string]struct {
+	elem *FwdElem
+	err  error
+}{
+	"case-push": {
+		elem: &FwdElem{Ip: "10.12.1.1", Prefix: 32, Iface: 4, IfName: "eth4", SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e", Action: ActionRedirect, Origin: OriginStatic, VlanOut: 100},
+	},
+	"case-pop": {
+		elem: &FwdElem{Ip: "10.12.0.0", Prefix: 16, Iface: 4, IfName: "eth4", SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e", Action: ActionRedirect, Origin: OriginStatic, VlanIn: 100},
+	},
+	"case-rewrite": {
+		elem: &FwdElem{Ip: "2001:db8:c::1", Prefix: 128, Iface: 4, IfName: "eth4", SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e", Action: ActionRedirect, Origin: OriginStatic, VlanIn: 100, VlanOut: 4094},
+	},
+	"case-drop": {
+		elem: &FwdElem{Ip: "10.12.1.2", Prefix: 32, SrcMac: "00:00:00:00:00:00", DstMac: "00:00:00:00:00:00", Action: ActionDrop, Origin: OriginStatic, VlanIn: 200},
+	},
+	"case-drop-out": {
+		elem: &FwdElem{Ip: "10.12.1.3", Prefix: 32, Action: ActionDrop, VlanOut: 200},
+		err:  ErrFwdVlan,
+	},
+	"case-invalid": {
+		elem: &FwdElem{Ip: "10.12.1.4", Prefix: 32, Iface: 4, SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e", VlanIn: 4095},
+		err:  ErrFwdVlan,
+	},
+}
+
+func Test_fwd_vlan(t *testing.T) {
+	logger, err := logx.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	c, err := NewFwdClient(logger, WithBackend(bpf.BackendSyscall), WithResolver(&fakeSource{}))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var ctx = context.TODO()
+	for n, p := range vlanTest {
+		f := func(t *testing.T) {
+			var errs = c.UptFwdBatch(ctx, []*FwdElem{p.elem})
+			assert.ErrorIs(t, errs[0], p.err)
+			if errs[0] != nil {
+				return
+			}
+			r, err := c.QryFwd(ctx)
+			assert.Nil(t, err)
+			assert.Contains(t, r, p.elem)
+			assert.Nil(t, c.DelFwd(ctx, fmt.Sprintf("%s/%d", p.elem.Ip, p.elem.Prefix)))
+		}
+		t.Run(n, f)
+	}
+}
@@ -0,0 +1,218 @@
+package fwd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/advancevillage/fwd/pkg/bpf"
+)
+
+var (
+	ErrGroupExist    = errors.New("next hop group already exists")
+	ErrGroupNotExist = errors.New("next hop group does not exist")
+	ErrGroupInUse    = errors.New("next hop group is referenced by forward entries")
+	ErrGroupHops     = errors.New("next hop group size is invalid")
+)
+
+//下一跳组 BPF_MAP_TYPE_HASH
+// key    uint32  组ID, 0保留表示不使用组
+// value  count(4) + pad(4) + nh[groupMaxHops](16)
+var (
+	groupKeySize   = int(0x04)
+	groupMaxHops   = int(0x08)
+	groupHopSize   = int(0x10)
+	groupValueSize = int(0x08 + 0x08*0x10)
+	groupMaxSize   = int(1024)
+	groupName      = "gfwd"
+)
+
+//IfName 出接口名 更新时非空则优先于Iface
+type NextHop struct {
+	Iface  uint32
+	IfName string
+	SrcMac string
+	DstMac string
+}
+
+type GroupElem struct {
+	Group uint32
+	Hops  []*NextHop
+}
+
+//转发表项指向下一跳组, XDP按五元组哈希选择组内下一跳
+func (d *fwdCli) UptFwdGroup(ctx context.Context, dstIp string, group uint32) error {
+	d.groupMu.Lock()
+	defer d.groupMu.Unlock()
+
+	ip, err := d.checkkey(dstIp)
+	if err != nil {
+		return err
+	}
+	_, err = d.lookupGroup(ctx, group)
+	if err != nil {
+		return err
+	}
+	k, v := d.kv(ip, 0, make([]byte, 6), make([]byte, 6), group)
+
+	return d.update(ctx, k, v)
+}
+
+func (d *fwdCli) AddGroup(ctx context.Context, group uint32, hops []*NextHop) error {
+	d.groupMu.Lock()
+	defer d.groupMu.Unlock()
+
+	var _, err = d.lookupGroup(ctx, group)
+	switch {
+	case err == nil:
+		return ErrGroupExist
+	case errors.Is(err, ErrGroupNotExist):
+	default:
+		return err
+	}
+	return d.updateGroup(ctx, group, hops)
+}
+
+func (d *fwdCli) UptGroup(ctx context.Context, group uint32, hops []*NextHop) error {
+	d.groupMu.Lock()
+	defer d.groupMu.Unlock()
+
+	var _, err = d.lookupGroup(ctx, group)
+	if err != nil {
+		return err
+	}
+	return d.updateGroup(ctx, group, hops)
+}
+
+//仍被转发表项或策略引用的组不允许删除
+func (d *fwdCli) DelGroup(ctx context.Context, group uint32) error {
+	d.groupMu.Lock()
+	defer d.groupMu.Unlock()
+
+	var _, err = d.lookupGroup(ctx, group)
+	if err != nil {
+		return err
+	}
+	elems, err := d.query(ctx)
+	if err != nil {
+		return err
+	}
+	for i := range elems {
+		if elems[i].Group == group {
+			return ErrGroupInUse
+		}
+	}
+	policies, err := d.QryPolicy(ctx)
+	if err != nil {
+		return err
+	}
+	for i := range policies {
+		if policies[i].Group == group {
+			return ErrGroupInUse
+		}
+	}
+	err = d.groupCli.DeleteTable(ctx, d.groupKey(group))
+	if errors.Is(err, bpf.ErrKeyNotExist) {
+		return ErrGroupNotExist
+	}
+	return err
+}
+
+func (d *fwdCli) QryGroup(ctx context.Context) ([]*GroupElem, error) {
+	var r = make([]*GroupElem, 0, 2)
+	if !d.groupCli.ExistTable(ctx) {
+		return r, nil
+	}
+	var kv, err = d.groupCli.QueryTable(ctx)
+	if err != nil {
+		return r, err
+	}
+	for i := range kv {
+		var rr = d.groupElem(kv[i].Value)
+		rr.Group |= uint32(kv[i].Key[0])
+		rr.Group |= uint32(kv[i].Key[1]) << 8
+		rr.Group |= uint32(kv[i].Key[2]) << 16
+		rr.Group |= uint32(kv[i].Key[3]) << 24
+		d.hopnames(ctx, rr.Hops)
+		r = append(r, rr)
+	}
+	return r, nil
+}
+
+func (d *fwdCli) lookupGroup(ctx context.Context, group uint32) ([]byte, error) {
+	if group == 0 {
+		return nil, errors.New("invalid group id")
+	}
+	var err = d.prepare(ctx, d.groupCli)
+	if err != nil {
+		return nil, err
+	}
+	v, err := d.groupCli.LookupTable(ctx, d.groupKey(group))
+	if errors.Is(err, bpf.ErrKeyNotExist) {
+		return nil, ErrGroupNotExist
+	}
+	return v, err
+}
+
+func (d *fwdCli) updateGroup(ctx context.Context, group uint32, hops []*NextHop) error {
+	if len(hops) <= 0 || len(hops) > groupMaxHops {
+		return ErrGroupHops
+	}
+	var v = make([]byte, groupValueSize)
+	v[0] = byte(len(hops))
+	for i := range hops {
+		src, err := d.checkmac(hops[i].SrcMac)
+		if err != nil {
+			return err
+		}
+		dst, err := d.checkmac(hops[i].DstMac)
+		if err != nil {
+			return err
+		}
+		iface, err := d.iface(ctx, hops[i].Iface, hops[i].IfName)
+		if err != nil {
+			return err
+		}
+		var off = 0x08 + i*groupHopSize
+		v[off+0] = byte(iface)
+		v[off+1] = byte(iface >> 8)
+		v[off+2] = byte(iface >> 16)
+		v[off+3] = byte(iface >> 24)
+		copy(v[off+4:off+10], src)
+		copy(v[off+10:off+16], dst)
+	}
+	return d.groupCli.UpdateTable(ctx, d.groupKey(group), v)
+}
+
+func (d *fwdCli) groupKey(group uint32) []byte {
+	var k = make([]byte, groupKeySize)
+	k[0] = byte(group)
+	k[1] = byte(group >> 8)
+	k[2] = byte(group >> 16)
+	k[3] = byte(group >> 24)
+	return k
+}
+
+func (d *fwdCli) groupElem(vv []byte) *GroupElem {
+	var (
+		rr    = new(GroupElem)
+		count = int(vv[0])
+	)
+	if count > groupMaxHops {
+		count = groupMaxHops
+	}
+	for i := 0; i < count; i++ {
+		var (
+			off = 0x08 + i*groupHopSize
+			hop = new(NextHop)
+		)
+		hop.Iface |= uint32(vv[off+0])
+		hop.Iface |= uint32(vv[off+1]) << 8
+		hop.Iface |= uint32(vv[off+2]) << 16
+		hop.Iface |= uint32(vv[off+3]) << 24
+		hop.SrcMac = fmt.Sprintf("%02x:%02x:%02x:%02x:%02x:%02x", vv[off+4], vv[off+5], vv[off+6], vv[off+7], vv[off+8], vv[off+9])
+		hop.DstMac = fmt.Sprintf("%02x:%02x:%02x:%02x:%02x:%02x", vv[off+10], vv[off+11], vv[off+12], vv[off+13], vv[off+14], vv[off+15])
+		rr.Hops = append(rr.Hops, hop)
+	}
+	return rr
+}
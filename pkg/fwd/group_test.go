@@ -0,0 +1,117 @@
+package fwd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/advancevillage/3rd/logx"
+	"github.com/advancevillage/fwd/pkg/bpf"
+	"github.com/stretchr/testify/assert"
+)
+
+var groupTest = map[string]struct {
+	group uint32
+	hops  []*NextHop
+	upt   []*NextHop
+	dstIp string
+}{
+	"case1": {
+		group: 7,
+		hops: []*NextHop{
+			{Iface: 4, IfName: "eth4", SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e"},
+			{Iface: 5, IfName: "eth5", SrcMac: "08:00:27:f3:81:0f", DstMac: "f8:ff:27:f3:81:0f"},
+		},
+		upt: []*NextHop{
+			{Iface: 6, IfName: "eth6", SrcMac: "08:00:27:f3:81:10", DstMac: "f8:ff:27:f3:81:10"},
+		},
+		dstIp: "10.2.0.0/16",
+	},
+}
+
+func Test_fwd_group(t *testing.T) {
+	logger, err := logx.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	c, err := NewFwdClient(logger, WithBackend(bpf.BackendSyscall), WithResolver(&fakeSource{}))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var ctx = context.TODO()
+
+	for n, p := range groupTest {
+		f := func(t *testing.T) {
+			//1. 创建组
+			assert.Equal(t, ErrGroupNotExist, c.UptGroup(ctx, p.group, p.hops))
+			assert.Equal(t, ErrGroupNotExist, c.UptFwdGroup(ctx, p.dstIp, p.group))
+			assert.Nil(t, c.AddGroup(ctx, p.group, p.hops))
+			assert.Equal(t, ErrGroupExist, c.AddGroup(ctx, p.group, p.hops))
+			//2. 转发表项指向组
+			assert.Nil(t, c.UptFwdGroup(ctx, p.dstIp, p.group))
+			assert.Equal(t, ErrGroupInUse, c.DelGroup(ctx, p.group))
+			//3. 修改组
+			assert.Nil(t, c.UptGroup(ctx, p.group, p.upt))
+			groups, err := c.QryGroup(ctx)
+			if err != nil {
+				t.Fatal(err)
+				return
+			}
+			assert.Contains(t, groups, &GroupElem{Group: p.group, Hops: p.upt})
+			//4. 删除
+			assert.Nil(t, c.DelFwd(ctx, p.dstIp))
+			assert.Nil(t, c.DelGroup(ctx, p.group))
+			assert.Equal(t, ErrGroupNotExist, c.DelGroup(ctx, p.group))
+		}
+		t.Run(n, f)
+	}
+}
+
+//并发删除组与表项引用组, 组被删除时不应存在引用它的表项
+func Test_fwd_group_race(t *testing.T) {
+	logger, err := logx.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	c, err := NewFwdClient(logger, WithBackend(bpf.BackendSyscall), WithResolver(&fakeSource{}))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var (
+		ctx   = context.TODO()
+		group = uint32(17)
+		hops  = []*NextHop{{Iface: 4, IfName: "eth4", SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e"}}
+	)
+	for i := 0; i < 20; i++ {
+		var (
+			wg  sync.WaitGroup
+			ip  = fmt.Sprintf("10.17.%d.0/24", i)
+			del error
+		)
+		assert.Nil(t, c.AddGroup(ctx, group, hops))
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_ = c.UptFwdGroup(ctx, ip, group)
+		}()
+		go func() {
+			defer wg.Done()
+			del = c.DelGroup(ctx, group)
+		}()
+		wg.Wait()
+		elems, err := c.QryFwd(ctx)
+		assert.Nil(t, err)
+		var used = false
+		for _, e := range elems {
+			used = used || e.Group == group
+		}
+		assert.Equal(t, del != nil, used)
+		_ = c.DelFwd(ctx, ip)
+		_ = c.DelGroup(ctx, group)
+	}
+}
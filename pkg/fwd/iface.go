@@ -0,0 +1,54 @@
+package fwd
+
+import (
+	"context"
+)
+
+//出接口校验, name 非空时优先按名称解析
+//ifindex 随网卡重建变化, 名称更稳定
+func (d *fwdCli) iface(ctx context.Context, index uint32, name string) (uint32, error) {
+	if d.resolver == nil {
+		if len(name) > 0 {
+			return 0, ErrResolverAbsent
+		}
+		return index, nil
+	}
+	if len(name) > 0 {
+		return d.resolver.Index(ctx, name)
+	}
+	var _, err = d.resolver.Name(ctx, index)
+	if err != nil {
+		return 0, err
+	}
+	return index, nil
+}
+
+//填充出接口名, ifindex已不存在的表项标记为Orphaned
+//下一跳组、隧道及drop/pass表项无出接口
+func (d *fwdCli) ifnames(ctx context.Context, elems []*FwdElem) {
+	if d.resolver == nil {
+		return
+	}
+	var names = make(map[uint32]string)
+	for _, e := range elems {
+		if e.Group > 0 || e.Tunnel > 0 || e.Action != ActionRedirect {
+			continue
+		}
+		var name, ok = names[e.Iface]
+		if !ok {
+			name, _ = d.resolver.Name(ctx, e.Iface)
+			names[e.Iface] = name
+		}
+		e.IfName = name
+		e.Orphaned = len(name) <= 0
+	}
+}
+
+func (d *fwdCli) hopnames(ctx context.Context, hops []*NextHop) {
+	if d.resolver == nil {
+		return
+	}
+	for _, h := range hops {
+		h.IfName, _ = d.resolver.Name(ctx, h.Iface)
+	}
+}
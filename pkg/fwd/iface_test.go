@@ -0,0 +1,102 @@
+package fwd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/advancevillage/3rd/logx"
+	"github.com/advancevillage/fwd/pkg/bpf"
+	"github.com/stretchr/testify/assert"
+)
+
+var ifaceTest = map[string]struct {
+	dstIp    string
+	iface    uint32
+	ifName   string
+	exp      *FwdElem
+	err      error
+	orphaned bool
+}{
+	"case-name": {
+		dstIp:  "10.8.1.1",
+		ifName: "eth5",
+		exp:    &FwdElem{Ip: "10.8.1.1", Prefix: 32, Iface: 5, IfName: "eth5", SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e", Action: ActionRedirect, Origin: OriginStatic},
+	},
+	"case-name-first": {
+		dstIp:  "10.8.1.2",
+		iface:  3,
+		ifName: "eth5",
+		exp:    &FwdElem{Ip: "10.8.1.2", Prefix: 32, Iface: 5, IfName: "eth5", SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e", Action: ActionRedirect, Origin: OriginStatic},
+	},
+	"case-index-not-exist": {
+		dstIp: "10.8.1.3",
+		iface: 20,
+		err:   ErrIfaceNotExist,
+	},
+	"case-name-not-exist": {
+		dstIp:  "10.8.1.4",
+		ifName: "bond0",
+		err:    ErrIfaceNotExist,
+	},
+}
+
+func Test_fwd_iface(t *testing.T) {
+	logger, err := logx.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	c, err := NewFwdClient(logger, WithBackend(bpf.BackendSyscall), WithResolver(&fakeSource{}))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var ctx = context.TODO()
+	for n, p := range ifaceTest {
+		f := func(t *testing.T) {
+			var elem = &FwdElem{Ip: p.dstIp, Iface: p.iface, IfName: p.ifName, SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e"}
+			var errs = c.UptFwdBatch(ctx, []*FwdElem{elem})
+			assert.ErrorIs(t, errs[0], p.err)
+			if errs[0] != nil {
+				return
+			}
+			elems, err := c.QryFwd(ctx)
+			assert.Nil(t, err)
+			assert.Contains(t, elems, p.exp)
+			assert.Nil(t, c.DelFwd(ctx, p.dstIp))
+		}
+		t.Run(n, f)
+	}
+}
+
+//网卡删除后表项标记为Orphaned
+func Test_fwd_orphaned(t *testing.T) {
+	logger, err := logx.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	c, err := NewFwdClient(logger, WithBackend(bpf.BackendSyscall), WithResolver(&fakeSource{}))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var (
+		ctx = context.TODO()
+		d   = c.(*fwdCli)
+	)
+	ip, err := d.checkkey("10.8.2.1")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	src, _ := d.checkmac("08:00:27:f3:81:0e")
+	dst, _ := d.checkmac("f8:ff:27:f3:81:0e")
+	k, v := d.kv(ip, 20, src, dst, 0)
+	assert.Nil(t, d.update(ctx, k, v))
+
+	elems, err := c.QryFwd(ctx)
+	assert.Nil(t, err)
+	assert.Contains(t, elems, &FwdElem{Ip: "10.8.2.1", Prefix: 32, Iface: 20, SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e", Action: ActionRedirect, Origin: OriginStatic, Orphaned: true})
+	assert.Nil(t, c.DelFwd(ctx, "10.8.2.1"))
+}
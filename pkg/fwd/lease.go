@@ -0,0 +1,256 @@
+package fwd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/advancevillage/fwd/pkg/bpf"
+)
+
+var (
+	ErrLeaseNotExist = errors.New("forward lease does not exist")
+)
+
+//租约表 BPF_MAP_TYPE_HASH, 仅用户态使用, 固定(pin)后守护进程重启不丢失
+// key    prefixlen(4) + addr, 同计数表
+// value  deadline(8) + ttl(8) 单位秒
+var (
+	leaseValueSize = int(0x10)
+	leaseName      = "efwd"
+	leaseName6     = "efwd6"
+	leaseInterval  = time.Second
+)
+
+//设置租约, 到期未续约的表项被删除
+//ttl 为0时取消租约, 表项永久有效
+func (d *fwdCli) LeaseFwd(ctx context.Context, dstIp string, ttl time.Duration) error {
+	var k, err = d.checkkey(dstIp)
+	if err != nil {
+		return err
+	}
+	err = d.exist(ctx, k)
+	if err != nil {
+		return err
+	}
+	if ttl <= 0 {
+		d.clearLeases(ctx, [][]byte{k})
+		return nil
+	}
+	return d.setLease(ctx, k, time.Now().Add(ttl), ttl)
+}
+
+//续约, 返回结果与dstIps一一对应
+//ttl 为0时沿用上次租约时长
+func (d *fwdCli) RenewFwd(ctx context.Context, dstIps []string, ttl time.Duration) []error {
+	var errs = make([]error, len(dstIps))
+	for i := range dstIps {
+		var k, err = d.checkkey(dstIps[i])
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		err = d.exist(ctx, k)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		var t = d.leaseTable(k)
+		if !t.ExistTable(ctx) {
+			errs[i] = ErrLeaseNotExist
+			continue
+		}
+		v, err := t.LookupTable(ctx, d.cntKey(k))
+		if errors.Is(err, bpf.ErrKeyNotExist) {
+			errs[i] = ErrLeaseNotExist
+			continue
+		}
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		var tt = ttl
+		if tt <= 0 {
+			tt = time.Duration(d.u64(v[8:])) * time.Second
+		}
+		errs[i] = d.setLease(ctx, k, time.Now().Add(tt), tt)
+	}
+	return errs
+}
+
+//删除租约到期的表项, 返回已删除的目的地址
+func (d *fwdCli) ExpireFwd(ctx context.Context) ([]string, error) {
+	var (
+		now  = time.Now().Unix()
+		keys = make([][]byte, 0, 2)
+		ips  = make([]string, 0, 2)
+	)
+	for _, t := range []bpf.ITable{d.leaseCli, d.leaseCli6} {
+		if !t.ExistTable(ctx) {
+			continue
+		}
+		var kv, err = t.QueryTable(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for i := range kv {
+			if int64(d.u64(kv[i].Value)) > now {
+				continue
+			}
+			var e = d.leaseElem(kv[i].Key)
+			ips = append(ips, d.prefix(e))
+			keys = append(keys, kv[i].Key)
+		}
+	}
+	if len(ips) <= 0 {
+		return ips, nil
+	}
+	var (
+		r    = make([]string, 0, len(ips))
+		errs = d.DelFwdBatch(ctx, ips)
+	)
+	for i := range errs {
+		switch {
+		case errs[i] == nil:
+			r = append(r, ips[i])
+		case errors.Is(errs[i], ErrFwdNotExist):
+			//表项已被删除或LRU淘汰, 仅清理租约
+			d.clearLeases(ctx, [][]byte{keys[i]})
+		default:
+			d.logger.Errorw(ctx, "expire forward fail", "ip", ips[i], "err", errs[i])
+		}
+	}
+	return r, nil
+}
+
+//周期清理到期表项及黑名单条目, 直至ctx取消
+func (d *fwdCli) RunExpiry(ctx context.Context) {
+	var ticker = time.NewTicker(leaseInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		var ips, err = d.ExpireFwd(ctx)
+		if err != nil {
+			d.logger.Errorw(ctx, "expire forward fail", "err", err)
+		}
+		for _, ip := range ips {
+			d.logger.Infow(ctx, "forward lease expired", "ip", ip)
+		}
+		srcs, err := d.ExpireBlock(ctx)
+		if err != nil {
+			d.logger.Errorw(ctx, "expire block fail", "err", err)
+		}
+		for _, src := range srcs {
+			d.logger.Infow(ctx, "source block expired", "src", src)
+		}
+	}
+}
+
+//填充租约到期时间
+func (d *fwdCli) leases(ctx context.Context, elems []*FwdElem) error {
+	var lease = make(map[string][]byte)
+	for _, t := range []bpf.ITable{d.leaseCli, d.leaseCli6} {
+		if !t.ExistTable(ctx) {
+			continue
+		}
+		var kv, err = t.QueryTable(ctx)
+		if err != nil {
+			return err
+		}
+		for i := range kv {
+			lease[string(kv[i].Key)] = kv[i].Value
+		}
+	}
+	if len(lease) <= 0 {
+		return nil
+	}
+	for _, e := range elems {
+		if e.Table > 0 {
+			continue
+		}
+		var k, err = d.checkkey(d.prefix(e))
+		if err != nil {
+			continue
+		}
+		if v, ok := lease[string(d.cntKey(k))]; ok {
+			e.Deadline = int64(d.u64(v))
+		}
+	}
+	return nil
+}
+
+func (d *fwdCli) setLease(ctx context.Context, k []byte, deadline time.Time, ttl time.Duration) error {
+	var t = d.leaseTable(k)
+	var err = d.prepare(ctx, t)
+	if err != nil {
+		return err
+	}
+	if ttl < 0 {
+		ttl = 0
+	}
+	var (
+		v  = make([]byte, leaseValueSize)
+		dl = uint64(deadline.Unix())
+		tt = uint64(ttl / time.Second)
+	)
+	for i := 0; i < 8; i++ {
+		v[i] = byte(dl >> (8 * i))
+		v[8+i] = byte(tt >> (8 * i))
+	}
+	return t.UpdateTable(ctx, d.cntKey(k), v)
+}
+
+func (d *fwdCli) clearLeases(ctx context.Context, keys [][]byte) {
+	var groups = make(map[bpf.ITable][][]byte)
+	for _, k := range keys {
+		if len(k) == policyTableKeySize {
+			continue
+		}
+		var t = d.leaseTable(k)
+		groups[t] = append(groups[t], d.cntKey(k))
+	}
+	for t, kk := range groups {
+		if !t.ExistTable(ctx) {
+			continue
+		}
+		_ = t.DeleteBatchTable(ctx, kk)
+	}
+}
+
+func (d *fwdCli) leaseTable(k []byte) bpf.ITable {
+	switch len(k) {
+	case key6Size, lpmKey6Size:
+		return d.leaseCli6
+	default:
+		return d.leaseCli
+	}
+}
+
+//租约key转换为表项, 主机路由前缀长度为32或128
+func (d *fwdCli) leaseElem(k []byte) *FwdElem {
+	return &FwdElem{Ip: net.IP(k[4:]).String(), Prefix: int(d.u32(k))}
+}
+
+func (d *fwdCli) exist(ctx context.Context, k []byte) error {
+	var t, err = d.table(k)
+	if err != nil {
+		return err
+	}
+	if !t.ExistTable(ctx) {
+		return ErrFwdNotExist
+	}
+	_, err = t.LookupTable(ctx, k)
+	if errors.Is(err, bpf.ErrKeyNotExist) {
+		return ErrFwdNotExist
+	}
+	return err
+}
+
+func (d *fwdCli) u64(b []byte) uint64 {
+	return uint64(d.u32(b)) | uint64(d.u32(b[4:]))<<32
+}
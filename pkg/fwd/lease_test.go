@@ -0,0 +1,131 @@
+package fwd
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/advancevillage/3rd/logx"
+	"github.com/advancevillage/fwd/pkg/bpf"
+	"github.com/stretchr/testify/assert"
+)
+
+var leaseTest = map[string]struct {
+	dstIp    string
+	install  bool
+	ttl      time.Duration
+	expired  bool
+	err      error
+	renewErr error
+}{
+	"case-lease": {
+		dstIp:   "10.9.1.1",
+		install: true,
+		ttl:     time.Minute,
+	},
+	"case-lease-prefix": {
+		dstIp:   "fd09::/64",
+		install: true,
+		ttl:     time.Minute,
+	},
+	"case-expired": {
+		dstIp:   "10.9.2.0/24",
+		install: true,
+		ttl:     time.Minute,
+		expired: true,
+	},
+	"case-permanent": {
+		dstIp:    "10.9.1.2",
+		install:  true,
+		renewErr: ErrLeaseNotExist,
+	},
+	"case-not-exist": {
+		dstIp:    "10.9.1.3",
+		ttl:      time.Minute,
+		err:      ErrFwdNotExist,
+		renewErr: ErrFwdNotExist,
+	},
+}
+
+func Test_fwd_lease(t *testing.T) {
+	logger, err := logx.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	c, err := NewFwdClient(logger, WithBackend(bpf.BackendSyscall), WithResolver(&fakeSource{}))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var (
+		ctx = context.TODO()
+		d   = c.(*fwdCli)
+	)
+	for n, p := range leaseTest {
+		f := func(t *testing.T) {
+			_ = c.DelFwd(ctx, p.dstIp)
+			if p.install {
+				assert.Nil(t, c.UptFwdBatch(ctx, []*FwdElem{{Ip: p.dstIp, Iface: 4, SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e"}})[0])
+			}
+			err := c.LeaseFwd(ctx, p.dstIp, p.ttl)
+			assert.ErrorIs(t, err, p.err)
+			//ttl为0时沿用上次租约时长
+			assert.ErrorIs(t, c.RenewFwd(ctx, []string{p.dstIp}, 0)[0], p.renewErr)
+			if err != nil {
+				return
+			}
+			if p.expired {
+				k, _ := d.checkkey(p.dstIp)
+				assert.Nil(t, d.setLease(ctx, k, time.Now().Add(-time.Second), p.ttl))
+			}
+			ips, err := c.ExpireFwd(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, p.expired, contains(ips, p.dstIp))
+
+			elems, err := c.QryFwd(ctx)
+			assert.Nil(t, err)
+			var elem = find(elems, p.dstIp)
+			if p.expired {
+				assert.Nil(t, elem)
+				return
+			}
+			if !assert.NotNil(t, elem) {
+				return
+			}
+			switch {
+			case p.ttl > 0:
+				assert.InDelta(t, time.Now().Add(p.ttl).Unix(), elem.Deadline, 1)
+			default:
+				assert.Equal(t, int64(0), elem.Deadline)
+			}
+			assert.Nil(t, c.DelFwd(ctx, p.dstIp))
+			//删除表项同时清理租约
+			k, _ := d.checkkey(p.dstIp)
+			if tt := d.leaseTable(k); tt.ExistTable(ctx) {
+				_, err = tt.LookupTable(ctx, d.cntKey(k))
+				assert.ErrorIs(t, err, bpf.ErrKeyNotExist)
+			}
+		}
+		t.Run(n, f)
+	}
+}
+
+func contains(s []string, v string) bool {
+	for i := range s {
+		if s[i] == v {
+			return true
+		}
+	}
+	return false
+}
+
+func find(elems []*FwdElem, dst string) *FwdElem {
+	for _, e := range elems {
+		if e.Ip == dst || fmt.Sprintf("%s/%d", e.Ip, e.Prefix) == dst {
+			return e
+		}
+	}
+	return nil
+}
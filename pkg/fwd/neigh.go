@@ -0,0 +1,82 @@
+package fwd
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+var (
+	ErrNeighNotExist  = errors.New("neighbor does not exist")
+	ErrIfaceNotExist  = errors.New("iface does not exist")
+	ErrGatewayOnLink  = errors.New("gateway is not directly connected")
+	ErrResolverAbsent = errors.New("next hop resolver is unavailable")
+)
+
+//下一跳解析, 读取内核邻居表及路由表
+type IResolver interface {
+	//下一跳MAC, 未解析时返回ErrNeighNotExist
+	Neighbor(ctx context.Context, iface uint32, ip net.IP) (net.HardwareAddr, error)
+	//出接口MAC
+	Link(ctx context.Context, iface uint32) (net.HardwareAddr, error)
+	//网卡名转ifindex, 不存在时返回ErrIfaceNotExist
+	Index(ctx context.Context, name string) (uint32, error)
+	//ifindex转网卡名, 不存在时返回ErrIfaceNotExist
+	Name(ctx context.Context, index uint32) (string, error)
+	//到达ip的出接口及网关, 直连时网关为空
+	Route(ctx context.Context, ip net.IP) (uint32, net.IP, error)
+}
+
+//指定下一跳解析, 默认使用netlink
+func WithResolver(r IResolver) FwdOption {
+	return func(d *fwdCli) {
+		d.resolver = r
+	}
+}
+
+//按网关设置转发表, 出接口及MAC由内核邻居表解析
+//dstIp   主机地址或CIDR前缀
+//gateway 网关地址, 需直连
+//ifName  出接口名 为空时按路由表查找
+func (d *fwdCli) UptFwdVia(ctx context.Context, dstIp string, gateway string, ifName string) error {
+	var hop, err = d.ResolveHop(ctx, gateway, ifName)
+	if err != nil {
+		return err
+	}
+	return d.UptFwd(ctx, dstIp, hop.Iface, hop.SrcMac, hop.DstMac)
+}
+
+func (d *fwdCli) ResolveHop(ctx context.Context, gateway string, ifName string) (*NextHop, error) {
+	if d.resolver == nil {
+		return nil, ErrResolverAbsent
+	}
+	var gw = net.ParseIP(gateway)
+	if gw == nil {
+		return nil, errors.New("invalid gateway format")
+	}
+	var (
+		iface uint32
+		err   error
+	)
+	if len(ifName) > 0 {
+		iface, err = d.resolver.Index(ctx, ifName)
+	} else {
+		var via net.IP
+		iface, via, err = d.resolver.Route(ctx, gw)
+		if err == nil && len(via) > 0 && !via.Equal(gw) {
+			err = ErrGatewayOnLink
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	src, err := d.resolver.Link(ctx, iface)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := d.resolver.Neighbor(ctx, iface, gw)
+	if err != nil {
+		return nil, err
+	}
+	return &NextHop{Iface: iface, SrcMac: src.String(), DstMac: dst.String()}, nil
+}
@@ -0,0 +1,83 @@
+package fwd
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/advancevillage/3rd/logx"
+	"github.com/advancevillage/fwd/pkg/bpf"
+	"github.com/stretchr/testify/assert"
+)
+
+var resolveTest = map[string]struct {
+	gateway string
+	ifName  string
+	hop     *NextHop
+	err     error
+}{
+	"case-route": {
+		gateway: "192.0.2.1",
+		hop:     &NextHop{Iface: 4, SrcMac: "08:00:27:f3:81:04", DstMac: "f8:ff:27:f3:81:01"},
+	},
+	"case-ifname": {
+		gateway: "192.0.2.1",
+		ifName:  "eth4",
+		hop:     &NextHop{Iface: 4, SrcMac: "08:00:27:f3:81:04", DstMac: "f8:ff:27:f3:81:01"},
+	},
+	"case-unresolved": {
+		gateway: "192.0.2.9",
+		err:     ErrNeighNotExist,
+	},
+	"case-not-onlink": {
+		gateway: "198.51.100.1",
+		err:     ErrGatewayOnLink,
+	},
+	"case-iface-not-exist": {
+		gateway: "192.0.2.1",
+		ifName:  "eth99",
+		err:     ErrIfaceNotExist,
+	},
+}
+
+func Test_resolve_hop(t *testing.T) {
+	logger, err := logx.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var src = &fakeSource{
+		neigh: map[string]net.HardwareAddr{
+			"192.0.2.1": {0xf8, 0xff, 0x27, 0xf3, 0x81, 0x01},
+		},
+	}
+	c, err := NewFwdClient(logger, WithBackend(bpf.BackendSyscall), WithResolver(src))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var ctx = context.TODO()
+	_, err = c.Tables(ctx)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	for n, p := range resolveTest {
+		f := func(t *testing.T) {
+			var hop, err = c.ResolveHop(ctx, p.gateway, p.ifName)
+			assert.ErrorIs(t, err, p.err)
+			assert.Equal(t, p.hop, hop)
+
+			err = c.UptFwdVia(ctx, "10.7.0.0/16", p.gateway, p.ifName)
+			assert.ErrorIs(t, err, p.err)
+			if err != nil {
+				return
+			}
+			elems, err := c.QryFwd(ctx)
+			assert.Nil(t, err)
+			assert.Contains(t, elems, &FwdElem{Ip: "10.7.0.0", Prefix: 16, Iface: p.hop.Iface, IfName: "eth4", SrcMac: p.hop.SrcMac, DstMac: p.hop.DstMac, Action: ActionRedirect, Origin: OriginStatic})
+			assert.Nil(t, c.DelFwd(ctx, "10.7.0.0/16"))
+		}
+		t.Run(n, f)
+	}
+}
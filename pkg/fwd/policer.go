@@ -0,0 +1,191 @@
+package fwd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/advancevillage/fwd/pkg/bpf"
+)
+
+var (
+	ErrPolicer = errors.New("forward policer is invalid")
+)
+
+//表项限速 BPF_MAP_TYPE_HASH, 表项value[policerOff]非0时XDP按表项key查询
+// rfwd/rfwd6  key: prefixlen(4) + addr, 同计数表
+// value  exceed(1) + pad(3) + lock(4) + pps(8) + rate(8) + burst_pkts(8) + burst_bytes(8)
+//        + tok_pkts(8) + tok_bytes(8) + last(8) + exceed_pkts(8) + exceed_bytes(8)
+var (
+	policerOff       = int(0x17)
+	policerValueSize = int(0x50)
+	policerName      = "rfwd"
+	policerName6     = "rfwd6"
+	//令牌按纳秒缩放, 桶深 * 1e9 需小于2^63, fwd.bpf.c tb_fill饱和相加不溢出
+	policerMaxBurst = uint64(1) << 33
+)
+
+//超限动作, 与fwd.bpf.c POL_DROP/POL_PASS保持一致
+const (
+	ExceedDrop = "drop"
+	ExceedPass = "pass"
+)
+
+//Pps/Bps   速率上限 报文每秒/比特每秒, 均为0表示不限速
+//BurstPkts/BurstBytes 桶深 0时取1秒的速率, 上限2^33
+//Exceed    超限动作 drop(默认)/pass
+//ExceedPkts/ExceedBytes 超限计数 仅查询
+type Policer struct {
+	Pps         uint64
+	Bps         uint64
+	BurstPkts   uint64
+	BurstBytes  uint64
+	Exceed      string
+	ExceedPkts  uint64
+	ExceedBytes uint64
+}
+
+func (p Policer) enabled() bool {
+	return p.Pps > 0 || p.Bps > 0
+}
+
+func (d *fwdCli) policerValue(p Policer) ([]byte, error) {
+	var (
+		v     = make([]byte, policerValueSize)
+		rate  = p.Bps / 8
+		burst = []uint64{p.BurstPkts, p.BurstBytes}
+	)
+	if p.Bps > 0 && rate <= 0 {
+		return nil, fmt.Errorf("bps %d is too small: %w", p.Bps, ErrPolicer)
+	}
+	switch p.Exceed {
+	case "", ExceedDrop:
+	case ExceedPass:
+		v[0] = 1
+	default:
+		return nil, fmt.Errorf("exceed %s: %w", p.Exceed, ErrPolicer)
+	}
+	for i, r := range []uint64{p.Pps, rate} {
+		//默认桶深取1秒速率, 高速率时截断到上限
+		if burst[i] <= 0 && r > policerMaxBurst {
+			burst[i] = policerMaxBurst
+		}
+		if burst[i] <= 0 {
+			burst[i] = r
+		}
+		if burst[i] > policerMaxBurst {
+			return nil, fmt.Errorf("burst %d exceeds limit: %w", burst[i], ErrPolicer)
+		}
+	}
+	for i, u := range []uint64{p.Pps, rate, burst[0], burst[1]} {
+		d.putU64(v[8+8*i:], u)
+	}
+	return v, nil
+}
+
+//填充限速配置及超限计数
+func (d *fwdCli) policers(ctx context.Context, elems []*FwdElem) error {
+	var pol = make(map[string][]byte)
+	for _, t := range []bpf.ITable{d.polCli, d.polCli6} {
+		if !t.ExistTable(ctx) {
+			continue
+		}
+		var kv, err = t.QueryTable(ctx)
+		if err != nil {
+			return err
+		}
+		for i := range kv {
+			pol[string(kv[i].Key)] = kv[i].Value
+		}
+	}
+	if len(pol) <= 0 {
+		return nil
+	}
+	for _, e := range elems {
+		if e.Table > 0 {
+			continue
+		}
+		var k, err = d.checkkey(d.prefix(e))
+		if err != nil {
+			continue
+		}
+		var v, ok = pol[string(d.cntKey(k))]
+		if !ok {
+			continue
+		}
+		e.Policer = Policer{
+			Pps:         d.u64(v[8:]),
+			Bps:         d.u64(v[16:]) * 8,
+			BurstPkts:   d.u64(v[24:]),
+			BurstBytes:  d.u64(v[32:]),
+			Exceed:      ExceedDrop,
+			ExceedPkts:  d.u64(v[64:]),
+			ExceedBytes: d.u64(v[72:]),
+		}
+		if v[0] == 1 {
+			e.Policer.Exceed = ExceedPass
+		}
+	}
+	return nil
+}
+
+//写入令牌桶, 重新配置时令牌及超限计数清零
+func (d *fwdCli) setPolicers(ctx context.Context, kvs []*bpf.KV) []error {
+	var (
+		errs   = make([]error, len(kvs))
+		groups = make(map[bpf.ITable][]int)
+	)
+	for j := range kvs {
+		var t = d.policerTable(kvs[j].Key)
+		groups[t] = append(groups[t], j)
+	}
+	for t, idx := range groups {
+		var err = d.prepare(ctx, t)
+		var sub = make([]*bpf.KV, 0, len(idx))
+		for _, j := range idx {
+			errs[j] = err
+			sub = append(sub, &bpf.KV{Key: d.cntKey(kvs[j].Key), Value: kvs[j].Value})
+		}
+		if err != nil {
+			continue
+		}
+		var rr = t.UpdateBatchTable(ctx, sub)
+		for k := range rr {
+			errs[idx[k]] = rr[k]
+		}
+	}
+	return errs
+}
+
+//表项删除或以不限速重新下发后清理令牌桶
+func (d *fwdCli) clearPolicers(ctx context.Context, keys [][]byte) {
+	var groups = make(map[bpf.ITable][][]byte)
+	for _, k := range keys {
+		if len(k) == policyTableKeySize {
+			continue
+		}
+		var t = d.policerTable(k)
+		groups[t] = append(groups[t], d.cntKey(k))
+	}
+	for t, kk := range groups {
+		if !t.ExistTable(ctx) {
+			continue
+		}
+		_ = t.DeleteBatchTable(ctx, kk)
+	}
+}
+
+func (d *fwdCli) policerTable(k []byte) bpf.ITable {
+	switch len(k) {
+	case key6Size, lpmKey6Size:
+		return d.polCli6
+	default:
+		return d.polCli
+	}
+}
+
+func (d *fwdCli) putU64(b []byte, v uint64) {
+	for i := 0; i < 8; i++ {
+		b[i] = byte(v >> (8 * i))
+	}
+}
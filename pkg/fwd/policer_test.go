@@ -0,0 +1,139 @@
+package fwd
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/advancevillage/3rd/logx"
+	"github.com/advancevillage/fwd/pkg/bpf"
+	"github.com/stretchr/testify/assert"
+)
+
+var policerTest = map[string]struct {
+	elem *FwdElem
+	exp  Policer
+	err  error
+}{
+	"case-pps": {
+		elem: &FwdElem{Ip: "10.9.1.1", Iface: 4, SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e", Policer: Policer{Pps: 1000, BurstPkts: 100}},
+		exp:  Policer{Pps: 1000, BurstPkts: 100, Exceed: ExceedDrop},
+	},
+	"case-bps-default-burst": {
+		elem: &FwdElem{Ip: "10.9.0.0/16", Iface: 4, SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e", Policer: Policer{Bps: 8000000, Exceed: ExceedPass}},
+		exp:  Policer{Bps: 8000000, BurstBytes: 1000000, Exceed: ExceedPass},
+	},
+	"case-ipv6": {
+		elem: &FwdElem{Ip: "2001:db8::9", Iface: 4, SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e", Policer: Policer{Pps: 10, Bps: 80000}},
+		exp:  Policer{Pps: 10, Bps: 80000, BurstPkts: 10, BurstBytes: 10000, Exceed: ExceedDrop},
+	},
+	"case-exceed-invalid": {
+		elem: &FwdElem{Ip: "10.9.1.2", Iface: 4, SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e", Policer: Policer{Pps: 1000, Exceed: "reject"}},
+		err:  ErrPolicer,
+	},
+	"case-burst-limit": {
+		elem: &FwdElem{Ip: "10.9.1.3", Iface: 4, SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e", Policer: Policer{Pps: 1000, BurstPkts: 1 << 40}},
+		err:  ErrPolicer,
+	},
+	"case-burst-boundary": {
+		elem: &FwdElem{Ip: "10.9.1.6", Iface: 4, SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e", Policer: Policer{Pps: 1000, BurstPkts: 1<<33 + 1}},
+		err:  ErrPolicer,
+	},
+	"case-bps-default-burst-limit": {
+		elem: &FwdElem{Ip: "10.9.1.7", Iface: 4, SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e", Policer: Policer{Bps: 100000000000}},
+		exp:  Policer{Bps: 100000000000, BurstBytes: 1 << 33, Exceed: ExceedDrop},
+	},
+	"case-bps-too-small": {
+		elem: &FwdElem{Ip: "10.9.1.4", Iface: 4, SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e", Policer: Policer{Bps: 7}},
+		err:  ErrPolicer,
+	},
+	"case-drop": {
+		elem: &FwdElem{Ip: "10.9.1.5", Action: ActionDrop, Policer: Policer{Pps: 1000}},
+		err:  ErrPolicer,
+	},
+}
+
+func Test_fwd_policer(t *testing.T) {
+	logger, err := logx.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	c, err := NewFwdClient(logger, WithBackend(bpf.BackendSyscall), WithResolver(&fakeSource{}))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var ctx = context.TODO()
+	for n, p := range policerTest {
+		f := func(t *testing.T) {
+			var errs = c.UptFwdBatch(ctx, []*FwdElem{p.elem})
+			assert.ErrorIs(t, errs[0], p.err)
+			if errs[0] != nil {
+				return
+			}
+			elems, err := c.QryFwd(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, p.exp, policerOf(elems, p.elem.Ip))
+
+			//不指定限速重新下发时取消限速
+			var e = *p.elem
+			e.Policer = Policer{}
+			errs = c.UptFwdBatch(ctx, []*FwdElem{&e})
+			assert.Nil(t, errs[0])
+			elems, err = c.QryFwd(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, Policer{}, policerOf(elems, p.elem.Ip))
+
+			//删除表项时清理令牌桶
+			errs = c.UptFwdBatch(ctx, []*FwdElem{p.elem})
+			assert.Nil(t, errs[0])
+			assert.Nil(t, c.DelFwd(ctx, p.elem.Ip))
+			var d = c.(*fwdCli)
+			k, _ := d.checkkey(p.elem.Ip)
+			_, err = d.policerTable(k).LookupTable(ctx, d.cntKey(k))
+			assert.ErrorIs(t, err, bpf.ErrKeyNotExist)
+		}
+		t.Run(n, f)
+	}
+}
+
+//模拟XDP超限计数
+func Test_fwd_policer_exceed(t *testing.T) {
+	logger, err := logx.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	c, err := NewFwdClient(logger, WithBackend(bpf.BackendSyscall), WithResolver(&fakeSource{}))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var (
+		ctx  = context.TODO()
+		d    = c.(*fwdCli)
+		elem = &FwdElem{Ip: "10.9.2.1", Iface: 4, SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e", Policer: Policer{Pps: 100}}
+	)
+	assert.Nil(t, c.UptFwdBatch(ctx, []*FwdElem{elem})[0])
+	k, _ := d.checkkey(elem.Ip)
+	v, err := d.polCli.LookupTable(ctx, d.cntKey(k))
+	assert.Nil(t, err)
+	d.putU64(v[64:], 7)
+	d.putU64(v[72:], 700)
+	assert.Nil(t, d.polCli.UpdateTable(ctx, d.cntKey(k), v))
+
+	elems, err := c.QryFwd(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, Policer{Pps: 100, BurstPkts: 100, Exceed: ExceedDrop, ExceedPkts: 7, ExceedBytes: 700}, policerOf(elems, elem.Ip))
+	assert.Nil(t, c.DelFwd(ctx, elem.Ip))
+}
+
+func policerOf(elems []*FwdElem, ip string) Policer {
+	for _, e := range elems {
+		if e.Ip == ip || fmt.Sprintf("%s/%d", e.Ip, e.Prefix) == ip {
+			return e.Policer
+		}
+	}
+	return Policer{}
+}
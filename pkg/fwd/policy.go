@@ -0,0 +1,322 @@
+package fwd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/advancevillage/fwd/pkg/bpf"
+)
+
+var (
+	ErrPolicy         = errors.New("policy rule is invalid")
+	ErrPolicyExist    = errors.New("policy rule already exists")
+	ErrPolicyNotExist = errors.New("policy rule does not exist")
+	ErrPolicyLimit    = errors.New("policy rule count exceeds limit")
+	ErrPolicyTable    = errors.New("policy table entry is invalid")
+)
+
+//策略路由 有序规则集, 仅IPv4, 按优先级在目的地址查找前匹配, 首条命中的规则生效
+// ofwd  BPF_MAP_TYPE_ARRAY     规则       key: 槽位 value: policy_rule
+// ocfg  BPF_MAP_TYPE_ARRAY     生效规则集 key: 0    value: set << 16 | count
+// vfwd  BPF_MAP_TYPE_LPM_TRIE  策略表     key: prefixlen(32 + 前缀长度) + 表ID + 网络地址 value: 同转发表
+//
+// ofwd 双缓冲同afwd, 增删规则时按优先级重写备用规则集后切换
+// policy_rule src(4) smask(4) dport(2+2) ifindex(4) proto(1) dscp(1) flags(1) target(1)
+//             prio(4) id(4) oif(4) smac(6) dmac(6)
+var (
+	policyMaxRules     = int(64)
+	policyRuleSize     = int(0x2c)
+	policyCfgSize      = int(0x04)
+	policyName         = "ofwd"
+	policyCfgName      = "ocfg"
+	policyTableName    = "vfwd"
+	policyTableKeySize = int(0x0c)
+	policyFlagDscp     = byte(0x01)
+	policyDscpMax      = uint8(63)
+)
+
+//与fwd.bpf.c POLICY_NH/POLICY_GROUP/POLICY_TABLE保持一致
+const (
+	policyNh = iota
+	policyGroup
+	policyTable
+)
+
+//Priority    优先级 越小越先匹配, 唯一标识规则
+//InIface     入接口 InIfName非空时优先, 均为空表示任意
+//Src         源前缀 为空表示任意 eg: 10.0.0.0/8 10.1.1.1
+//Proto       IP协议号 0表示任意, 指定端口时须为TCP(6)或UDP(17)
+//Dscp        为空表示任意, 取值0~63
+//DportMin/DportMax 目的端口闭区间 Max为0表示任意, 分片或L4头不完整的报文不命中指定端口的规则
+//Table       策略表 非0时查找该表, 表中无匹配时继续匹配后续规则
+//Group       下一跳组 Table为0时有效
+//Iface/IfName/SrcMac/DstMac 下一跳 Table/Group均为0时有效
+type PolicyElem struct {
+	Priority uint32
+	InIface  uint32
+	InIfName string
+	Src      string
+	Proto    uint8
+	Dscp     *uint8
+	DportMin uint16
+	DportMax uint16
+	Table    uint32
+	Group    uint32
+	Iface    uint32
+	IfName   string
+	SrcMac   string
+	DstMac   string
+}
+
+//按优先级插入规则, 优先级已存在时返回ErrPolicyExist
+func (d *fwdCli) AddPolicy(ctx context.Context, p *PolicyElem) error {
+	d.groupMu.Lock()
+	defer d.groupMu.Unlock()
+
+	var v, err = d.policyValue(ctx, p)
+	if err != nil {
+		return err
+	}
+	values, err := d.policyValues(ctx)
+	if err != nil {
+		return err
+	}
+	for i := range values {
+		if d.u32(values[i][20:]) == p.Priority {
+			return ErrPolicyExist
+		}
+	}
+	if len(values) >= policyMaxRules {
+		return ErrPolicyLimit
+	}
+	return d.setPolicies(ctx, append(values, v))
+}
+
+func (d *fwdCli) DelPolicy(ctx context.Context, priority uint32) error {
+	var values, err = d.policyValues(ctx)
+	if err != nil {
+		return err
+	}
+	for i := range values {
+		if d.u32(values[i][20:]) == priority {
+			return d.setPolicies(ctx, append(values[:i], values[i+1:]...))
+		}
+	}
+	return ErrPolicyNotExist
+}
+
+//按优先级返回规则
+func (d *fwdCli) QryPolicy(ctx context.Context) ([]*PolicyElem, error) {
+	var r = make([]*PolicyElem, 0, 2)
+	var values, err = d.policyValues(ctx)
+	if err != nil {
+		return r, err
+	}
+	for i := range values {
+		var p = d.policyElem(values[i])
+		if d.resolver != nil {
+			if p.InIface > 0 {
+				p.InIfName, _ = d.resolver.Name(ctx, p.InIface)
+			}
+			if p.Iface > 0 {
+				p.IfName, _ = d.resolver.Name(ctx, p.Iface)
+			}
+		}
+		r = append(r, p)
+	}
+	return r, nil
+}
+
+//返回生效规则集的规则, 表未创建时为空
+func (d *fwdCli) policyValues(ctx context.Context) ([][]byte, error) {
+	var set, count, err = d.policyCfg(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var values = make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		var v, err = d.pbrCli.LookupTable(ctx, d.u32Key(uint32(set*policyMaxRules+i)))
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+//按优先级排序后写入备用规则集并切换
+func (d *fwdCli) setPolicies(ctx context.Context, values [][]byte) error {
+	if len(values) > policyMaxRules {
+		return ErrPolicyLimit
+	}
+	sort.SliceStable(values, func(i, j int) bool {
+		return d.u32(values[i][20:]) < d.u32(values[j][20:])
+	})
+	var set, _, err = d.policyCfg(ctx)
+	if err != nil {
+		return err
+	}
+	var (
+		next = 1 - set
+		kvs  = make([]*bpf.KV, 0, len(values))
+	)
+	for i := range values {
+		kvs = append(kvs, &bpf.KV{Key: d.u32Key(uint32(next*policyMaxRules + i)), Value: values[i]})
+	}
+	for _, e := range d.pbrCli.UpdateBatchTable(ctx, kvs) {
+		if e != nil {
+			return e
+		}
+	}
+	return d.pbrCfgCli.UpdateTable(ctx, d.u32Key(0), d.u32Key(uint32(next<<16|len(values))))
+}
+
+func (d *fwdCli) policyCfg(ctx context.Context) (int, int, error) {
+	var err = d.prepare(ctx, d.pbrCli)
+	if err != nil {
+		return 0, 0, err
+	}
+	err = d.prepare(ctx, d.pbrCfgCli)
+	if err != nil {
+		return 0, 0, err
+	}
+	v, err := d.pbrCfgCli.LookupTable(ctx, d.u32Key(0))
+	if err != nil {
+		return 0, 0, err
+	}
+	var cfg = d.u32(v)
+	if cfg>>16 > 0 {
+		return 1, int(cfg & 0xffff), nil
+	}
+	return 0, int(cfg & 0xffff), nil
+}
+
+func (d *fwdCli) policyValue(ctx context.Context, p *PolicyElem) ([]byte, error) {
+	if p == nil {
+		return nil, ErrPolicy
+	}
+	var v = make([]byte, policyRuleSize)
+	src, smask, err := d.aclPrefix(p.Src)
+	if err != nil {
+		return nil, fmt.Errorf("src %s: %w", p.Src, ErrPolicy)
+	}
+	copy(v[0:4], src)
+	copy(v[4:8], smask)
+	//目的端口
+	var lo, hi = p.DportMin, p.DportMax
+	if hi == 0 {
+		if lo > 0 {
+			return nil, fmt.Errorf("port range is invalid: %w", ErrPolicy)
+		}
+		hi = 0xffff
+	}
+	if lo > hi {
+		return nil, fmt.Errorf("port range is invalid: %w", ErrPolicy)
+	}
+	if (lo > 0 || hi < 0xffff) && p.Proto != 6 && p.Proto != 17 {
+		return nil, fmt.Errorf("port requires tcp or udp: %w", ErrPolicy)
+	}
+	v[8], v[9] = byte(lo), byte(lo>>8)
+	v[10], v[11] = byte(hi), byte(hi>>8)
+	//入接口
+	var in = p.InIface
+	if in > 0 || len(p.InIfName) > 0 {
+		in, err = d.iface(ctx, p.InIface, p.InIfName)
+		if err != nil {
+			return nil, err
+		}
+	}
+	copy(v[12:16], d.u32Key(in))
+	v[16] = p.Proto
+	if p.Dscp != nil {
+		if *p.Dscp > policyDscpMax {
+			return nil, fmt.Errorf("dscp %d: %w", *p.Dscp, ErrPolicy)
+		}
+		v[17] = *p.Dscp
+		v[18] = policyFlagDscp
+	}
+	copy(v[20:24], d.u32Key(p.Priority))
+	//目标
+	switch {
+	case p.Table > 0:
+		v[19] = policyTable
+		copy(v[24:28], d.u32Key(p.Table))
+	case p.Group > 0:
+		_, err = d.lookupGroup(ctx, p.Group)
+		if err != nil {
+			return nil, err
+		}
+		v[19] = policyGroup
+		copy(v[24:28], d.u32Key(p.Group))
+	default:
+		smac, err := d.checkmac(p.SrcMac)
+		if err != nil {
+			return nil, fmt.Errorf("smac %s: %w", p.SrcMac, ErrPolicy)
+		}
+		dmac, err := d.checkmac(p.DstMac)
+		if err != nil {
+			return nil, fmt.Errorf("dmac %s: %w", p.DstMac, ErrPolicy)
+		}
+		oif, err := d.iface(ctx, p.Iface, p.IfName)
+		if err != nil {
+			return nil, err
+		}
+		v[19] = policyNh
+		copy(v[28:32], d.u32Key(oif))
+		copy(v[32:38], smac)
+		copy(v[38:44], dmac)
+	}
+	return v, nil
+}
+
+func (d *fwdCli) policyElem(v []byte) *PolicyElem {
+	var p = &PolicyElem{
+		Priority: d.u32(v[20:]),
+		InIface:  d.u32(v[12:]),
+		Src:      d.aclString(v[0:4], v[4:8]),
+		Proto:    v[16],
+		DportMin: uint16(v[8]) | uint16(v[9])<<8,
+		DportMax: uint16(v[10]) | uint16(v[11])<<8,
+	}
+	if p.DportMin == 0 && p.DportMax == 0xffff {
+		p.DportMax = 0
+	}
+	if v[18]&policyFlagDscp > 0 {
+		var dscp = v[17]
+		p.Dscp = &dscp
+	}
+	switch v[19] {
+	case policyTable:
+		p.Table = d.u32(v[24:])
+	case policyGroup:
+		p.Group = d.u32(v[24:])
+	default:
+		p.Iface = d.u32(v[28:])
+		p.SrcMac = net.HardwareAddr(v[32:38]).String()
+		p.DstMac = net.HardwareAddr(v[38:44]).String()
+	}
+	return p
+}
+
+//策略表key prefixlen(32 + 前缀长度) + 表ID + 网络地址, 仅IPv4
+func (d *fwdCli) policyKey(table uint32, k []byte) ([]byte, error) {
+	var (
+		plen = uint32(32)
+		addr = k
+	)
+	switch len(k) {
+	case keySize:
+	case lpmKeySize:
+		plen, addr = d.u32(k), k[4:]
+	default:
+		return nil, fmt.Errorf("table %d requires ipv4: %w", table, ErrPolicyTable)
+	}
+	var pk = make([]byte, policyTableKeySize)
+	copy(pk[0:4], d.u32Key(32+plen))
+	copy(pk[4:8], d.u32Key(table))
+	copy(pk[8:12], addr)
+	return pk, nil
+}
@@ -0,0 +1,168 @@
+package fwd
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/advancevillage/3rd/logx"
+	"github.com/advancevillage/fwd/pkg/bpf"
+	"github.com/stretchr/testify/assert"
+)
+
+func dscp(v uint8) *uint8 {
+	return &v
+}
+
+var policyTest = map[string]struct {
+	policy *PolicyElem
+	err    error
+}{
+	"case-nh": {
+		policy: &PolicyElem{Priority: 100, InIface: 2, InIfName: "eth2", Src: "10.20.0.0/16", Proto: 6, DportMin: 80, DportMax: 443, Iface: 4, IfName: "eth4", SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e"},
+	},
+	"case-table": {
+		policy: &PolicyElem{Priority: 200, Src: "10.21.1.1/32", Dscp: dscp(46), Table: 10},
+	},
+	"case-dscp-zero": {
+		policy: &PolicyElem{Priority: 300, Dscp: dscp(0), Table: 11},
+	},
+	"case-dscp": {
+		policy: &PolicyElem{Priority: 400, Dscp: dscp(64), Table: 10},
+		err:    ErrPolicy,
+	},
+	"case-port": {
+		policy: &PolicyElem{Priority: 500, Proto: 1, DportMin: 80, DportMax: 80, Table: 10},
+		err:    ErrPolicy,
+	},
+	"case-src": {
+		policy: &PolicyElem{Priority: 600, Src: "2001:db8::/32", Table: 10},
+		err:    ErrPolicy,
+	},
+	"case-mac": {
+		policy: &PolicyElem{Priority: 700, Iface: 4, SrcMac: "08:00:27:f3:81"},
+		err:    ErrPolicy,
+	},
+	"case-group": {
+		policy: &PolicyElem{Priority: 800, Group: 99},
+		err:    ErrGroupNotExist,
+	},
+}
+
+func Test_fwd_policy(t *testing.T) {
+	logger, err := logx.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	c, err := NewFwdClient(logger, WithBackend(bpf.BackendSyscall), WithResolver(&fakeSource{}))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var ctx = context.TODO()
+
+	for n, p := range policyTest {
+		f := func(t *testing.T) {
+			assert.ErrorIs(t, c.AddPolicy(ctx, p.policy), p.err)
+			if p.err != nil {
+				return
+			}
+			assert.Equal(t, ErrPolicyExist, c.AddPolicy(ctx, p.policy))
+			r, err := c.QryPolicy(ctx)
+			assert.Nil(t, err)
+			assert.Contains(t, r, p.policy)
+			assert.Nil(t, c.DelPolicy(ctx, p.policy.Priority))
+			assert.Equal(t, ErrPolicyNotExist, c.DelPolicy(ctx, p.policy.Priority))
+		}
+		t.Run(n, f)
+	}
+}
+
+func Test_fwd_policy_order(t *testing.T) {
+	logger, err := logx.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	c, err := NewFwdClient(logger, WithBackend(bpf.BackendSyscall), WithResolver(&fakeSource{}))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var ctx = context.TODO()
+	var prios = []uint32{30, 10, 20}
+	for _, p := range prios {
+		assert.Nil(t, c.AddPolicy(ctx, &PolicyElem{Priority: p, Table: p}))
+	}
+	r, err := c.QryPolicy(ctx)
+	assert.Nil(t, err)
+	if assert.Len(t, r, len(prios)) {
+		assert.Equal(t, []uint32{10, 20, 30}, []uint32{r[0].Priority, r[1].Priority, r[2].Priority})
+	}
+	//被策略引用的组不允许删除
+	var hops = []*NextHop{{Iface: 4, IfName: "eth4", SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e"}}
+	assert.Nil(t, c.AddGroup(ctx, 17, hops))
+	assert.Nil(t, c.AddPolicy(ctx, &PolicyElem{Priority: 40, Group: 17}))
+	assert.Equal(t, ErrGroupInUse, c.DelGroup(ctx, 17))
+	for _, p := range append(prios, 40) {
+		assert.Nil(t, c.DelPolicy(ctx, p))
+	}
+	assert.Nil(t, c.DelGroup(ctx, 17))
+}
+
+var policyTableTest = map[string]struct {
+	elem *FwdElem
+	err  error
+}{
+	"case-prefix": {
+		elem: &FwdElem{Ip: "10.22.0.0", Prefix: 16, Iface: 4, IfName: "eth4", SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e", Action: ActionRedirect, Origin: OriginStatic, Table: 10},
+	},
+	"case-host": {
+		elem: &FwdElem{Ip: "10.22.1.1", Prefix: 32, Iface: 5, IfName: "eth5", SrcMac: "08:00:27:f3:81:0f", DstMac: "f8:ff:27:f3:81:0f", Action: ActionRedirect, Origin: OriginStatic, Table: 11},
+	},
+	"case-drop": {
+		elem: &FwdElem{Ip: "10.22.2.0", Prefix: 24, SrcMac: "00:00:00:00:00:00", DstMac: "00:00:00:00:00:00", Action: ActionDrop, Origin: OriginStatic, Table: 10},
+	},
+	"case-ipv6": {
+		elem: &FwdElem{Ip: "2001:db8:e::", Prefix: 48, Iface: 4, SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e", Table: 10},
+		err:  ErrPolicyTable,
+	},
+	"case-policer": {
+		elem: &FwdElem{Ip: "10.22.3.0", Prefix: 24, Iface: 4, SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e", Table: 10, Policer: Policer{Pps: 100}},
+		err:  ErrPolicyTable,
+	},
+}
+
+func Test_fwd_policy_table(t *testing.T) {
+	logger, err := logx.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	c, err := NewFwdClient(logger, WithBackend(bpf.BackendSyscall), WithResolver(&fakeSource{}))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var ctx = context.TODO()
+
+	for n, p := range policyTableTest {
+		f := func(t *testing.T) {
+			var errs = c.UptFwdBatch(ctx, []*FwdElem{p.elem})
+			assert.ErrorIs(t, errs[0], p.err)
+			if errs[0] != nil {
+				return
+			}
+			r, err := c.QryFwd(ctx)
+			assert.Nil(t, err)
+			assert.Contains(t, r, p.elem)
+			//主表不受影响
+			var dst = fmt.Sprintf("%s/%d", p.elem.Ip, p.elem.Prefix)
+			assert.Equal(t, ErrFwdNotExist, c.DelFwd(ctx, dst))
+			assert.Nil(t, c.DelFwdTable(ctx, p.elem.Table, dst))
+			assert.Equal(t, ErrFwdNotExist, c.DelFwdTable(ctx, p.elem.Table, dst))
+		}
+		t.Run(n, f)
+	}
+}
@@ -0,0 +1,275 @@
+package fwd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/advancevillage/3rd/logx"
+)
+
+var (
+	//未解析下一跳的路由及已同步路由的下一跳MAC定期重新解析
+	routeResyncInterval = time.Second * 5
+	RouteTableMain      = uint32(254)
+)
+
+//路由变更类型
+const (
+	RouteAdd = iota
+	RouteDel
+)
+
+//rtnetlink rtm_protocol, 见 /etc/iproute2/rt_protos
+var routeProtocols = map[string]uint8{
+	"redirect": 1,
+	"kernel":   2,
+	"boot":     3,
+	"static":   4,
+	"ra":       9,
+	"dhcp":     16,
+	"zebra":    11,
+	"bird":     12,
+	"bgp":      186,
+	"isis":     187,
+	"ospf":     188,
+	"rip":      189,
+	"eigrp":    192,
+}
+
+//eg: static 或 4
+func ParseRouteProtocol(name string) (uint8, error) {
+	if p, ok := routeProtocols[name]; ok {
+		return p, nil
+	}
+	var p, err = strconv.ParseUint(name, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("route protocol %s is invalid", name)
+	}
+	return uint8(p), nil
+}
+
+//内核单播路由变更
+//Gw    下一跳 为空表示直连
+//Iface 出接口 多路径路由为0
+type RouteEvent struct {
+	Type     int
+	Dst      *net.IPNet
+	Gw       net.IP
+	Iface    uint32
+	Table    uint32
+	Protocol uint8
+}
+
+//路由事件源, 订阅后先推送已有路由再推送变更
+type IRouteSource interface {
+	IResolver
+	Subscribe(ctx context.Context) (<-chan *RouteEvent, error)
+}
+
+//Tables 为空时仅同步main表, Protocols 为空时同步全部协议
+//Exclude 优先
+type RouteFilter struct {
+	Tables           []uint32
+	ExcludeTables    []uint32
+	Protocols        []uint8
+	ExcludeProtocols []uint8
+}
+
+type IRouteSync interface {
+	Run(ctx context.Context) error
+}
+
+//内核路由与转发表同步, 仅撤销由同步安装的表项
+//
+// 直连前缀路由的目的MAC因主机而异, 交由XDP slow_fwd处理
+// 多路径路由暂不支持
+type routeSync struct {
+	logger logx.ILogger
+	fwdCli IFwd
+	src    IRouteSource
+	filter *RouteFilter
+	mu     sync.Mutex
+	routes map[string]*routeState
+}
+
+//elem 为空表示下一跳未解析
+type routeState struct {
+	ev   *RouteEvent
+	elem *FwdElem
+}
+
+func NewRouteSync(logger logx.ILogger, fwdCli IFwd, src IRouteSource, filter *RouteFilter) IRouteSync {
+	if filter == nil {
+		filter = &RouteFilter{}
+	}
+	return &routeSync{
+		logger: logger,
+		fwdCli: fwdCli,
+		src:    src,
+		filter: filter,
+		routes: make(map[string]*routeState),
+	}
+}
+
+func (r *routeSync) Run(ctx context.Context) error {
+	var ch, err = r.src.Subscribe(ctx)
+	if err != nil {
+		return err
+	}
+	var ticker = time.NewTicker(routeResyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-ch:
+			if !ok {
+				return errors.New("route source closed")
+			}
+			r.handle(ctx, ev)
+		case <-ticker.C:
+			r.resync(ctx)
+		}
+	}
+}
+
+func (r *routeSync) handle(ctx context.Context, ev *RouteEvent) {
+	if ev == nil || ev.Dst == nil || !r.match(ev) {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var k = ev.Dst.String()
+	switch ev.Type {
+	case RouteAdd:
+		var st, ok = r.routes[k]
+		if !ok {
+			st = &routeState{}
+			r.routes[k] = st
+		}
+		st.ev = ev
+		r.install(ctx, k, st)
+	case RouteDel:
+		var st, ok = r.routes[k]
+		if !ok || st.ev.Table != ev.Table {
+			return
+		}
+		r.withdraw(ctx, k, st)
+		delete(r.routes, k)
+	}
+}
+
+//重新解析下一跳MAC
+func (r *routeSync) resync(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for k, st := range r.routes {
+		r.install(ctx, k, st)
+	}
+}
+
+//表项与期望一致时不重复下发
+func (r *routeSync) install(ctx context.Context, k string, st *routeState) {
+	var elem, err = r.resolve(ctx, st.ev)
+	if err != nil {
+		r.logger.Warnw(ctx, "route sync skip", "dst", k, "err", err)
+		r.withdraw(ctx, k, st)
+		return
+	}
+	if st.elem != nil && *st.elem == *elem {
+		return
+	}
+	err = r.fwdCli.UptFwdBatch(ctx, []*FwdElem{elem})[0]
+	if err != nil {
+		r.logger.Errorw(ctx, "route sync install fail", "dst", k, "err", err)
+		return
+	}
+	r.logger.Infow(ctx, "route sync install", "dst", k, "iface", elem.Iface, "srcMac", elem.SrcMac, "dstMac", elem.DstMac)
+	st.elem = elem
+}
+
+func (r *routeSync) withdraw(ctx context.Context, k string, st *routeState) {
+	if st.elem == nil {
+		return
+	}
+	var err = r.fwdCli.DelFwd(ctx, k)
+	if err != nil && !errors.Is(err, ErrFwdNotExist) {
+		r.logger.Errorw(ctx, "route sync withdraw fail", "dst", k, "err", err)
+		return
+	}
+	r.logger.Infow(ctx, "route sync withdraw", "dst", k)
+	st.elem = nil
+}
+
+//计算出接口及MAC
+func (r *routeSync) resolve(ctx context.Context, ev *RouteEvent) (*FwdElem, error) {
+	if ev.Iface == 0 {
+		return nil, errors.New("multipath route is not supported")
+	}
+	var (
+		nh         = ev.Gw
+		ones, bits = ev.Dst.Mask.Size()
+	)
+	if len(nh) <= 0 || nh.IsUnspecified() {
+		if ones != bits {
+			return nil, errors.New("connected prefix route is not supported")
+		}
+		nh = ev.Dst.IP
+	}
+	src, err := r.src.Link(ctx, ev.Iface)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := r.src.Neighbor(ctx, ev.Iface, nh)
+	if err != nil {
+		return nil, err
+	}
+	return &FwdElem{
+		Ip:     ev.Dst.IP.String(),
+		Prefix: ones,
+		Iface:  ev.Iface,
+		SrcMac: src.String(),
+		DstMac: dst.String(),
+	}, nil
+}
+
+func (r *routeSync) match(ev *RouteEvent) bool {
+	var f = r.filter
+	if len(f.Tables) <= 0 && ev.Table != RouteTableMain {
+		return false
+	}
+	if len(f.Tables) > 0 && !containsU32(f.Tables, ev.Table) {
+		return false
+	}
+	if containsU32(f.ExcludeTables, ev.Table) {
+		return false
+	}
+	if len(f.Protocols) > 0 && !containsU8(f.Protocols, ev.Protocol) {
+		return false
+	}
+	return !containsU8(f.ExcludeProtocols, ev.Protocol)
+}
+
+func containsU32(s []uint32, v uint32) bool {
+	for i := range s {
+		if s[i] == v {
+			return true
+		}
+	}
+	return false
+}
+
+func containsU8(s []uint8, v uint8) bool {
+	for i := range s {
+		if s[i] == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,323 @@
+package fwd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/advancevillage/3rd/logx"
+	"golang.org/x/sys/unix"
+)
+
+//基于rtnetlink订阅内核路由变更
+type netlinkSource struct {
+	logger logx.ILogger
+	seq    uint32
+}
+
+func NewNetlinkSource(logger logx.ILogger) (IRouteSource, error) {
+	return &netlinkSource{logger: logger}, nil
+}
+
+func (n *netlinkSource) Subscribe(ctx context.Context) (<-chan *RouteEvent, error) {
+	var groups = uint32(1<<(unix.RTNLGRP_IPV4_ROUTE-1) | 1<<(unix.RTNLGRP_IPV6_ROUTE-1))
+	var fd, err = n.socket(groups)
+	if err != nil {
+		return nil, err
+	}
+	//先订阅再全量获取, 避免遗漏期间的变更
+	err = n.request(fd, unix.RTM_GETROUTE, unix.NLM_F_DUMP, make([]byte, unix.SizeofRtMsg))
+	if err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	var ch = make(chan *RouteEvent, 64)
+	go func() {
+		defer close(ch)
+		defer unix.Close(fd)
+		for {
+			var msgs, err = n.recv(fd)
+			if ctx.Err() != nil {
+				return
+			}
+			if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+				continue
+			}
+			if err != nil {
+				n.logger.Errorw(ctx, "netlink route recv fail", "err", err)
+				return
+			}
+			for i := range msgs {
+				var ev = n.route(&msgs[i])
+				if ev == nil {
+					continue
+				}
+				select {
+				case ch <- ev:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func (n *netlinkSource) Neighbor(ctx context.Context, iface uint32, ip net.IP) (net.HardwareAddr, error) {
+	var fd, err = n.socket(0)
+	if err != nil {
+		return nil, err
+	}
+	defer unix.Close(fd)
+
+	var (
+		body   = make([]byte, unix.SizeofNdMsg)
+		family = unix.AF_INET6
+	)
+	if ip.To4() != nil {
+		family = unix.AF_INET
+	}
+	body[0] = byte(family)
+	err = n.request(fd, unix.RTM_GETNEIGH, unix.NLM_F_DUMP, body)
+	if err != nil {
+		return nil, err
+	}
+	var mac net.HardwareAddr
+	for {
+		var msgs, err = n.recv(fd)
+		if err != nil {
+			return nil, err
+		}
+		for i := range msgs {
+			switch msgs[i].Header.Type {
+			case unix.NLMSG_DONE:
+				if mac == nil {
+					return nil, ErrNeighNotExist
+				}
+				return mac, nil
+			case unix.NLMSG_ERROR:
+				return nil, n.error(&msgs[i])
+			case unix.RTM_NEWNEIGH:
+			default:
+				continue
+			}
+			var b = msgs[i].Data
+			if len(b) < unix.SizeofNdMsg {
+				continue
+			}
+			var (
+				index = *(*int32)(unsafe.Pointer(&b[4]))
+				state = *(*uint16)(unsafe.Pointer(&b[8]))
+				attrs = n.attrs(b[unix.SizeofNdMsg:])
+			)
+			if uint32(index) != iface || state&(unix.NUD_INCOMPLETE|unix.NUD_FAILED|unix.NUD_NOARP) != 0 {
+				continue
+			}
+			if !net.IP(attrs[unix.NDA_DST]).Equal(ip) || len(attrs[unix.NDA_LLADDR]) != 6 {
+				continue
+			}
+			mac = net.HardwareAddr(append([]byte{}, attrs[unix.NDA_LLADDR]...))
+		}
+	}
+}
+
+func (n *netlinkSource) Link(ctx context.Context, iface uint32) (net.HardwareAddr, error) {
+	var i, err = net.InterfaceByIndex(int(iface))
+	if err != nil {
+		return nil, err
+	}
+	if len(i.HardwareAddr) != 6 {
+		return nil, errors.New("iface has no ethernet address")
+	}
+	return i.HardwareAddr, nil
+}
+
+func (n *netlinkSource) Index(ctx context.Context, name string) (uint32, error) {
+	var i, err = net.InterfaceByName(name)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, ErrIfaceNotExist)
+	}
+	return uint32(i.Index), nil
+}
+
+func (n *netlinkSource) Name(ctx context.Context, index uint32) (string, error) {
+	var i, err = net.InterfaceByIndex(int(index))
+	if err != nil {
+		return "", fmt.Errorf("%d: %w", index, ErrIfaceNotExist)
+	}
+	return i.Name, nil
+}
+
+//等价于 ip route get
+func (n *netlinkSource) Route(ctx context.Context, ip net.IP) (uint32, net.IP, error) {
+	var fd, err = n.socket(0)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer unix.Close(fd)
+
+	var family, addr = unix.AF_INET6, ip.To16()
+	if v4 := ip.To4(); v4 != nil {
+		family, addr = unix.AF_INET, v4
+	}
+	//rtmsg + rtattr(RTA_DST)
+	var body = make([]byte, unix.SizeofRtMsg+unix.SizeofRtAttr+len(addr))
+	body[0] = byte(family)
+	body[1] = byte(len(addr) * 8)
+	*(*uint16)(unsafe.Pointer(&body[unix.SizeofRtMsg])) = uint16(unix.SizeofRtAttr + len(addr))
+	*(*uint16)(unsafe.Pointer(&body[unix.SizeofRtMsg+2])) = unix.RTA_DST
+	copy(body[unix.SizeofRtMsg+unix.SizeofRtAttr:], addr)
+	err = n.request(fd, unix.RTM_GETROUTE, 0, body)
+	if err != nil {
+		return 0, nil, err
+	}
+	msgs, err := n.recv(fd)
+	if err != nil {
+		return 0, nil, err
+	}
+	for i := range msgs {
+		switch msgs[i].Header.Type {
+		case unix.NLMSG_ERROR:
+			return 0, nil, n.error(&msgs[i])
+		case unix.RTM_NEWROUTE:
+		default:
+			continue
+		}
+		var b = msgs[i].Data
+		if len(b) < unix.SizeofRtMsg {
+			continue
+		}
+		var (
+			attrs = n.attrs(b[unix.SizeofRtMsg:])
+			oif   = attrs[unix.RTA_OIF]
+			gw    net.IP
+		)
+		if len(oif) != 4 {
+			return 0, nil, errors.New("route has no output iface")
+		}
+		if len(attrs[unix.RTA_GATEWAY]) > 0 {
+			gw = net.IP(attrs[unix.RTA_GATEWAY])
+		}
+		return *(*uint32)(unsafe.Pointer(&oif[0])), gw, nil
+	}
+	return 0, nil, errors.New("route does not exist")
+}
+
+//接收超时用于退出时检查ctx
+func (n *netlinkSource) socket(groups uint32) (int, error) {
+	var fd, err = unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_ROUTE)
+	if err != nil {
+		return -1, err
+	}
+	err = unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: groups})
+	if err != nil {
+		unix.Close(fd)
+		return -1, err
+	}
+	var tv = unix.NsecToTimeval(time.Second.Nanoseconds())
+	err = unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv)
+	if err != nil {
+		unix.Close(fd)
+		return -1, err
+	}
+	return fd, nil
+}
+
+func (n *netlinkSource) request(fd int, typ uint16, flags uint16, body []byte) error {
+	var b = make([]byte, unix.SizeofNlMsghdr+len(body))
+	*(*unix.NlMsghdr)(unsafe.Pointer(&b[0])) = unix.NlMsghdr{
+		Len:   uint32(len(b)),
+		Type:  typ,
+		Flags: unix.NLM_F_REQUEST | flags,
+		Seq:   atomic.AddUint32(&n.seq, 1),
+	}
+	copy(b[unix.SizeofNlMsghdr:], body)
+	return unix.Sendto(fd, b, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK})
+}
+
+func (n *netlinkSource) recv(fd int) ([]syscall.NetlinkMessage, error) {
+	var b = make([]byte, 1<<16)
+	var l, _, err = unix.Recvfrom(fd, b, 0)
+	if err != nil {
+		return nil, err
+	}
+	return syscall.ParseNetlinkMessage(b[:l])
+}
+
+func (n *netlinkSource) error(m *syscall.NetlinkMessage) error {
+	if len(m.Data) < 4 {
+		return errors.New("netlink error message is invalid")
+	}
+	var code = *(*int32)(unsafe.Pointer(&m.Data[0]))
+	if code == 0 {
+		return nil
+	}
+	return syscall.Errno(-code)
+}
+
+//仅处理单播路由
+func (n *netlinkSource) route(m *syscall.NetlinkMessage) *RouteEvent {
+	var ev = &RouteEvent{}
+	switch m.Header.Type {
+	case unix.RTM_NEWROUTE:
+		ev.Type = RouteAdd
+	case unix.RTM_DELROUTE:
+		ev.Type = RouteDel
+	default:
+		return nil
+	}
+	var b = m.Data
+	if len(b) < unix.SizeofRtMsg {
+		return nil
+	}
+	var rt = (*unix.RtMsg)(unsafe.Pointer(&b[0]))
+	if rt.Type != unix.RTN_UNICAST {
+		return nil
+	}
+	var bits = 128
+	if rt.Family == unix.AF_INET {
+		bits = 32
+	}
+	var attrs = n.attrs(b[unix.SizeofRtMsg:])
+	var dst = make(net.IP, bits/8)
+	copy(dst, attrs[unix.RTA_DST])
+	ev.Dst = &net.IPNet{IP: dst, Mask: net.CIDRMask(int(rt.Dst_len), bits)}
+	ev.Table = uint32(rt.Table)
+	ev.Protocol = rt.Protocol
+	if len(attrs[unix.RTA_GATEWAY]) > 0 {
+		ev.Gw = net.IP(attrs[unix.RTA_GATEWAY])
+	}
+	if v := attrs[unix.RTA_TABLE]; len(v) == 4 {
+		ev.Table = *(*uint32)(unsafe.Pointer(&v[0]))
+	}
+	if v := attrs[unix.RTA_OIF]; len(v) == 4 {
+		ev.Iface = *(*uint32)(unsafe.Pointer(&v[0]))
+	}
+	return ev
+}
+
+//rtattr: len(2) type(2) value, 4字节对齐
+func (n *netlinkSource) attrs(b []byte) map[uint16][]byte {
+	var r = make(map[uint16][]byte)
+	for len(b) >= unix.SizeofRtAttr {
+		var (
+			l = int(*(*uint16)(unsafe.Pointer(&b[0])))
+			t = *(*uint16)(unsafe.Pointer(&b[2]))
+		)
+		if l < unix.SizeofRtAttr || l > len(b) {
+			break
+		}
+		r[t] = b[unix.SizeofRtAttr:l]
+		var a = (l + unix.RTA_ALIGNTO - 1) &^ (unix.RTA_ALIGNTO - 1)
+		if a > len(b) {
+			break
+		}
+		b = b[a:]
+	}
+	return r
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package fwd
+
+import (
+	"errors"
+
+	"github.com/advancevillage/3rd/logx"
+)
+
+func NewNetlinkSource(logger logx.ILogger) (IRouteSource, error) {
+	return nil, errors.New("netlink route sync only support linux")
+}
@@ -0,0 +1,230 @@
+package fwd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/advancevillage/3rd/logx"
+	"github.com/advancevillage/fwd/pkg/bpf"
+	"github.com/stretchr/testify/assert"
+)
+
+//模拟路由事件源
+type fakeSource struct {
+	ch    chan *RouteEvent
+	neigh map[string]net.HardwareAddr
+}
+
+func (f *fakeSource) Subscribe(ctx context.Context) (<-chan *RouteEvent, error) {
+	return f.ch, nil
+}
+
+func (f *fakeSource) Neighbor(ctx context.Context, iface uint32, ip net.IP) (net.HardwareAddr, error) {
+	var mac, ok = f.neigh[ip.String()]
+	if !ok {
+		return nil, ErrNeighNotExist
+	}
+	return mac, nil
+}
+
+func (f *fakeSource) Link(ctx context.Context, iface uint32) (net.HardwareAddr, error) {
+	return net.HardwareAddr{0x08, 0x00, 0x27, 0xf3, 0x81, byte(iface)}, nil
+}
+
+//eth1..eth16 存在
+func (f *fakeSource) Index(ctx context.Context, name string) (uint32, error) {
+	var i, err = strconv.Atoi(strings.TrimPrefix(name, "eth"))
+	if err != nil || !strings.HasPrefix(name, "eth") || i <= 0 || i > 16 {
+		return 0, ErrIfaceNotExist
+	}
+	return uint32(i), nil
+}
+
+func (f *fakeSource) Name(ctx context.Context, index uint32) (string, error) {
+	if index <= 0 || index > 16 {
+		return "", ErrIfaceNotExist
+	}
+	return fmt.Sprintf("eth%d", index), nil
+}
+
+//192.0.2.0/24 直连, 其余经192.0.2.1
+func (f *fakeSource) Route(ctx context.Context, ip net.IP) (uint32, net.IP, error) {
+	var _, onlink, _ = net.ParseCIDR("192.0.2.0/24")
+	if onlink.Contains(ip) {
+		return 4, nil, nil
+	}
+	return 4, net.ParseIP("192.0.2.1"), nil
+}
+
+func routeEvent(typ int, dst string, gw string, table uint32, protocol uint8) *RouteEvent {
+	var _, ipnet, _ = net.ParseCIDR(dst)
+	return &RouteEvent{Type: typ, Dst: ipnet, Gw: net.ParseIP(gw), Iface: 4, Table: table, Protocol: protocol}
+}
+
+var routeSyncTest = map[string]struct {
+	filter *RouteFilter
+	events []*RouteEvent
+	expect map[string]*FwdElem
+}{
+	"case-gateway": {
+		events: []*RouteEvent{
+			routeEvent(RouteAdd, "10.5.0.0/16", "192.0.2.1", 254, 4),
+			routeEvent(RouteAdd, "10.5.1.1/32", "", 254, 2),
+		},
+		expect: map[string]*FwdElem{
+			"10.5.0.0/16": {Ip: "10.5.0.0", Prefix: 16, Iface: 4, IfName: "eth4", SrcMac: "08:00:27:f3:81:04", DstMac: "f8:ff:27:f3:81:01", Action: ActionRedirect, Origin: OriginStatic},
+			"10.5.1.1/32": {Ip: "10.5.1.1", Prefix: 32, Iface: 4, IfName: "eth4", SrcMac: "08:00:27:f3:81:04", DstMac: "f8:ff:27:f3:81:02", Action: ActionRedirect, Origin: OriginStatic},
+		},
+	},
+	"case-connected-prefix": {
+		events: []*RouteEvent{
+			routeEvent(RouteAdd, "10.5.2.0/24", "", 254, 2),
+		},
+		expect: map[string]*FwdElem{},
+	},
+	"case-unresolved": {
+		events: []*RouteEvent{
+			routeEvent(RouteAdd, "10.5.3.0/24", "192.0.2.9", 254, 4),
+		},
+		expect: map[string]*FwdElem{},
+	},
+	"case-filter": {
+		filter: &RouteFilter{Tables: []uint32{100, 254}, ExcludeProtocols: []uint8{2}},
+		events: []*RouteEvent{
+			routeEvent(RouteAdd, "10.5.4.0/24", "192.0.2.1", 100, 4),
+			routeEvent(RouteAdd, "10.5.5.0/24", "192.0.2.1", 200, 4),
+			routeEvent(RouteAdd, "10.5.1.1/32", "", 254, 2),
+		},
+		expect: map[string]*FwdElem{
+			"10.5.4.0/24": {Ip: "10.5.4.0", Prefix: 24, Iface: 4, IfName: "eth4", SrcMac: "08:00:27:f3:81:04", DstMac: "f8:ff:27:f3:81:01", Action: ActionRedirect, Origin: OriginStatic},
+		},
+	},
+	"case-withdraw": {
+		events: []*RouteEvent{
+			routeEvent(RouteAdd, "10.5.6.0/24", "192.0.2.1", 254, 4),
+			routeEvent(RouteAdd, "10.5.7.0/24", "192.0.2.1", 254, 4),
+			routeEvent(RouteDel, "10.5.6.0/24", "192.0.2.1", 254, 4),
+			//其他表的删除不影响
+			routeEvent(RouteDel, "10.5.7.0/24", "192.0.2.1", 100, 4),
+		},
+		expect: map[string]*FwdElem{
+			"10.5.7.0/24": {Ip: "10.5.7.0", Prefix: 24, Iface: 4, IfName: "eth4", SrcMac: "08:00:27:f3:81:04", DstMac: "f8:ff:27:f3:81:01", Action: ActionRedirect, Origin: OriginStatic},
+		},
+	},
+}
+
+func Test_route_sync(t *testing.T) {
+	logger, err := logx.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var src = &fakeSource{
+		ch: make(chan *RouteEvent),
+		neigh: map[string]net.HardwareAddr{
+			"192.0.2.1": {0xf8, 0xff, 0x27, 0xf3, 0x81, 0x01},
+			"10.5.1.1":  {0xf8, 0xff, 0x27, 0xf3, 0x81, 0x02},
+		},
+	}
+	c, err := NewFwdClient(logger, WithBackend(bpf.BackendSyscall), WithResolver(src))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var ctx = context.TODO()
+	_, err = c.Tables(ctx)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	for n, p := range routeSyncTest {
+		f := func(t *testing.T) {
+			var r = NewRouteSync(logger, c, src, p.filter).(*routeSync)
+			for _, ev := range p.events {
+				r.handle(ctx, ev)
+			}
+			elems, err := c.QryFwd(ctx)
+			assert.Nil(t, err)
+			var actual = make(map[string]*FwdElem)
+			for _, e := range elems {
+				if e.Ip[:5] != "10.5." {
+					continue
+				}
+				actual[(&fwdCli{}).prefix(e)] = e
+			}
+			assert.Equal(t, p.expect, actual)
+			//清理
+			for k := range r.routes {
+				r.handle(ctx, &RouteEvent{Type: RouteDel, Dst: r.routes[k].ev.Dst, Table: r.routes[k].ev.Table, Protocol: r.routes[k].ev.Protocol})
+			}
+			elems, err = c.QryFwd(ctx)
+			assert.Nil(t, err)
+			for _, e := range elems {
+				assert.NotEqual(t, "10.5.", e.Ip[:5])
+			}
+		}
+		t.Run(n, f)
+	}
+}
+
+//下一跳解析后重新下发
+func Test_route_resync(t *testing.T) {
+	logger, err := logx.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var src = &fakeSource{neigh: map[string]net.HardwareAddr{}}
+	c, err := NewFwdClient(logger, WithBackend(bpf.BackendSyscall), WithResolver(src))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var (
+		ctx = context.TODO()
+		r   = NewRouteSync(logger, c, src, nil).(*routeSync)
+		ev  = routeEvent(RouteAdd, "10.6.0.0/16", "192.0.2.1", 254, 4)
+	)
+	_, err = c.Tables(ctx)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	r.handle(ctx, ev)
+	assert.Nil(t, r.routes["10.6.0.0/16"].elem)
+
+	src.neigh["192.0.2.1"] = net.HardwareAddr{0xf8, 0xff, 0x27, 0xf3, 0x81, 0x01}
+	r.resync(ctx)
+	assert.Equal(t, "f8:ff:27:f3:81:01", r.routes["10.6.0.0/16"].elem.DstMac)
+
+	//邻居消失后撤销
+	delete(src.neigh, "192.0.2.1")
+	r.resync(ctx)
+	assert.Nil(t, r.routes["10.6.0.0/16"].elem)
+	assert.Equal(t, ErrFwdNotExist, c.DelFwd(ctx, "10.6.0.0/16"))
+}
+
+var routeProtocolTest = map[string]struct {
+	name string
+	exp  uint8
+	err  bool
+}{
+	"case-name":    {name: "static", exp: 4},
+	"case-number":  {name: "186", exp: 186},
+	"case-invalid": {name: "foo", err: true},
+}
+
+func Test_parse_route_protocol(t *testing.T) {
+	for n, p := range routeProtocolTest {
+		f := func(t *testing.T) {
+			var v, err = ParseRouteProtocol(p.name)
+			assert.Equal(t, p.err, err != nil)
+			assert.Equal(t, p.exp, v)
+		}
+		t.Run(n, f)
+	}
+}
@@ -0,0 +1,277 @@
+package fwd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+var (
+	ErrSnapshotVersion  = errors.New("snapshot version is not supported")
+	ErrSnapshotChecksum = errors.New("snapshot checksum mismatch")
+)
+
+//快照格式变更时递增snapshotVersion
+// 1  各字段与version同级, 校验和由Go结构体重新编码计算, 字段增加后无法校验
+// 2  校验和为body原始字节(去除空白)的sha256, 与结构体字段无关
+var (
+	snapshotVersion   = 2
+	snapshotVersionV1 = 1
+)
+
+//转发表快照, 用于重启及跨主机恢复
+type snapshot struct {
+	Version  int             `json:"version"`
+	Time     time.Time       `json:"time"`
+	Checksum string          `json:"checksum"`
+	Body     json.RawMessage `json:"body"`
+}
+
+//版本1快照, 按写入时的字段顺序拼接原始字节校验
+type snapshotV1 struct {
+	Checksum string          `json:"checksum"`
+	Groups   json.RawMessage `json:"groups"`
+	Tunnels  json.RawMessage `json:"tunnels"`
+	Decaps   json.RawMessage `json:"decaps"`
+	Entries  json.RawMessage `json:"entries"`
+	Policies json.RawMessage `json:"policies"`
+}
+
+type snapshotBody struct {
+	Groups   []*GroupElem  `json:"groups"`
+	Tunnels  []*TunnelElem `json:"tunnels,omitempty"`
+	Decaps   []*DecapElem  `json:"decaps,omitempty"`
+	Entries  []*FwdElem    `json:"entries"`
+	Policies []*PolicyElem `json:"policies,omitempty"`
+}
+
+//导出下一跳组、隧道、静态转发表项及租约、限速、策略路由, 不包含XDP计数及学习表项
+func (d *fwdCli) Snapshot(ctx context.Context, w io.Writer) (int, error) {
+	var groups, err = d.QryGroup(ctx)
+	if err != nil {
+		return 0, err
+	}
+	tunnels, err := d.QryTunnel(ctx)
+	if err != nil {
+		return 0, err
+	}
+	decaps, err := d.QryDecap(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range decaps {
+		e.Packets, e.Bytes = 0, 0
+	}
+	all, err := d.query(ctx)
+	if err != nil {
+		return 0, err
+	}
+	//学习表项可由XDP重新学习, 恢复时会被当作静态表项下发
+	var entries = make([]*FwdElem, 0, len(all))
+	for _, e := range all {
+		if e.Origin != OriginLearned {
+			entries = append(entries, e)
+		}
+	}
+	err = d.leases(ctx, entries)
+	if err != nil {
+		return 0, err
+	}
+	err = d.policers(ctx, entries)
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range entries {
+		e.Packets, e.Bytes = 0, 0
+		e.Policer.ExceedPkts, e.Policer.ExceedBytes = 0, 0
+	}
+	policies, err := d.QryPolicy(ctx)
+	if err != nil {
+		return 0, err
+	}
+	body, err := json.Marshal(&snapshotBody{Groups: groups, Tunnels: tunnels, Decaps: decaps, Entries: entries, Policies: policies})
+	if err != nil {
+		return 0, err
+	}
+	var s = &snapshot{
+		Version:  snapshotVersion,
+		Time:     time.Now(),
+		Checksum: checksum(body),
+		Body:     body,
+	}
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	_, err = w.Write(b)
+	if err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+//先恢复下一跳组、隧道再恢复转发表项, 已存在的表项被覆盖
+//策略路由在转发表项之后整体替换, 快照不含策略路由时保持不变
+//带租约的表项按原到期时间恢复, 已到期的由过期清理删除
+//返回成功恢复的转发表项数
+func (d *fwdCli) Restore(ctx context.Context, r io.Reader) (int, error) {
+	var b, err = ioutil.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	s, err := parseSnapshot(b)
+	if err != nil {
+		return 0, err
+	}
+	for _, g := range s.Groups {
+		if g == nil {
+			continue
+		}
+		d.groupMu.Lock()
+		err = d.updateGroup(ctx, g.Group, g.Hops)
+		d.groupMu.Unlock()
+		if err != nil {
+			return 0, fmt.Errorf("restore group %d: %w", g.Group, err)
+		}
+	}
+	for _, t := range s.Tunnels {
+		if t == nil {
+			continue
+		}
+		err = d.prepare(ctx, d.tunCli)
+		if err == nil {
+			err = d.updateTunnel(ctx, t)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("restore tunnel %d: %w", t.Tunnel, err)
+		}
+	}
+	for _, e := range s.Decaps {
+		if e == nil {
+			continue
+		}
+		err = d.AddDecap(ctx, e)
+		if err != nil {
+			return 0, fmt.Errorf("restore decap %s %s: %w", e.Type, e.Dst, err)
+		}
+	}
+	var (
+		n    = 0
+		errs = d.UptFwdBatch(ctx, s.Entries)
+	)
+	err = nil
+	for i := range errs {
+		if errs[i] == nil {
+			n++
+			d.restoreLease(ctx, s.Entries[i])
+			continue
+		}
+		d.logger.Errorw(ctx, "restore forward fail", "ip", s.Entries[i].Ip, "prefix", s.Entries[i].Prefix, "err", errs[i])
+		if err == nil {
+			err = fmt.Errorf("restore %s: %w", d.prefix(s.Entries[i]), errs[i])
+		}
+	}
+	if len(s.Policies) <= 0 {
+		return n, err
+	}
+	d.groupMu.Lock()
+	defer d.groupMu.Unlock()
+	var values = make([][]byte, 0, len(s.Policies))
+	for _, p := range s.Policies {
+		if p == nil {
+			continue
+		}
+		var v, e = d.policyValue(ctx, p)
+		if e != nil {
+			return n, fmt.Errorf("restore policy %d: %w", p.Priority, e)
+		}
+		values = append(values, v)
+	}
+	if e := d.setPolicies(ctx, values); e != nil {
+		return n, e
+	}
+	return n, err
+}
+
+func (d *fwdCli) restoreLease(ctx context.Context, e *FwdElem) {
+	if e.Deadline <= 0 {
+		return
+	}
+	var k, err = d.checkkey(d.prefix(e))
+	if err != nil {
+		return
+	}
+	var deadline = time.Unix(e.Deadline, 0)
+	err = d.setLease(ctx, k, deadline, time.Until(deadline).Round(time.Second))
+	if err != nil {
+		d.logger.Errorw(ctx, "restore forward lease fail", "ip", e.Ip, "prefix", e.Prefix, "err", err)
+	}
+}
+
+//校验后解析快照内容, 校验基于文件中的原始字节
+func parseSnapshot(b []byte) (*snapshotBody, error) {
+	var s = &snapshot{}
+	var err = json.Unmarshal(b, s)
+	if err != nil {
+		return nil, err
+	}
+	var raw []byte
+	switch s.Version {
+	case snapshotVersion:
+		raw = s.Body
+	case snapshotVersionV1:
+		var v1 = &snapshotV1{}
+		err = json.Unmarshal(b, v1)
+		if err != nil {
+			return nil, err
+		}
+		raw = v1.body()
+	default:
+		return nil, ErrSnapshotVersion
+	}
+	var buf = &bytes.Buffer{}
+	err = json.Compact(buf, raw)
+	if err != nil {
+		return nil, err
+	}
+	if checksum(buf.Bytes()) != s.Checksum {
+		return nil, ErrSnapshotChecksum
+	}
+	var body = &snapshotBody{}
+	err = json.Unmarshal(buf.Bytes(), body)
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+//还原版本1写入时编码的body, tunnels/decaps/policies为空时未编码
+func (s *snapshotV1) body() []byte {
+	var b = &bytes.Buffer{}
+	b.WriteString(`{"groups":`)
+	b.Write(s.Groups)
+	for _, f := range []struct {
+		name string
+		raw  json.RawMessage
+	}{{"tunnels", s.Tunnels}, {"decaps", s.Decaps}, {"entries", s.Entries}, {"policies", s.Policies}} {
+		if len(f.raw) <= 0 {
+			continue
+		}
+		fmt.Fprintf(b, `,"%s":`, f.name)
+		b.Write(f.raw)
+	}
+	b.WriteString("}")
+	return b.Bytes()
+}
+
+func checksum(b []byte) string {
+	var sum = sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,141 @@
+package fwd
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/advancevillage/3rd/logx"
+	"github.com/advancevillage/fwd/pkg/bpf"
+	"github.com/stretchr/testify/assert"
+)
+
+//版本1快照, 字段为当时的FwdElem/NextHop
+var snapshotV1Test = `{
+  "version": 1,
+  "time": "2024-03-01T10:00:00+08:00",
+  "checksum": "53f8482896cc19aee3a85f8b0e9f96c295cf79927f87e84d7fe99ba6a5a85725",
+  "groups": [
+    {
+      "Group": 41,
+      "Hops": [
+        {
+          "Iface": 4,
+          "SrcMac": "08:00:27:f3:81:0e",
+          "DstMac": "f8:ff:27:f3:81:0e"
+        }
+      ]
+    }
+  ],
+  "entries": [
+    {
+      "Ip": "10.4.1.1",
+      "Prefix": 32,
+      "Iface": 4,
+      "SrcMac": "08:00:27:f3:81:0e",
+      "DstMac": "f8:ff:27:f3:81:0e",
+      "Group": 0,
+      "Packets": 0,
+      "Bytes": 0
+    },
+    {
+      "Ip": "10.4.2.0",
+      "Prefix": 24,
+      "Iface": 0,
+      "SrcMac": "",
+      "DstMac": "",
+      "Group": 41,
+      "Packets": 0,
+      "Bytes": 0
+    }
+  ]
+}`
+
+var snapshotTest = map[string]struct {
+	modify func(string) string
+	err    error
+}{
+	"case-ok": {
+		modify: func(s string) string { return s },
+	},
+	"case-checksum": {
+		modify: func(s string) string { return strings.Replace(s, "10.4.1.1", "10.4.1.2", 1) },
+		err:    ErrSnapshotChecksum,
+	},
+	"case-version": {
+		modify: func(s string) string { return strings.Replace(s, `"version": 2`, `"version": 9`, 1) },
+		err:    ErrSnapshotVersion,
+	},
+	"case-v1": {
+		modify: func(s string) string { return snapshotV1Test },
+	},
+	"case-v1-checksum": {
+		modify: func(s string) string { return strings.Replace(snapshotV1Test, `"Group": 41,`, `"Group": 42,`, 1) },
+		err:    ErrSnapshotChecksum,
+	},
+}
+
+func Test_fwd_snapshot(t *testing.T) {
+	logger, err := logx.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	c, err := NewFwdClient(logger, WithBackend(bpf.BackendSyscall), WithResolver(&fakeSource{}))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var ctx = context.TODO()
+	_, err = c.Tables(ctx)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var hops = []*NextHop{{Iface: 4, IfName: "eth4", SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e"}}
+	_ = c.DelFwd(ctx, "10.4.2.0/24")
+	_ = c.DelGroup(ctx, 41)
+	assert.Nil(t, c.AddGroup(ctx, 41, hops))
+	assert.Nil(t, c.UptFwd(ctx, "10.4.1.1", 4, "08:00:27:f3:81:0e", "f8:ff:27:f3:81:0e"))
+	assert.Nil(t, c.UptFwdGroup(ctx, "10.4.2.0/24", 41))
+	expect, err := c.QryFwd(ctx)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	var buf = &bytes.Buffer{}
+	n, err := c.Snapshot(ctx, buf)
+	assert.Nil(t, err)
+	assert.Equal(t, len(expect), n)
+
+	for name, p := range snapshotTest {
+		f := func(t *testing.T) {
+			assert.Nil(t, c.DelFwd(ctx, "10.4.1.1"))
+			assert.Nil(t, c.DelFwd(ctx, "10.4.2.0/24"))
+			assert.Nil(t, c.DelGroup(ctx, 41))
+
+			n, err := c.Restore(ctx, strings.NewReader(p.modify(buf.String())))
+			assert.Equal(t, p.err, err)
+			if err != nil {
+				//恢复失败时不修改转发表, 供下一用例删除
+				assert.Nil(t, c.AddGroup(ctx, 41, hops))
+				assert.Nil(t, c.UptFwd(ctx, "10.4.1.1", 4, "08:00:27:f3:81:0e", "f8:ff:27:f3:81:0e"))
+				assert.Nil(t, c.UptFwdGroup(ctx, "10.4.2.0/24", 41))
+				return
+			}
+			assert.Equal(t, len(expect), n)
+			actual, err := c.QryFwd(ctx)
+			assert.Nil(t, err)
+			assert.ElementsMatch(t, expect, actual)
+			groups, err := c.QryGroup(ctx)
+			assert.Nil(t, err)
+			assert.Contains(t, groups, &GroupElem{Group: 41, Hops: hops})
+		}
+		t.Run(name, f)
+	}
+	assert.Nil(t, c.DelFwd(ctx, "10.4.1.1"))
+	assert.Nil(t, c.DelFwd(ctx, "10.4.2.0/24"))
+	assert.Nil(t, c.DelGroup(ctx, 41))
+}
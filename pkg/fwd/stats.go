@@ -0,0 +1,257 @@
+package fwd
+
+import (
+	"context"
+	"sort"
+
+	"github.com/advancevillage/fwd/pkg/bpf"
+)
+
+//转发计数 Per-CPU Map, XDP无锁累加, 控制面按CPU汇总
+// sfwd  BPF_MAP_TYPE_PERCPU_ARRAY     全局计数   key: 计数索引          value: uint64
+// ifwd  BPF_MAP_TYPE_PERCPU_HASH      出接口计数 key: ifindex           value: pkts(8) + bytes(8)
+// cfwd  BPF_MAP_TYPE_LRU_PERCPU_HASH  表项计数   key: prefixlen(4) + addr value: pkts(8) + bytes(8)
+var (
+	statKeySize   = int(0x04)
+	statValueSize = int(0x08)
+	statMaxSize   = int(0x10)
+	statName      = "sfwd"
+	ifaceKeySize  = int(0x04)
+	ifaceMaxSize  = int(1024)
+	ifaceName     = "ifwd"
+	cntValueSize  = int(0x10)
+	cntName       = "cfwd"
+	cntName6      = "cfwd6"
+)
+
+//全局计数索引, 与fwd.bpf.c保持一致
+const (
+	statFast = iota
+	statLpm
+	statSlow
+	statMiss
+	statDrop
+	statBlackhole
+	statPass
+	statAclDeny
+	statBlocked
+	statPoliced
+	statVlan
+	statEncap
+	statDecap
+	statPolicy
+)
+
+type IfaceStats struct {
+	Iface   uint32
+	Packets uint64
+	Bytes   uint64
+}
+
+//FastHit hfwd命中
+//LpmHit  lfwd命中
+//SlowHit bpf_fib_lookup命中
+//FibMiss bpf_fib_lookup未命中, 交由内核协议栈
+//Drop    报文不完整丢弃
+//Blackhole 黑洞路由丢弃
+//Pass    表项动作为pass交由内核协议栈
+//AclDeny ACL拒绝丢弃
+//Blocked 源地址黑名单丢弃
+//Policed 表项限速超限, 按超限动作丢弃或交由内核
+//VlanMiss 入VLAN不匹配或带标签报文未命中表项, 交由内核
+//Encap   隧道封装
+//Decap   隧道解封装
+//Policy  策略路由命中
+//Ifaces  按出接口统计重定向
+type StatsElem struct {
+	FastHit   uint64
+	LpmHit    uint64
+	SlowHit   uint64
+	FibMiss   uint64
+	Drop      uint64
+	Blackhole uint64
+	Pass      uint64
+	AclDeny   uint64
+	Blocked   uint64
+	Policed   uint64
+	VlanMiss  uint64
+	Encap     uint64
+	Decap     uint64
+	Policy    uint64
+	Ifaces    []*IfaceStats
+}
+
+//Size     当前表项数
+//Capacity 最大表项数
+type TableUsage struct {
+	Name     string
+	Size     int
+	Capacity int
+}
+
+//转发表、下一跳组及策略表的容量使用情况, 表未创建时Size为0
+//需遍历各表全部表项, 开销随表项数增长, 不宜按采集频率调用
+func (d *fwdCli) QryUsage(ctx context.Context) ([]*TableUsage, error) {
+	var (
+		r      = make([]*TableUsage, 0, 5)
+		names  = []string{name, name6, lpmName, lpmName6, groupName, policyTableName}
+		caps   = []int{maxSize, maxSize, maxSize, maxSize, groupMaxSize, maxSize}
+		tables = []bpf.ITable{d.tableCli, d.tableCli6, d.lpmCli, d.lpmCli6, d.groupCli, d.pbrTblCli}
+	)
+	for i, t := range tables {
+		var u = &TableUsage{Name: names[i], Capacity: caps[i]}
+		if t.ExistTable(ctx) {
+			var kv, err = t.QueryTable(ctx)
+			if err != nil {
+				return r, err
+			}
+			u.Size = len(kv)
+		}
+		r = append(r, u)
+	}
+	return r, nil
+}
+
+func (d *fwdCli) QryStats(ctx context.Context) (*StatsElem, error) {
+	var r = &StatsElem{Ifaces: make([]*IfaceStats, 0, 2)}
+	//1. 全局计数
+	if d.statCli.ExistTable(ctx) {
+		var kv, err = d.statCli.QueryTable(ctx)
+		if err != nil {
+			return r, err
+		}
+		for i := range kv {
+			var v = d.sum(kv[i].Value, statValueSize, 0)
+			switch d.u32(kv[i].Key) {
+			case statFast:
+				r.FastHit = v
+			case statLpm:
+				r.LpmHit = v
+			case statSlow:
+				r.SlowHit = v
+			case statMiss:
+				r.FibMiss = v
+			case statDrop:
+				r.Drop = v
+			case statBlackhole:
+				r.Blackhole = v
+			case statPass:
+				r.Pass = v
+			case statAclDeny:
+				r.AclDeny = v
+			case statBlocked:
+				r.Blocked = v
+			case statPoliced:
+				r.Policed = v
+			case statVlan:
+				r.VlanMiss = v
+			case statEncap:
+				r.Encap = v
+			case statDecap:
+				r.Decap = v
+			case statPolicy:
+				r.Policy = v
+			}
+		}
+	}
+	//2. 出接口计数
+	if d.ifaceCli.ExistTable(ctx) {
+		var kv, err = d.ifaceCli.QueryTable(ctx)
+		if err != nil {
+			return r, err
+		}
+		for i := range kv {
+			r.Ifaces = append(r.Ifaces, &IfaceStats{
+				Iface:   d.u32(kv[i].Key),
+				Packets: d.sum(kv[i].Value, cntValueSize, 0),
+				Bytes:   d.sum(kv[i].Value, cntValueSize, 8),
+			})
+		}
+		sort.Slice(r.Ifaces, func(i, j int) bool { return r.Ifaces[i].Iface < r.Ifaces[j].Iface })
+	}
+	return r, nil
+}
+
+//按表项key(prefixlen + addr)关联计数
+func (d *fwdCli) counters(ctx context.Context, elems []*FwdElem) error {
+	var cnt = make(map[string][]byte)
+	for _, t := range []bpf.ITable{d.cntCli, d.cntCli6} {
+		if !t.ExistTable(ctx) {
+			continue
+		}
+		var kv, err = t.QueryTable(ctx)
+		if err != nil {
+			return err
+		}
+		for i := range kv {
+			cnt[string(kv[i].Key)] = kv[i].Value
+		}
+	}
+	for _, e := range elems {
+		if e.Table > 0 {
+			continue
+		}
+		var k, err = d.checkkey(d.prefix(e))
+		if err != nil {
+			continue
+		}
+		var v, ok = cnt[string(d.cntKey(k))]
+		if !ok {
+			continue
+		}
+		e.Packets = d.sum(v, cntValueSize, 0)
+		e.Bytes = d.sum(v, cntValueSize, 8)
+	}
+	return nil
+}
+
+//表项删除后清理计数, 避免同一目的地址重新下发时沿用旧计数
+func (d *fwdCli) clearCounters(ctx context.Context, keys [][]byte) {
+	var groups = make(map[bpf.ITable][][]byte)
+	for _, k := range keys {
+		var ck = d.cntKey(k)
+		switch len(ck) {
+		case lpmKeySize:
+			groups[d.cntCli] = append(groups[d.cntCli], ck)
+		case lpmKey6Size:
+			groups[d.cntCli6] = append(groups[d.cntCli6], ck)
+		}
+	}
+	for t, kk := range groups {
+		if !t.ExistTable(ctx) {
+			continue
+		}
+		_ = t.DeleteBatchTable(ctx, kk)
+	}
+}
+
+//主机路由转换为 prefixlen + addr
+func (d *fwdCli) cntKey(k []byte) []byte {
+	switch len(k) {
+	case keySize, key6Size:
+		var ck = make([]byte, 4+len(k))
+		ck[0] = byte(len(k) * 8)
+		copy(ck[4:], k)
+		return ck
+	default:
+		return k
+	}
+}
+
+//Per-CPU value 每个CPU按8字节对齐, 累加各CPU上off处的uint64
+func (d *fwdCli) sum(v []byte, size int, off int) uint64 {
+	var (
+		r      = uint64(0)
+		stride = (size + 7) &^ 7
+	)
+	for i := 0; i+off+8 <= len(v); i += stride {
+		var b = v[i+off : i+off+8]
+		r += uint64(b[0]) | uint64(b[1])<<8 | uint64(b[2])<<16 | uint64(b[3])<<24 |
+			uint64(b[4])<<32 | uint64(b[5])<<40 | uint64(b[6])<<48 | uint64(b[7])<<56
+	}
+	return r
+}
+
+func (d *fwdCli) u32(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24
+}
@@ -0,0 +1,127 @@
+package fwd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/advancevillage/3rd/logx"
+	"github.com/advancevillage/fwd/pkg/bpf"
+	"github.com/stretchr/testify/assert"
+)
+
+var sumTest = map[string]struct {
+	v    []byte
+	size int
+	off  int
+	exp  uint64
+}{
+	"case-u64": {
+		v:    []byte{0x01, 0, 0, 0, 0, 0, 0, 0, 0x02, 0, 0, 0, 0, 0, 0, 0},
+		size: 8,
+		off:  0,
+		exp:  3,
+	},
+	"case-cnt-bytes": {
+		v: []byte{
+			0x01, 0, 0, 0, 0, 0, 0, 0, 0x40, 0, 0, 0, 0, 0, 0, 0,
+			0x02, 0, 0, 0, 0, 0, 0, 0, 0x00, 0x01, 0, 0, 0, 0, 0, 0,
+		},
+		size: 16,
+		off:  8,
+		exp:  0x140,
+	},
+}
+
+func Test_stats_sum(t *testing.T) {
+	var c = &fwdCli{}
+	for n, p := range sumTest {
+		f := func(t *testing.T) {
+			assert.Equal(t, p.exp, c.sum(p.v, p.size, p.off))
+		}
+		t.Run(n, f)
+	}
+}
+
+var statsTest = map[string]struct {
+	dstIp string
+	key   []byte
+	pkts  uint64
+	bytes uint64
+}{
+	"case-host": {
+		dstIp: "10.3.1.1",
+		key:   []byte{32, 0, 0, 0, 10, 3, 1, 1},
+		pkts:  3,
+		bytes: 300,
+	},
+	"case-prefix": {
+		dstIp: "10.3.0.0/16",
+		key:   []byte{16, 0, 0, 0, 10, 3, 0, 0},
+		pkts:  5,
+		bytes: 500,
+	},
+}
+
+func Test_fwd_stats(t *testing.T) {
+	logger, err := logx.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	c, err := NewFwdClient(logger, WithBackend(bpf.BackendSyscall), WithResolver(&fakeSource{}))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var ctx = context.TODO()
+	_, err = c.Tables(ctx)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var d = c.(*fwdCli)
+	var cpus = bpf.PossibleCPUs()
+
+	for n, p := range statsTest {
+		f := func(t *testing.T) {
+			assert.Nil(t, c.UptFwd(ctx, p.dstIp, 4, "08:00:27:f3:81:0e", "f8:ff:27:f3:81:0e"))
+			//1. 模拟XDP在CPU0累加计数
+			var v = make([]byte, cpus*cntValueSize)
+			v[0] = byte(p.pkts)
+			v[8] = byte(p.bytes)
+			v[9] = byte(p.bytes >> 8)
+			assert.Nil(t, d.cntCli.UpdateTable(ctx, p.key, v))
+
+			elems, err := c.QryFwd(ctx)
+			if err != nil {
+				t.Fatal(err)
+				return
+			}
+			var found = false
+			for _, e := range elems {
+				if d.prefix(e) != p.dstIp && e.Ip != p.dstIp {
+					continue
+				}
+				found = true
+				assert.Equal(t, p.pkts, e.Packets)
+				assert.Equal(t, p.bytes, e.Bytes)
+			}
+			assert.True(t, found)
+			//2. 删除表项后计数清理
+			assert.Nil(t, c.DelFwd(ctx, p.dstIp))
+			_, err = d.cntCli.LookupTable(ctx, p.key)
+			assert.Equal(t, bpf.ErrKeyNotExist, err)
+		}
+		t.Run(n, f)
+	}
+	//3. 全局计数
+	var v = make([]byte, cpus*statValueSize)
+	v[0] = 7
+	assert.Nil(t, d.statCli.UpdateTable(ctx, []byte{statSlow, 0, 0, 0}, v))
+	stats, err := c.QryStats(ctx)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	assert.Equal(t, uint64(7), stats.SlowHit)
+}
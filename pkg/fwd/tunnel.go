@@ -0,0 +1,340 @@
+package fwd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/advancevillage/fwd/pkg/bpf"
+)
+
+var (
+	ErrTunnel         = errors.New("tunnel is invalid")
+	ErrTunnelExist    = errors.New("tunnel already exists")
+	ErrTunnelNotExist = errors.New("tunnel does not exist")
+	ErrTunnelInUse    = errors.New("tunnel is referenced by forward entries")
+	ErrDecapNotExist  = errors.New("tunnel decap rule does not exist")
+)
+
+//隧道封装下一跳 BPF_MAP_TYPE_HASH, 仅支持IPv4外层
+// tfwd  key: 隧道ID(4), 0保留表示不使用隧道
+//       value: ifindex(4) + smac(6) + dmac(6) + saddr(4) + daddr(4) + key(4) + type(1) + flags(1) + port(2)
+// dfwd  key: daddr(4) + key(4) + type(1) + pad(1) + port(2)
+//       value: pkts(8) + bytes(8) 解封装计数
+//转发表项 value[tunnelOff] 为隧道ID
+var (
+	tunnelOff       = int(0x1c)
+	tunnelKeySize   = int(0x04)
+	tunnelValueSize = int(0x20)
+	tunnelMaxSize   = int(1024)
+	tunnelName      = "tfwd"
+	decapKeySize    = int(0x0c)
+	decapMaxSize    = int(1024)
+	decapName       = "dfwd"
+	vxlanPort       = uint16(4789)
+	vxlanMaxVni     = uint32(1<<24 - 1)
+	tunnelFlagKey   = byte(0x01)
+	tunnelIpip      = byte(0x01)
+	tunnelGre       = byte(0x02)
+	tunnelVxlan     = byte(0x03)
+)
+
+//隧道类型, 与fwd.bpf.c TUN_IPIP/TUN_GRE/TUN_VXLAN保持一致
+const (
+	TunnelIpip  = "ipip"
+	TunnelGre   = "gre"
+	TunnelVxlan = "vxlan"
+)
+
+//Src/Dst 外层源/目的IPv4地址
+//Key     VXLAN VNI(24位)或GRE key, GRE为0时不携带key, IPIP须为0
+//Port    VXLAN目的端口 0时取4789
+//Iface/IfName/SrcMac/DstMac 外层下一跳, IfName非空时优先于Iface
+type TunnelElem struct {
+	Tunnel uint32
+	Type   string
+	Src    string
+	Dst    string
+	Key    uint32
+	Port   uint16
+	Iface  uint32
+	IfName string
+	SrcMac string
+	DstMac string
+}
+
+//Dst 本端隧道端点IPv4地址, 外层目的地址与之一致且类型、Key、Port均匹配时解封装
+//Packets/Bytes 解封装计数 仅查询
+type DecapElem struct {
+	Type    string
+	Dst     string
+	Key     uint32
+	Port    uint16
+	Packets uint64
+	Bytes   uint64
+}
+
+func (d *fwdCli) AddTunnel(ctx context.Context, t *TunnelElem) error {
+	var _, err = d.lookupTunnel(ctx, t.Tunnel)
+	switch {
+	case err == nil:
+		return ErrTunnelExist
+	case errors.Is(err, ErrTunnelNotExist):
+	default:
+		return err
+	}
+	return d.updateTunnel(ctx, t)
+}
+
+func (d *fwdCli) UptTunnel(ctx context.Context, t *TunnelElem) error {
+	var _, err = d.lookupTunnel(ctx, t.Tunnel)
+	if err != nil {
+		return err
+	}
+	return d.updateTunnel(ctx, t)
+}
+
+//仍被转发表项引用的隧道不允许删除
+func (d *fwdCli) DelTunnel(ctx context.Context, tunnel uint32) error {
+	var _, err = d.lookupTunnel(ctx, tunnel)
+	if err != nil {
+		return err
+	}
+	elems, err := d.query(ctx)
+	if err != nil {
+		return err
+	}
+	for i := range elems {
+		if elems[i].Tunnel == tunnel {
+			return ErrTunnelInUse
+		}
+	}
+	err = d.tunCli.DeleteTable(ctx, d.u32Key(tunnel))
+	if errors.Is(err, bpf.ErrKeyNotExist) {
+		return ErrTunnelNotExist
+	}
+	return err
+}
+
+func (d *fwdCli) QryTunnel(ctx context.Context) ([]*TunnelElem, error) {
+	var r = make([]*TunnelElem, 0, 2)
+	if !d.tunCli.ExistTable(ctx) {
+		return r, nil
+	}
+	var kv, err = d.tunCli.QueryTable(ctx)
+	if err != nil {
+		return r, err
+	}
+	for i := range kv {
+		var rr = d.tunnelElem(kv[i].Value)
+		rr.Tunnel = d.u32(kv[i].Key)
+		if d.resolver != nil {
+			rr.IfName, _ = d.resolver.Name(ctx, rr.Iface)
+		}
+		r = append(r, rr)
+	}
+	return r, nil
+}
+
+//已存在的规则被覆盖, 计数清零
+func (d *fwdCli) AddDecap(ctx context.Context, e *DecapElem) error {
+	var k, err = d.decapKey(e)
+	if err != nil {
+		return err
+	}
+	err = d.prepare(ctx, d.decapCli)
+	if err != nil {
+		return err
+	}
+	return d.decapCli.UpdateTable(ctx, k, make([]byte, cntValueSize))
+}
+
+func (d *fwdCli) DelDecap(ctx context.Context, e *DecapElem) error {
+	var k, err = d.decapKey(e)
+	if err != nil {
+		return err
+	}
+	if !d.decapCli.ExistTable(ctx) {
+		return ErrDecapNotExist
+	}
+	err = d.decapCli.DeleteTable(ctx, k)
+	if errors.Is(err, bpf.ErrKeyNotExist) {
+		return ErrDecapNotExist
+	}
+	return err
+}
+
+func (d *fwdCli) QryDecap(ctx context.Context) ([]*DecapElem, error) {
+	var r = make([]*DecapElem, 0, 2)
+	if !d.decapCli.ExistTable(ctx) {
+		return r, nil
+	}
+	var kv, err = d.decapCli.QueryTable(ctx)
+	if err != nil {
+		return r, err
+	}
+	for i := range kv {
+		var (
+			kk = kv[i].Key
+			vv = kv[i].Value
+		)
+		r = append(r, &DecapElem{
+			Type:    d.tunnelType(kk[8]),
+			Dst:     net.IP(kk[0:4]).String(),
+			Key:     d.u32(kk[4:]),
+			Port:    uint16(kk[10]) | uint16(kk[11])<<8,
+			Packets: d.u64(vv[0:]),
+			Bytes:   d.u64(vv[8:]),
+		})
+	}
+	return r, nil
+}
+
+func (d *fwdCli) lookupTunnel(ctx context.Context, tunnel uint32) (*TunnelElem, error) {
+	if tunnel == 0 {
+		return nil, errors.New("invalid tunnel id")
+	}
+	var err = d.prepare(ctx, d.tunCli)
+	if err != nil {
+		return nil, err
+	}
+	v, err := d.tunCli.LookupTable(ctx, d.u32Key(tunnel))
+	if errors.Is(err, bpf.ErrKeyNotExist) {
+		return nil, ErrTunnelNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	var t = d.tunnelElem(v)
+	t.Tunnel = tunnel
+	return t, nil
+}
+
+func (d *fwdCli) updateTunnel(ctx context.Context, t *TunnelElem) error {
+	var v = make([]byte, tunnelValueSize)
+	tYpe, err := d.checkTunnel(t.Type, t.Key, t.Port)
+	if err != nil {
+		return err
+	}
+	src, err := d.checkip(t.Src)
+	if err != nil || len(src) != keySize {
+		return fmt.Errorf("src %s: %w", t.Src, ErrTunnel)
+	}
+	dst, err := d.checkip(t.Dst)
+	if err != nil || len(dst) != keySize {
+		return fmt.Errorf("dst %s: %w", t.Dst, ErrTunnel)
+	}
+	smac, err := d.checkmac(t.SrcMac)
+	if err != nil {
+		return err
+	}
+	dmac, err := d.checkmac(t.DstMac)
+	if err != nil {
+		return err
+	}
+	iface, err := d.iface(ctx, t.Iface, t.IfName)
+	if err != nil {
+		return err
+	}
+	copy(v[0:4], d.u32Key(iface))
+	copy(v[4:10], smac)
+	copy(v[10:16], dmac)
+	copy(v[16:20], src)
+	copy(v[20:24], dst)
+	copy(v[24:28], d.u32Key(t.Key))
+	v[28] = tYpe
+	if tYpe == tunnelGre && t.Key > 0 {
+		v[29] = tunnelFlagKey
+	}
+	v[30] = byte(t.Port)
+	v[31] = byte(t.Port >> 8)
+	return d.tunCli.UpdateTable(ctx, d.u32Key(t.Tunnel), v)
+}
+
+//隧道表项 VXLAN内层以太头使用表项SrcMac/DstMac, IPIP/GRE忽略
+func (d *fwdCli) tunnelKv(ctx context.Context, ip []byte, e *FwdElem) ([]byte, []byte, error) {
+	if e.Group > 0 {
+		return nil, nil, fmt.Errorf("group and tunnel are exclusive: %w", ErrTunnel)
+	}
+	var t, err = d.lookupTunnel(ctx, e.Tunnel)
+	if err != nil {
+		return nil, nil, err
+	}
+	var src, dst = make([]byte, 6), make([]byte, 6)
+	if t.Type == TunnelVxlan {
+		src, err = d.checkmac(e.SrcMac)
+		if err != nil {
+			return nil, nil, fmt.Errorf("inner smac %s: %w", e.SrcMac, ErrTunnel)
+		}
+		dst, err = d.checkmac(e.DstMac)
+		if err != nil {
+			return nil, nil, fmt.Errorf("inner dmac %s: %w", e.DstMac, ErrTunnel)
+		}
+	}
+	k, v := d.kv(ip, 0, src, dst, 0)
+	copy(v[tunnelOff:], d.u32Key(e.Tunnel))
+	return k, v, nil
+}
+
+func (d *fwdCli) decapKey(e *DecapElem) ([]byte, error) {
+	var tYpe, err = d.checkTunnel(e.Type, e.Key, e.Port)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := d.checkip(e.Dst)
+	if err != nil || len(dst) != keySize {
+		return nil, fmt.Errorf("dst %s: %w", e.Dst, ErrTunnel)
+	}
+	var (
+		k    = make([]byte, decapKeySize)
+		port = e.Port
+	)
+	if tYpe == tunnelVxlan && port == 0 {
+		port = vxlanPort
+	}
+	copy(k[0:4], dst)
+	copy(k[4:8], d.u32Key(e.Key))
+	k[8] = tYpe
+	k[10] = byte(port)
+	k[11] = byte(port >> 8)
+	return k, nil
+}
+
+//类型名转换为fwd.bpf.c中的取值, 校验Key及Port
+func (d *fwdCli) checkTunnel(name string, key uint32, port uint16) (byte, error) {
+	switch {
+	case name == TunnelIpip && key == 0 && port == 0:
+		return tunnelIpip, nil
+	case name == TunnelGre && port == 0:
+		return tunnelGre, nil
+	case name == TunnelVxlan && key <= vxlanMaxVni:
+		return tunnelVxlan, nil
+	}
+	return 0, fmt.Errorf("type %s key %d port %d: %w", name, key, port, ErrTunnel)
+}
+
+func (d *fwdCli) tunnelType(t byte) string {
+	switch t {
+	case tunnelIpip:
+		return TunnelIpip
+	case tunnelGre:
+		return TunnelGre
+	case tunnelVxlan:
+		return TunnelVxlan
+	}
+	return ""
+}
+
+func (d *fwdCli) tunnelElem(vv []byte) *TunnelElem {
+	return &TunnelElem{
+		Type:   d.tunnelType(vv[28]),
+		Src:    net.IP(vv[16:20]).String(),
+		Dst:    net.IP(vv[20:24]).String(),
+		Key:    d.u32(vv[24:]),
+		Port:   uint16(vv[30]) | uint16(vv[31])<<8,
+		Iface:  d.u32(vv[0:]),
+		SrcMac: fmt.Sprintf("%02x:%02x:%02x:%02x:%02x:%02x", vv[4], vv[5], vv[6], vv[7], vv[8], vv[9]),
+		DstMac: fmt.Sprintf("%02x:%02x:%02x:%02x:%02x:%02x", vv[0xa], vv[0xb], vv[0xc], vv[0xd], vv[0xe], vv[0xf]),
+	}
+}
@@ -0,0 +1,192 @@
+package fwd
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/advancevillage/3rd/logx"
+	"github.com/advancevillage/fwd/pkg/bpf"
+	"github.com/stretchr/testify/assert"
+)
+
+var tunnelTest = map[string]struct {
+	tunnel *TunnelElem
+	upt    *TunnelElem
+	elem   *FwdElem
+	err    error
+}{
+	"case-ipip": {
+		tunnel: &TunnelElem{Tunnel: 11, Type: TunnelIpip, Src: "192.0.2.10", Dst: "198.51.100.1", Iface: 4, IfName: "eth4", SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e"},
+		upt:    &TunnelElem{Tunnel: 11, Type: TunnelIpip, Src: "192.0.2.10", Dst: "198.51.100.2", Iface: 5, IfName: "eth5", SrcMac: "08:00:27:f3:81:0f", DstMac: "f8:ff:27:f3:81:0f"},
+		elem:   &FwdElem{Ip: "10.13.0.0", Prefix: 16, SrcMac: "00:00:00:00:00:00", DstMac: "00:00:00:00:00:00", Action: ActionRedirect, Origin: OriginStatic, Tunnel: 11},
+	},
+	"case-gre": {
+		tunnel: &TunnelElem{Tunnel: 12, Type: TunnelGre, Src: "192.0.2.10", Dst: "198.51.100.1", Key: 100, Iface: 4, IfName: "eth4", SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e"},
+		upt:    &TunnelElem{Tunnel: 12, Type: TunnelGre, Src: "192.0.2.10", Dst: "198.51.100.1", Iface: 4, IfName: "eth4", SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e"},
+		elem:   &FwdElem{Ip: "2001:db8:d::", Prefix: 48, SrcMac: "00:00:00:00:00:00", DstMac: "00:00:00:00:00:00", Action: ActionRedirect, Origin: OriginStatic, Tunnel: 12},
+	},
+	"case-vxlan": {
+		tunnel: &TunnelElem{Tunnel: 13, Type: TunnelVxlan, Src: "192.0.2.10", Dst: "198.51.100.1", Key: 5000, Port: 4789, Iface: 4, IfName: "eth4", SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e"},
+		upt:    &TunnelElem{Tunnel: 13, Type: TunnelVxlan, Src: "192.0.2.10", Dst: "198.51.100.1", Key: 5001, Port: 8472, Iface: 4, IfName: "eth4", SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e"},
+		elem:   &FwdElem{Ip: "10.13.1.1", Prefix: 32, SrcMac: "02:00:00:00:00:01", DstMac: "02:00:00:00:00:02", Action: ActionRedirect, Origin: OriginStatic, Tunnel: 13},
+	},
+	"case-ipip-key": {
+		tunnel: &TunnelElem{Tunnel: 14, Type: TunnelIpip, Src: "192.0.2.10", Dst: "198.51.100.1", Key: 1, Iface: 4, SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e"},
+		err:    ErrTunnel,
+	},
+	"case-vxlan-vni": {
+		tunnel: &TunnelElem{Tunnel: 15, Type: TunnelVxlan, Src: "192.0.2.10", Dst: "198.51.100.1", Key: 1 << 24, Iface: 4, SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e"},
+		err:    ErrTunnel,
+	},
+	"case-ipv6": {
+		tunnel: &TunnelElem{Tunnel: 16, Type: TunnelGre, Src: "2001:db8::1", Dst: "2001:db8::2", Iface: 4, SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e"},
+		err:    ErrTunnel,
+	},
+}
+
+func Test_fwd_tunnel(t *testing.T) {
+	logger, err := logx.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	c, err := NewFwdClient(logger, WithBackend(bpf.BackendSyscall), WithResolver(&fakeSource{}))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var ctx = context.TODO()
+
+	for n, p := range tunnelTest {
+		f := func(t *testing.T) {
+			//1. 创建隧道
+			assert.ErrorIs(t, c.AddTunnel(ctx, p.tunnel), p.err)
+			if p.err != nil {
+				return
+			}
+			assert.Equal(t, ErrTunnelExist, c.AddTunnel(ctx, p.tunnel))
+			//2. 转发表项指向隧道
+			var errs = c.UptFwdBatch(ctx, []*FwdElem{p.elem})
+			assert.Nil(t, errs[0])
+			r, err := c.QryFwd(ctx)
+			assert.Nil(t, err)
+			assert.Contains(t, r, p.elem)
+			assert.Equal(t, ErrTunnelInUse, c.DelTunnel(ctx, p.tunnel.Tunnel))
+			//3. 修改隧道
+			assert.Nil(t, c.UptTunnel(ctx, p.upt))
+			tunnels, err := c.QryTunnel(ctx)
+			assert.Nil(t, err)
+			assert.Contains(t, tunnels, p.upt)
+			//4. 删除
+			assert.Nil(t, c.DelFwd(ctx, fmt.Sprintf("%s/%d", p.elem.Ip, p.elem.Prefix)))
+			assert.Nil(t, c.DelTunnel(ctx, p.tunnel.Tunnel))
+			assert.Equal(t, ErrTunnelNotExist, c.DelTunnel(ctx, p.tunnel.Tunnel))
+		}
+		t.Run(n, f)
+	}
+}
+
+var tunnelFwdTest = map[string]struct {
+	elem *FwdElem
+	err  error
+}{
+	"case-not-exist": {
+		elem: &FwdElem{Ip: "10.14.0.0", Prefix: 16, Tunnel: 99},
+		err:  ErrTunnelNotExist,
+	},
+	"case-group": {
+		elem: &FwdElem{Ip: "10.14.1.0", Prefix: 24, Tunnel: 21, Group: 7},
+		err:  ErrTunnel,
+	},
+	"case-vlan-out": {
+		elem: &FwdElem{Ip: "10.14.2.0", Prefix: 24, Tunnel: 21, VlanOut: 100},
+		err:  ErrFwdVlan,
+	},
+	"case-vxlan-mac": {
+		elem: &FwdElem{Ip: "10.14.3.0", Prefix: 24, Tunnel: 22, SrcMac: "02:00:00:00:00:01"},
+		err:  ErrTunnel,
+	},
+}
+
+func Test_fwd_tunnel_entry(t *testing.T) {
+	logger, err := logx.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	c, err := NewFwdClient(logger, WithBackend(bpf.BackendSyscall), WithResolver(&fakeSource{}))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var ctx = context.TODO()
+	assert.Nil(t, c.AddTunnel(ctx, &TunnelElem{Tunnel: 21, Type: TunnelGre, Src: "192.0.2.10", Dst: "198.51.100.1", Iface: 4, SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e"}))
+	assert.Nil(t, c.AddTunnel(ctx, &TunnelElem{Tunnel: 22, Type: TunnelVxlan, Src: "192.0.2.10", Dst: "198.51.100.1", Key: 10, Iface: 4, SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e"}))
+	defer c.DelTunnel(ctx, 21)
+	defer c.DelTunnel(ctx, 22)
+
+	for n, p := range tunnelFwdTest {
+		f := func(t *testing.T) {
+			var errs = c.UptFwdBatch(ctx, []*FwdElem{p.elem})
+			assert.ErrorIs(t, errs[0], p.err)
+		}
+		t.Run(n, f)
+	}
+}
+
+var decapTest = map[string]struct {
+	decap *DecapElem
+	exp   *DecapElem
+	err   error
+}{
+	"case-ipip": {
+		decap: &DecapElem{Type: TunnelIpip, Dst: "192.0.2.10"},
+		exp:   &DecapElem{Type: TunnelIpip, Dst: "192.0.2.10"},
+	},
+	"case-gre": {
+		decap: &DecapElem{Type: TunnelGre, Dst: "192.0.2.10", Key: 100},
+		exp:   &DecapElem{Type: TunnelGre, Dst: "192.0.2.10", Key: 100},
+	},
+	"case-vxlan": {
+		decap: &DecapElem{Type: TunnelVxlan, Dst: "192.0.2.10", Key: 5000},
+		exp:   &DecapElem{Type: TunnelVxlan, Dst: "192.0.2.10", Key: 5000, Port: 4789},
+	},
+	"case-type": {
+		decap: &DecapElem{Type: "geneve", Dst: "192.0.2.10"},
+		err:   ErrTunnel,
+	},
+	"case-ipv6": {
+		decap: &DecapElem{Type: TunnelIpip, Dst: "2001:db8::1"},
+		err:   ErrTunnel,
+	},
+}
+
+func Test_fwd_decap(t *testing.T) {
+	logger, err := logx.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	c, err := NewFwdClient(logger, WithBackend(bpf.BackendSyscall), WithResolver(&fakeSource{}))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var ctx = context.TODO()
+
+	for n, p := range decapTest {
+		f := func(t *testing.T) {
+			assert.ErrorIs(t, c.AddDecap(ctx, p.decap), p.err)
+			if p.err != nil {
+				return
+			}
+			r, err := c.QryDecap(ctx)
+			assert.Nil(t, err)
+			assert.Contains(t, r, p.exp)
+			assert.Nil(t, c.DelDecap(ctx, p.decap))
+			assert.Equal(t, ErrDecapNotExist, c.DelDecap(ctx, p.decap))
+		}
+		t.Run(n, f)
+	}
+}
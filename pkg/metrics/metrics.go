@@ -0,0 +1,284 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//Prometheus 文本格式
+//
+// refer https://prometheus.io/docs/instrumenting/exposition_formats/
+//
+//eg:
+//
+// # HELP fwd_api_requests_total API requests by action and code.
+// # TYPE fwd_api_requests_total counter
+// fwd_api_requests_total{action="UpdateForward",code="200"} 3
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var (
+	//默认时延分桶 单位秒
+	DefBuckets = []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5}
+)
+
+type IRegistry interface {
+	Counter(name string, help string, labels ...string) ICounter
+	Gauge(name string, help string, labels ...string) IGauge
+	Histogram(name string, help string, buckets []float64, labels ...string) IHistogram
+	//采集前回调, 用于从外部(eg: BPF Map)刷新指标
+	Collect(f func(ctx context.Context))
+	Write(ctx context.Context, w io.Writer) error
+	Handler() http.Handler
+}
+
+type ICounter interface {
+	Inc(values ...string)
+	Add(v float64, values ...string)
+	//外部累计值(eg: XDP计数)直接覆盖
+	Set(v float64, values ...string)
+}
+
+type IGauge interface {
+	Set(v float64, values ...string)
+	Reset()
+}
+
+type IHistogram interface {
+	Observe(v float64, values ...string)
+}
+
+type registry struct {
+	mu       sync.Mutex
+	families []*family
+	names    map[string]*family
+	collects []func(ctx context.Context)
+}
+
+func NewRegistry() IRegistry {
+	return &registry{names: make(map[string]*family)}
+}
+
+func (r *registry) Counter(name string, help string, labels ...string) ICounter {
+	return r.family(name, help, "counter", nil, labels)
+}
+
+func (r *registry) Gauge(name string, help string, labels ...string) IGauge {
+	return r.family(name, help, "gauge", nil, labels)
+}
+
+func (r *registry) Histogram(name string, help string, buckets []float64, labels ...string) IHistogram {
+	if len(buckets) <= 0 {
+		buckets = DefBuckets
+	}
+	var b = make([]float64, len(buckets))
+	copy(b, buckets)
+	sort.Float64s(b)
+	return r.family(name, help, "histogram", b, labels)
+}
+
+func (r *registry) Collect(f func(ctx context.Context)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collects = append(r.collects, f)
+}
+
+func (r *registry) Write(ctx context.Context, w io.Writer) error {
+	r.mu.Lock()
+	var collects = append([]func(context.Context){}, r.collects...)
+	var families = append([]*family{}, r.families...)
+	r.mu.Unlock()
+
+	for _, f := range collects {
+		f(ctx)
+	}
+	var bw = bufio.NewWriter(w)
+	for _, f := range families {
+		f.write(bw)
+	}
+	return bw.Flush()
+}
+
+func (r *registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", ContentType)
+		_ = r.Write(req.Context(), w)
+	})
+}
+
+//同名指标重复注册时返回已注册的指标
+func (r *registry) family(name string, help string, tYpe string, buckets []float64, labels []string) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if f, ok := r.names[name]; ok {
+		return f
+	}
+	var f = &family{
+		name:    name,
+		help:    help,
+		tYpe:    tYpe,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*series),
+	}
+	r.names[name] = f
+	r.families = append(r.families, f)
+	return f
+}
+
+type series struct {
+	values  []string
+	value   float64
+	sum     float64
+	count   uint64
+	buckets []uint64
+}
+
+type family struct {
+	mu      sync.Mutex
+	name    string
+	help    string
+	tYpe    string
+	labels  []string
+	buckets []float64
+	series  map[string]*series
+}
+
+func (f *family) Inc(values ...string) {
+	f.Add(1, values...)
+}
+
+func (f *family) Add(v float64, values ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.get(values).value += v
+}
+
+func (f *family) Set(v float64, values ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.get(values).value = v
+}
+
+//清空序列, 用于标签集合会变化的指标(eg: 出接口)
+func (f *family) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.series = make(map[string]*series)
+}
+
+func (f *family) Observe(v float64, values ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var s = f.get(values)
+	s.sum += v
+	s.count++
+	for i := range f.buckets {
+		if v <= f.buckets[i] {
+			s.buckets[i]++
+		}
+	}
+}
+
+//标签值个数与标签名个数不一致时截断或补空
+func (f *family) get(values []string) *series {
+	var vv = make([]string, len(f.labels))
+	copy(vv, values)
+	var k = strings.Join(vv, "\xff")
+	var s, ok = f.series[k]
+	if !ok {
+		s = &series{values: vv, buckets: make([]uint64, len(f.buckets))}
+		f.series[k] = s
+	}
+	return s
+}
+
+func (f *family) write(w *bufio.Writer) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, escape(f.help, false))
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.tYpe)
+
+	var keys = make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		var s = f.series[k]
+		switch f.tYpe {
+		case "histogram":
+			for i := range f.buckets {
+				fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, f.label(s.values, "le", format(f.buckets[i])), s.buckets[i])
+			}
+			fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, f.label(s.values, "le", "+Inf"), s.count)
+			fmt.Fprintf(w, "%s_sum%s %s\n", f.name, f.label(s.values, "", ""), format(s.sum))
+			fmt.Fprintf(w, "%s_count%s %d\n", f.name, f.label(s.values, "", ""), s.count)
+		default:
+			fmt.Fprintf(w, "%s%s %s\n", f.name, f.label(s.values, "", ""), format(s.value))
+		}
+	}
+}
+
+func (f *family) label(values []string, extraName string, extraValue string) string {
+	var b strings.Builder
+	for i := range f.labels {
+		if b.Len() > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", f.labels[i], escape(values[i], true))
+	}
+	if len(extraName) > 0 {
+		if b.Len() > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", extraName, extraValue)
+	}
+	if b.Len() <= 0 {
+		return ""
+	}
+	return "{" + b.String() + "}"
+}
+
+func format(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+//HELP 转义 \ 和换行, 标签值额外转义 "
+func escape(s string, quote bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			b.WriteString(`\\`)
+		case s[i] == '\n':
+			b.WriteString(`\n`)
+		case s[i] == '"' && quote:
+			b.WriteString(`\"`)
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var writeTest = map[string]struct {
+	f   func(r IRegistry)
+	exp string
+}{
+	"case-counter": {
+		f: func(r IRegistry) {
+			var c = r.Counter("fwd_api_requests_total", "API requests.", "action", "code")
+			c.Inc("UpdateForward", "200")
+			c.Inc("UpdateForward", "200")
+			c.Add(3, "QueryForward", "500")
+		},
+		exp: "# HELP fwd_api_requests_total API requests.\n" +
+			"# TYPE fwd_api_requests_total counter\n" +
+			"fwd_api_requests_total{action=\"QueryForward\",code=\"500\"} 3\n" +
+			"fwd_api_requests_total{action=\"UpdateForward\",code=\"200\"} 2\n",
+	},
+	"case-gauge-nolabel": {
+		f: func(r IRegistry) {
+			r.Gauge("fwd_up", "Up.").Set(1)
+		},
+		exp: "# HELP fwd_up Up.\n" +
+			"# TYPE fwd_up gauge\n" +
+			"fwd_up 1\n",
+	},
+	"case-histogram": {
+		f: func(r IRegistry) {
+			var h = r.Histogram("fwd_op_seconds", "Op latency.", []float64{0.1, 1}, "op")
+			h.Observe(0.05, "update")
+			h.Observe(0.5, "update")
+		},
+		exp: "# HELP fwd_op_seconds Op latency.\n" +
+			"# TYPE fwd_op_seconds histogram\n" +
+			"fwd_op_seconds_bucket{op=\"update\",le=\"0.1\"} 1\n" +
+			"fwd_op_seconds_bucket{op=\"update\",le=\"1\"} 2\n" +
+			"fwd_op_seconds_bucket{op=\"update\",le=\"+Inf\"} 2\n" +
+			"fwd_op_seconds_sum{op=\"update\"} 0.55\n" +
+			"fwd_op_seconds_count{op=\"update\"} 2\n",
+	},
+	"case-escape": {
+		f: func(r IRegistry) {
+			r.Counter("fwd_err_total", "Errors\\x.", "msg").Inc("a\"b\nc")
+		},
+		exp: "# HELP fwd_err_total Errors\\\\x.\n" +
+			"# TYPE fwd_err_total counter\n" +
+			"fwd_err_total{msg=\"a\\\"b\\nc\"} 1\n",
+	},
+}
+
+func Test_write(t *testing.T) {
+	for n, p := range writeTest {
+		f := func(t *testing.T) {
+			var r = NewRegistry()
+			p.f(r)
+			var b bytes.Buffer
+			assert.Nil(t, r.Write(context.TODO(), &b))
+			assert.Equal(t, p.exp, b.String())
+		}
+		t.Run(n, f)
+	}
+}
+
+func Test_handler(t *testing.T) {
+	var r = NewRegistry()
+	var g = r.Gauge("fwd_table_entries", "Entries.", "table")
+	r.Collect(func(ctx context.Context) {
+		g.Set(7, "hfwd")
+	})
+	var w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "fwd_table_entries{table=\"hfwd\"} 7\n")
+
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
@@ -20,7 +20,59 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
-//protoc -I proto --go_out=./proto/ --go_opt=paths=source_relative proto/fwd.proto
+type EventType int32
+
+const (
+	EventType_EVENT_UPDATE EventType = 0 //表项修改
+	EventType_EVENT_DELETE EventType = 1 //表项删除
+	EventType_EVENT_ADD    EventType = 2 //表项新增 全量推送时同样使用
+	EventType_EVENT_SYNC   EventType = 3 //全量推送结束
+)
+
+// Enum value maps for EventType.
+var (
+	EventType_name = map[int32]string{
+		0: "EVENT_UPDATE",
+		1: "EVENT_DELETE",
+		2: "EVENT_ADD",
+		3: "EVENT_SYNC",
+	}
+	EventType_value = map[string]int32{
+		"EVENT_UPDATE": 0,
+		"EVENT_DELETE": 1,
+		"EVENT_ADD":    2,
+		"EVENT_SYNC":   3,
+	}
+)
+
+func (x EventType) Enum() *EventType {
+	p := new(EventType)
+	*p = x
+	return p
+}
+
+func (x EventType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (EventType) Descriptor() protoreflect.EnumDescriptor {
+	return file_fwd_proto_enumTypes[0].Descriptor()
+}
+
+func (EventType) Type() protoreflect.EnumType {
+	return &file_fwd_proto_enumTypes[0]
+}
+
+func (x EventType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use EventType.Descriptor instead.
+func (EventType) EnumDescriptor() ([]byte, []int) {
+	return file_fwd_proto_rawDescGZIP(), []int{0}
+}
+
+//protoc -I proto --go_out=./proto/ --go_opt=paths=source_relative --go-grpc_out=./proto/ --go-grpc_opt=paths=source_relative proto/fwd.proto
 type Error struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -194,86 +246,1410 @@ func (x *ActionResponse) GetTraceId() string {
 	return ""
 }
 
-var File_fwd_proto protoreflect.FileDescriptor
+//转发表项
+type FwdEntry struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
 
-var file_fwd_proto_rawDesc = []byte{
-	0x0a, 0x09, 0x66, 0x77, 0x64, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x03, 0x66, 0x77, 0x64,
-	0x22, 0x2d, 0x0a, 0x05, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x63, 0x6f, 0x64,
-	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x12, 0x10, 0x0a,
-	0x03, 0x6d, 0x73, 0x67, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6d, 0x73, 0x67, 0x22,
-	0x41, 0x0a, 0x0d, 0x41, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
-	0x12, 0x16, 0x0a, 0x06, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x06, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x18, 0x0a, 0x07, 0x74, 0x72, 0x61, 0x63,
-	0x65, 0x49, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x74, 0x72, 0x61, 0x63, 0x65,
-	0x49, 0x64, 0x22, 0x62, 0x0a, 0x0e, 0x41, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x73, 0x70,
-	0x6f, 0x6e, 0x73, 0x65, 0x12, 0x22, 0x0a, 0x06, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x73, 0x18, 0x01,
-	0x20, 0x03, 0x28, 0x0b, 0x32, 0x0a, 0x2e, 0x66, 0x77, 0x64, 0x2e, 0x45, 0x72, 0x72, 0x6f, 0x72,
-	0x52, 0x06, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x63, 0x6f, 0x64, 0x65,
-	0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x12, 0x18, 0x0a, 0x07,
-	0x74, 0x72, 0x61, 0x63, 0x65, 0x49, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x74,
-	0x72, 0x61, 0x63, 0x65, 0x49, 0x64, 0x42, 0x09, 0x5a, 0x07, 0x2e, 0x2f, 0x70, 0x72, 0x6f, 0x74,
-	0x6f, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	Ip       string   `protobuf:"bytes,1,opt,name=ip,proto3" json:"ip,omitempty"`               //目的地址 主机地址或CIDR前缀
+	Prefix   int32    `protobuf:"varint,2,opt,name=prefix,proto3" json:"prefix,omitempty"`      //前缀长度 ip不含前缀时生效
+	Iface    uint32   `protobuf:"varint,3,opt,name=iface,proto3" json:"iface,omitempty"`        //出接口ifindex
+	SrcMac   string   `protobuf:"bytes,4,opt,name=srcMac,proto3" json:"srcMac,omitempty"`       //源MAC
+	DstMac   string   `protobuf:"bytes,5,opt,name=dstMac,proto3" json:"dstMac,omitempty"`       //目的MAC
+	Group    uint32   `protobuf:"varint,6,opt,name=group,proto3" json:"group,omitempty"`        //下一跳组 非0时忽略iface/srcMac/dstMac
+	Packets  uint64   `protobuf:"varint,7,opt,name=packets,proto3" json:"packets,omitempty"`    //XDP转发报文数 仅查询
+	Bytes    uint64   `protobuf:"varint,8,opt,name=bytes,proto3" json:"bytes,omitempty"`        //XDP转发字节数 仅查询
+	Gateway  string   `protobuf:"bytes,9,opt,name=gateway,proto3" json:"gateway,omitempty"`     //网关 非空时按邻居表解析iface/srcMac/dstMac
+	IfName   string   `protobuf:"bytes,10,opt,name=ifName,proto3" json:"ifName,omitempty"`      //出接口名 非空时优先于iface
+	Orphaned bool     `protobuf:"varint,11,opt,name=orphaned,proto3" json:"orphaned,omitempty"` //出接口已不存在 仅查询
+	Ttl      uint32   `protobuf:"varint,12,opt,name=ttl,proto3" json:"ttl,omitempty"`           //租约秒数 到期未续约则删除, 0表示永久有效
+	Deadline int64    `protobuf:"varint,13,opt,name=deadline,proto3" json:"deadline,omitempty"` //租约到期时间 unix秒 仅查询
+	Origin   string   `protobuf:"bytes,14,opt,name=origin,proto3" json:"origin,omitempty"`      //表项来源 static或learned 仅查询
+	Action   string   `protobuf:"bytes,15,opt,name=action,proto3" json:"action,omitempty"`      //表项动作 redirect(默认)/drop/pass, drop/pass时忽略下一跳
+	Policer  *Policer `protobuf:"bytes,16,opt,name=policer,proto3" json:"policer,omitempty"`    //限速 为空表示不限速
+	VlanIn   uint32   `protobuf:"varint,17,opt,name=vlanIn,proto3" json:"vlanIn,omitempty"`     //入VLAN 报文802.1Q VID与之一致时表项生效, 0表示不带标签
+	VlanOut  uint32   `protobuf:"varint,18,opt,name=vlanOut,proto3" json:"vlanOut,omitempty"`   //出VLAN 0表示不带标签, 与入VLAN不同时push/pop/rewrite
+	Tunnel   uint32   `protobuf:"varint,19,opt,name=tunnel,proto3" json:"tunnel,omitempty"`     //隧道 非0时按隧道封装, 忽略iface, vxlan时srcMac/dstMac为内层MAC
+	Table    uint32   `protobuf:"varint,20,opt,name=table,proto3" json:"table,omitempty"`       //策略表 非0时写入策略表, 仅IPv4, 不支持ttl及policer
 }
 
-var (
-	file_fwd_proto_rawDescOnce sync.Once
-	file_fwd_proto_rawDescData = file_fwd_proto_rawDesc
-)
+func (x *FwdEntry) Reset() {
+	*x = FwdEntry{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_fwd_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
 
-func file_fwd_proto_rawDescGZIP() []byte {
-	file_fwd_proto_rawDescOnce.Do(func() {
-		file_fwd_proto_rawDescData = protoimpl.X.CompressGZIP(file_fwd_proto_rawDescData)
-	})
-	return file_fwd_proto_rawDescData
+func (x *FwdEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
 
-var file_fwd_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
-var file_fwd_proto_goTypes = []interface{}{
-	(*Error)(nil),          // 0: fwd.Error
-	(*ActionRequest)(nil),  // 1: fwd.ActionRequest
-	(*ActionResponse)(nil), // 2: fwd.ActionResponse
+func (*FwdEntry) ProtoMessage() {}
+
+func (x *FwdEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_fwd_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
 }
-var file_fwd_proto_depIdxs = []int32{
-	0, // 0: fwd.ActionResponse.errors:type_name -> fwd.Error
-	1, // [1:1] is the sub-list for method output_type
-	1, // [1:1] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+
+// Deprecated: Use FwdEntry.ProtoReflect.Descriptor instead.
+func (*FwdEntry) Descriptor() ([]byte, []int) {
+	return file_fwd_proto_rawDescGZIP(), []int{3}
 }
 
-func init() { file_fwd_proto_init() }
-func file_fwd_proto_init() {
-	if File_fwd_proto != nil {
-		return
+func (x *FwdEntry) GetIp() string {
+	if x != nil {
+		return x.Ip
 	}
-	if !protoimpl.UnsafeEnabled {
-		file_fwd_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Error); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_fwd_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*ActionRequest); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
+	return ""
+}
+
+func (x *FwdEntry) GetPrefix() int32 {
+	if x != nil {
+		return x.Prefix
+	}
+	return 0
+}
+
+func (x *FwdEntry) GetIface() uint32 {
+	if x != nil {
+		return x.Iface
+	}
+	return 0
+}
+
+func (x *FwdEntry) GetSrcMac() string {
+	if x != nil {
+		return x.SrcMac
+	}
+	return ""
+}
+
+func (x *FwdEntry) GetDstMac() string {
+	if x != nil {
+		return x.DstMac
+	}
+	return ""
+}
+
+func (x *FwdEntry) GetGroup() uint32 {
+	if x != nil {
+		return x.Group
+	}
+	return 0
+}
+
+func (x *FwdEntry) GetPackets() uint64 {
+	if x != nil {
+		return x.Packets
+	}
+	return 0
+}
+
+func (x *FwdEntry) GetBytes() uint64 {
+	if x != nil {
+		return x.Bytes
+	}
+	return 0
+}
+
+func (x *FwdEntry) GetGateway() string {
+	if x != nil {
+		return x.Gateway
+	}
+	return ""
+}
+
+func (x *FwdEntry) GetIfName() string {
+	if x != nil {
+		return x.IfName
+	}
+	return ""
+}
+
+func (x *FwdEntry) GetOrphaned() bool {
+	if x != nil {
+		return x.Orphaned
+	}
+	return false
+}
+
+func (x *FwdEntry) GetTtl() uint32 {
+	if x != nil {
+		return x.Ttl
+	}
+	return 0
+}
+
+func (x *FwdEntry) GetDeadline() int64 {
+	if x != nil {
+		return x.Deadline
+	}
+	return 0
+}
+
+func (x *FwdEntry) GetOrigin() string {
+	if x != nil {
+		return x.Origin
+	}
+	return ""
+}
+
+func (x *FwdEntry) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *FwdEntry) GetPolicer() *Policer {
+	if x != nil {
+		return x.Policer
+	}
+	return nil
+}
+
+func (x *FwdEntry) GetVlanIn() uint32 {
+	if x != nil {
+		return x.VlanIn
+	}
+	return 0
+}
+
+func (x *FwdEntry) GetVlanOut() uint32 {
+	if x != nil {
+		return x.VlanOut
+	}
+	return 0
+}
+
+func (x *FwdEntry) GetTunnel() uint32 {
+	if x != nil {
+		return x.Tunnel
+	}
+	return 0
+}
+
+func (x *FwdEntry) GetTable() uint32 {
+	if x != nil {
+		return x.Table
+	}
+	return 0
+}
+
+//令牌桶限速 pps/bps均为0表示不限速
+type Policer struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Pps         uint64 `protobuf:"varint,1,opt,name=pps,proto3" json:"pps,omitempty"`                 //报文每秒
+	Bps         uint64 `protobuf:"varint,2,opt,name=bps,proto3" json:"bps,omitempty"`                 //比特每秒
+	BurstPkts   uint64 `protobuf:"varint,3,opt,name=burstPkts,proto3" json:"burstPkts,omitempty"`     //报文桶深 0时取1秒的速率
+	BurstBytes  uint64 `protobuf:"varint,4,opt,name=burstBytes,proto3" json:"burstBytes,omitempty"`   //字节桶深 0时取1秒的速率
+	Exceed      string `protobuf:"bytes,5,opt,name=exceed,proto3" json:"exceed,omitempty"`            //超限动作 drop(默认)/pass
+	ExceedPkts  uint64 `protobuf:"varint,6,opt,name=exceedPkts,proto3" json:"exceedPkts,omitempty"`   //超限报文数 仅查询
+	ExceedBytes uint64 `protobuf:"varint,7,opt,name=exceedBytes,proto3" json:"exceedBytes,omitempty"` //超限字节数 仅查询
+}
+
+func (x *Policer) Reset() {
+	*x = Policer{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_fwd_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Policer) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Policer) ProtoMessage() {}
+
+func (x *Policer) ProtoReflect() protoreflect.Message {
+	mi := &file_fwd_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
-		file_fwd_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*ActionResponse); i {
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Policer.ProtoReflect.Descriptor instead.
+func (*Policer) Descriptor() ([]byte, []int) {
+	return file_fwd_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Policer) GetPps() uint64 {
+	if x != nil {
+		return x.Pps
+	}
+	return 0
+}
+
+func (x *Policer) GetBps() uint64 {
+	if x != nil {
+		return x.Bps
+	}
+	return 0
+}
+
+func (x *Policer) GetBurstPkts() uint64 {
+	if x != nil {
+		return x.BurstPkts
+	}
+	return 0
+}
+
+func (x *Policer) GetBurstBytes() uint64 {
+	if x != nil {
+		return x.BurstBytes
+	}
+	return 0
+}
+
+func (x *Policer) GetExceed() string {
+	if x != nil {
+		return x.Exceed
+	}
+	return ""
+}
+
+func (x *Policer) GetExceedPkts() uint64 {
+	if x != nil {
+		return x.ExceedPkts
+	}
+	return 0
+}
+
+func (x *Policer) GetExceedBytes() uint64 {
+	if x != nil {
+		return x.ExceedBytes
+	}
+	return 0
+}
+
+type UpdateForwardRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	TraceId string      `protobuf:"bytes,1,opt,name=traceId,proto3" json:"traceId,omitempty"`
+	Entries []*FwdEntry `protobuf:"bytes,2,rep,name=entries,proto3" json:"entries,omitempty"`
+}
+
+func (x *UpdateForwardRequest) Reset() {
+	*x = UpdateForwardRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_fwd_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *UpdateForwardRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateForwardRequest) ProtoMessage() {}
+
+func (x *UpdateForwardRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fwd_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateForwardRequest.ProtoReflect.Descriptor instead.
+func (*UpdateForwardRequest) Descriptor() ([]byte, []int) {
+	return file_fwd_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *UpdateForwardRequest) GetTraceId() string {
+	if x != nil {
+		return x.TraceId
+	}
+	return ""
+}
+
+func (x *UpdateForwardRequest) GetEntries() []*FwdEntry {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+type UpdateForwardResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Status  *ActionResponse `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Results []*Error        `protobuf:"bytes,2,rep,name=results,proto3" json:"results,omitempty"` //与entries一一对应, code为0表示成功
+}
+
+func (x *UpdateForwardResponse) Reset() {
+	*x = UpdateForwardResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_fwd_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *UpdateForwardResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateForwardResponse) ProtoMessage() {}
+
+func (x *UpdateForwardResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_fwd_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateForwardResponse.ProtoReflect.Descriptor instead.
+func (*UpdateForwardResponse) Descriptor() ([]byte, []int) {
+	return file_fwd_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *UpdateForwardResponse) GetStatus() *ActionResponse {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+func (x *UpdateForwardResponse) GetResults() []*Error {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type DeleteForwardRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	TraceId string   `protobuf:"bytes,1,opt,name=traceId,proto3" json:"traceId,omitempty"`
+	Ips     []string `protobuf:"bytes,2,rep,name=ips,proto3" json:"ips,omitempty"`      //主机地址或CIDR前缀
+	Table   uint32   `protobuf:"varint,3,opt,name=table,proto3" json:"table,omitempty"` //策略表 非0时删除策略表项
+}
+
+func (x *DeleteForwardRequest) Reset() {
+	*x = DeleteForwardRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_fwd_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeleteForwardRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteForwardRequest) ProtoMessage() {}
+
+func (x *DeleteForwardRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fwd_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteForwardRequest.ProtoReflect.Descriptor instead.
+func (*DeleteForwardRequest) Descriptor() ([]byte, []int) {
+	return file_fwd_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *DeleteForwardRequest) GetTraceId() string {
+	if x != nil {
+		return x.TraceId
+	}
+	return ""
+}
+
+func (x *DeleteForwardRequest) GetIps() []string {
+	if x != nil {
+		return x.Ips
+	}
+	return nil
+}
+
+func (x *DeleteForwardRequest) GetTable() uint32 {
+	if x != nil {
+		return x.Table
+	}
+	return 0
+}
+
+type DeleteForwardResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Status  *ActionResponse `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Results []*Error        `protobuf:"bytes,2,rep,name=results,proto3" json:"results,omitempty"` //与ips一一对应, code为0表示成功
+}
+
+func (x *DeleteForwardResponse) Reset() {
+	*x = DeleteForwardResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_fwd_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeleteForwardResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteForwardResponse) ProtoMessage() {}
+
+func (x *DeleteForwardResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_fwd_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteForwardResponse.ProtoReflect.Descriptor instead.
+func (*DeleteForwardResponse) Descriptor() ([]byte, []int) {
+	return file_fwd_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *DeleteForwardResponse) GetStatus() *ActionResponse {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+func (x *DeleteForwardResponse) GetResults() []*Error {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type RenewForwardRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	TraceId string   `protobuf:"bytes,1,opt,name=traceId,proto3" json:"traceId,omitempty"`
+	Ips     []string `protobuf:"bytes,2,rep,name=ips,proto3" json:"ips,omitempty"`  //主机地址或CIDR前缀
+	Ttl     uint32   `protobuf:"varint,3,opt,name=ttl,proto3" json:"ttl,omitempty"` //续约秒数 0表示沿用上次租约时长
+}
+
+func (x *RenewForwardRequest) Reset() {
+	*x = RenewForwardRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_fwd_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RenewForwardRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RenewForwardRequest) ProtoMessage() {}
+
+func (x *RenewForwardRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fwd_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RenewForwardRequest.ProtoReflect.Descriptor instead.
+func (*RenewForwardRequest) Descriptor() ([]byte, []int) {
+	return file_fwd_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *RenewForwardRequest) GetTraceId() string {
+	if x != nil {
+		return x.TraceId
+	}
+	return ""
+}
+
+func (x *RenewForwardRequest) GetIps() []string {
+	if x != nil {
+		return x.Ips
+	}
+	return nil
+}
+
+func (x *RenewForwardRequest) GetTtl() uint32 {
+	if x != nil {
+		return x.Ttl
+	}
+	return 0
+}
+
+type RenewForwardResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Status  *ActionResponse `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Results []*Error        `protobuf:"bytes,2,rep,name=results,proto3" json:"results,omitempty"` //与ips一一对应, code为0表示成功
+}
+
+func (x *RenewForwardResponse) Reset() {
+	*x = RenewForwardResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_fwd_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RenewForwardResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RenewForwardResponse) ProtoMessage() {}
+
+func (x *RenewForwardResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_fwd_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RenewForwardResponse.ProtoReflect.Descriptor instead.
+func (*RenewForwardResponse) Descriptor() ([]byte, []int) {
+	return file_fwd_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *RenewForwardResponse) GetStatus() *ActionResponse {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+func (x *RenewForwardResponse) GetResults() []*Error {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type QueryForwardRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	TraceId string `protobuf:"bytes,1,opt,name=traceId,proto3" json:"traceId,omitempty"`
+	Origin  string `protobuf:"bytes,2,opt,name=origin,proto3" json:"origin,omitempty"` //为空时返回全部, 否则按来源static或learned过滤
+}
+
+func (x *QueryForwardRequest) Reset() {
+	*x = QueryForwardRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_fwd_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *QueryForwardRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryForwardRequest) ProtoMessage() {}
+
+func (x *QueryForwardRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fwd_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryForwardRequest.ProtoReflect.Descriptor instead.
+func (*QueryForwardRequest) Descriptor() ([]byte, []int) {
+	return file_fwd_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *QueryForwardRequest) GetTraceId() string {
+	if x != nil {
+		return x.TraceId
+	}
+	return ""
+}
+
+func (x *QueryForwardRequest) GetOrigin() string {
+	if x != nil {
+		return x.Origin
+	}
+	return ""
+}
+
+type QueryForwardResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Status  *ActionResponse `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Entries []*FwdEntry     `protobuf:"bytes,2,rep,name=entries,proto3" json:"entries,omitempty"`
+}
+
+func (x *QueryForwardResponse) Reset() {
+	*x = QueryForwardResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_fwd_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *QueryForwardResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryForwardResponse) ProtoMessage() {}
+
+func (x *QueryForwardResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_fwd_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryForwardResponse.ProtoReflect.Descriptor instead.
+func (*QueryForwardResponse) Descriptor() ([]byte, []int) {
+	return file_fwd_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *QueryForwardResponse) GetStatus() *ActionResponse {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+func (x *QueryForwardResponse) GetEntries() []*FwdEntry {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+type FlushLearnedRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	TraceId string `protobuf:"bytes,1,opt,name=traceId,proto3" json:"traceId,omitempty"`
+}
+
+func (x *FlushLearnedRequest) Reset() {
+	*x = FlushLearnedRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_fwd_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *FlushLearnedRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FlushLearnedRequest) ProtoMessage() {}
+
+func (x *FlushLearnedRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fwd_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FlushLearnedRequest.ProtoReflect.Descriptor instead.
+func (*FlushLearnedRequest) Descriptor() ([]byte, []int) {
+	return file_fwd_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *FlushLearnedRequest) GetTraceId() string {
+	if x != nil {
+		return x.TraceId
+	}
+	return ""
+}
+
+type FlushLearnedResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Status *ActionResponse `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Ips    []string        `protobuf:"bytes,2,rep,name=ips,proto3" json:"ips,omitempty"` //已删除的学习表项
+}
+
+func (x *FlushLearnedResponse) Reset() {
+	*x = FlushLearnedResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_fwd_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *FlushLearnedResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FlushLearnedResponse) ProtoMessage() {}
+
+func (x *FlushLearnedResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_fwd_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FlushLearnedResponse.ProtoReflect.Descriptor instead.
+func (*FlushLearnedResponse) Descriptor() ([]byte, []int) {
+	return file_fwd_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *FlushLearnedResponse) GetStatus() *ActionResponse {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+func (x *FlushLearnedResponse) GetIps() []string {
+	if x != nil {
+		return x.Ips
+	}
+	return nil
+}
+
+// seq为0时先推送全量表项, 否则从seq之后的事件续订
+type WatchForwardRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	TraceId string `protobuf:"bytes,1,opt,name=traceId,proto3" json:"traceId,omitempty"`
+	Seq     uint64 `protobuf:"varint,2,opt,name=seq,proto3" json:"seq,omitempty"`
+}
+
+func (x *WatchForwardRequest) Reset() {
+	*x = WatchForwardRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_fwd_proto_msgTypes[15]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WatchForwardRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchForwardRequest) ProtoMessage() {}
+
+func (x *WatchForwardRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fwd_proto_msgTypes[15]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchForwardRequest.ProtoReflect.Descriptor instead.
+func (*WatchForwardRequest) Descriptor() ([]byte, []int) {
+	return file_fwd_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *WatchForwardRequest) GetTraceId() string {
+	if x != nil {
+		return x.TraceId
+	}
+	return ""
+}
+
+func (x *WatchForwardRequest) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+type FwdEvent struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Type  EventType `protobuf:"varint,1,opt,name=type,proto3,enum=fwd.EventType" json:"type,omitempty"`
+	Entry *FwdEntry `protobuf:"bytes,2,opt,name=entry,proto3" json:"entry,omitempty"`
+	Seq   uint64    `protobuf:"varint,3,opt,name=seq,proto3" json:"seq,omitempty"` //单调递增, 全量推送时为快照对应的序号
+}
+
+func (x *FwdEvent) Reset() {
+	*x = FwdEvent{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_fwd_proto_msgTypes[16]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *FwdEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FwdEvent) ProtoMessage() {}
+
+func (x *FwdEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_fwd_proto_msgTypes[16]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FwdEvent.ProtoReflect.Descriptor instead.
+func (*FwdEvent) Descriptor() ([]byte, []int) {
+	return file_fwd_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *FwdEvent) GetType() EventType {
+	if x != nil {
+		return x.Type
+	}
+	return EventType_EVENT_UPDATE
+}
+
+func (x *FwdEvent) GetEntry() *FwdEntry {
+	if x != nil {
+		return x.Entry
+	}
+	return nil
+}
+
+func (x *FwdEvent) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+var File_fwd_proto protoreflect.FileDescriptor
+
+var file_fwd_proto_rawDesc = []byte{
+	0x0a, 0x09, 0x66, 0x77, 0x64, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x03, 0x66, 0x77, 0x64,
+	0x22, 0x2d, 0x0a, 0x05, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x63, 0x6f, 0x64,
+	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x12, 0x10, 0x0a,
+	0x03, 0x6d, 0x73, 0x67, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6d, 0x73, 0x67, 0x22,
+	0x41, 0x0a, 0x0d, 0x41, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x12, 0x16, 0x0a, 0x06, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x06, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x18, 0x0a, 0x07, 0x74, 0x72, 0x61, 0x63,
+	0x65, 0x49, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x74, 0x72, 0x61, 0x63, 0x65,
+	0x49, 0x64, 0x22, 0x62, 0x0a, 0x0e, 0x41, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x12, 0x22, 0x0a, 0x06, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x73, 0x18, 0x01,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x0a, 0x2e, 0x66, 0x77, 0x64, 0x2e, 0x45, 0x72, 0x72, 0x6f, 0x72,
+	0x52, 0x06, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x63, 0x6f, 0x64, 0x65,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x12, 0x18, 0x0a, 0x07,
+	0x74, 0x72, 0x61, 0x63, 0x65, 0x49, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x74,
+	0x72, 0x61, 0x63, 0x65, 0x49, 0x64, 0x22, 0xf2, 0x03, 0x0a, 0x08, 0x46, 0x77, 0x64, 0x45, 0x6e,
+	0x74, 0x72, 0x79, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x02, 0x69, 0x70, 0x12, 0x16, 0x0a, 0x06, 0x70, 0x72, 0x65, 0x66, 0x69, 0x78, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x05, 0x52, 0x06, 0x70, 0x72, 0x65, 0x66, 0x69, 0x78, 0x12, 0x14, 0x0a, 0x05, 0x69,
+	0x66, 0x61, 0x63, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x69, 0x66, 0x61, 0x63,
+	0x65, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x72, 0x63, 0x4d, 0x61, 0x63, 0x18, 0x04, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x06, 0x73, 0x72, 0x63, 0x4d, 0x61, 0x63, 0x12, 0x16, 0x0a, 0x06, 0x64, 0x73, 0x74,
+	0x4d, 0x61, 0x63, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x64, 0x73, 0x74, 0x4d, 0x61,
+	0x63, 0x12, 0x14, 0x0a, 0x05, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0d,
+	0x52, 0x05, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x12, 0x18, 0x0a, 0x07, 0x70, 0x61, 0x63, 0x6b, 0x65,
+	0x74, 0x73, 0x18, 0x07, 0x20, 0x01, 0x28, 0x04, 0x52, 0x07, 0x70, 0x61, 0x63, 0x6b, 0x65, 0x74,
+	0x73, 0x12, 0x14, 0x0a, 0x05, 0x62, 0x79, 0x74, 0x65, 0x73, 0x18, 0x08, 0x20, 0x01, 0x28, 0x04,
+	0x52, 0x05, 0x62, 0x79, 0x74, 0x65, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x67, 0x61, 0x74, 0x65, 0x77,
+	0x61, 0x79, 0x18, 0x09, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x67, 0x61, 0x74, 0x65, 0x77, 0x61,
+	0x79, 0x12, 0x16, 0x0a, 0x06, 0x69, 0x66, 0x4e, 0x61, 0x6d, 0x65, 0x18, 0x0a, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x06, 0x69, 0x66, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x6f, 0x72, 0x70,
+	0x68, 0x61, 0x6e, 0x65, 0x64, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x6f, 0x72, 0x70,
+	0x68, 0x61, 0x6e, 0x65, 0x64, 0x12, 0x10, 0x0a, 0x03, 0x74, 0x74, 0x6c, 0x18, 0x0c, 0x20, 0x01,
+	0x28, 0x0d, 0x52, 0x03, 0x74, 0x74, 0x6c, 0x12, 0x1a, 0x0a, 0x08, 0x64, 0x65, 0x61, 0x64, 0x6c,
+	0x69, 0x6e, 0x65, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x64, 0x65, 0x61, 0x64, 0x6c,
+	0x69, 0x6e, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x6f, 0x72, 0x69, 0x67, 0x69, 0x6e, 0x18, 0x0e, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x06, 0x6f, 0x72, 0x69, 0x67, 0x69, 0x6e, 0x12, 0x16, 0x0a, 0x06, 0x61,
+	0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x61, 0x63, 0x74,
+	0x69, 0x6f, 0x6e, 0x12, 0x26, 0x0a, 0x07, 0x70, 0x6f, 0x6c, 0x69, 0x63, 0x65, 0x72, 0x18, 0x10,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x66, 0x77, 0x64, 0x2e, 0x50, 0x6f, 0x6c, 0x69, 0x63,
+	0x65, 0x72, 0x52, 0x07, 0x70, 0x6f, 0x6c, 0x69, 0x63, 0x65, 0x72, 0x12, 0x16, 0x0a, 0x06, 0x76,
+	0x6c, 0x61, 0x6e, 0x49, 0x6e, 0x18, 0x11, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x06, 0x76, 0x6c, 0x61,
+	0x6e, 0x49, 0x6e, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x6c, 0x61, 0x6e, 0x4f, 0x75, 0x74, 0x18, 0x12,
+	0x20, 0x01, 0x28, 0x0d, 0x52, 0x07, 0x76, 0x6c, 0x61, 0x6e, 0x4f, 0x75, 0x74, 0x12, 0x16, 0x0a,
+	0x06, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x18, 0x13, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x06, 0x74,
+	0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x18, 0x14,
+	0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x22, 0xc5, 0x01, 0x0a, 0x07,
+	0x50, 0x6f, 0x6c, 0x69, 0x63, 0x65, 0x72, 0x12, 0x10, 0x0a, 0x03, 0x70, 0x70, 0x73, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x04, 0x52, 0x03, 0x70, 0x70, 0x73, 0x12, 0x10, 0x0a, 0x03, 0x62, 0x70, 0x73,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x03, 0x62, 0x70, 0x73, 0x12, 0x1c, 0x0a, 0x09, 0x62,
+	0x75, 0x72, 0x73, 0x74, 0x50, 0x6b, 0x74, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x09,
+	0x62, 0x75, 0x72, 0x73, 0x74, 0x50, 0x6b, 0x74, 0x73, 0x12, 0x1e, 0x0a, 0x0a, 0x62, 0x75, 0x72,
+	0x73, 0x74, 0x42, 0x79, 0x74, 0x65, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0a, 0x62,
+	0x75, 0x72, 0x73, 0x74, 0x42, 0x79, 0x74, 0x65, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x65, 0x78, 0x63,
+	0x65, 0x65, 0x64, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x65, 0x78, 0x63, 0x65, 0x65,
+	0x64, 0x12, 0x1e, 0x0a, 0x0a, 0x65, 0x78, 0x63, 0x65, 0x65, 0x64, 0x50, 0x6b, 0x74, 0x73, 0x18,
+	0x06, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0a, 0x65, 0x78, 0x63, 0x65, 0x65, 0x64, 0x50, 0x6b, 0x74,
+	0x73, 0x12, 0x20, 0x0a, 0x0b, 0x65, 0x78, 0x63, 0x65, 0x65, 0x64, 0x42, 0x79, 0x74, 0x65, 0x73,
+	0x18, 0x07, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0b, 0x65, 0x78, 0x63, 0x65, 0x65, 0x64, 0x42, 0x79,
+	0x74, 0x65, 0x73, 0x22, 0x59, 0x0a, 0x14, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x46, 0x6f, 0x72,
+	0x77, 0x61, 0x72, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x74,
+	0x72, 0x61, 0x63, 0x65, 0x49, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x74, 0x72,
+	0x61, 0x63, 0x65, 0x49, 0x64, 0x12, 0x27, 0x0a, 0x07, 0x65, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73,
+	0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0d, 0x2e, 0x66, 0x77, 0x64, 0x2e, 0x46, 0x77, 0x64,
+	0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x07, 0x65, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x22, 0x6a,
+	0x0a, 0x15, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x46, 0x6f, 0x72, 0x77, 0x61, 0x72, 0x64, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x2b, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75,
+	0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x66, 0x77, 0x64, 0x2e, 0x41, 0x63,
+	0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x52, 0x06, 0x73, 0x74,
+	0x61, 0x74, 0x75, 0x73, 0x12, 0x24, 0x0a, 0x07, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x73, 0x18,
+	0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0a, 0x2e, 0x66, 0x77, 0x64, 0x2e, 0x45, 0x72, 0x72, 0x6f,
+	0x72, 0x52, 0x07, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x73, 0x22, 0x58, 0x0a, 0x14, 0x44, 0x65,
+	0x6c, 0x65, 0x74, 0x65, 0x46, 0x6f, 0x72, 0x77, 0x61, 0x72, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x74, 0x72, 0x61, 0x63, 0x65, 0x49, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x07, 0x74, 0x72, 0x61, 0x63, 0x65, 0x49, 0x64, 0x12, 0x10, 0x0a, 0x03,
+	0x69, 0x70, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x03, 0x69, 0x70, 0x73, 0x12, 0x14,
+	0x0a, 0x05, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x74,
+	0x61, 0x62, 0x6c, 0x65, 0x22, 0x6a, 0x0a, 0x15, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x46, 0x6f,
+	0x72, 0x77, 0x61, 0x72, 0x64, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x2b, 0x0a,
+	0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e,
+	0x66, 0x77, 0x64, 0x2e, 0x41, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x24, 0x0a, 0x07, 0x72, 0x65,
+	0x73, 0x75, 0x6c, 0x74, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0a, 0x2e, 0x66, 0x77,
+	0x64, 0x2e, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x52, 0x07, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x73,
+	0x22, 0x53, 0x0a, 0x13, 0x52, 0x65, 0x6e, 0x65, 0x77, 0x46, 0x6f, 0x72, 0x77, 0x61, 0x72, 0x64,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x74, 0x72, 0x61, 0x63, 0x65,
+	0x49, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x74, 0x72, 0x61, 0x63, 0x65, 0x49,
+	0x64, 0x12, 0x10, 0x0a, 0x03, 0x69, 0x70, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x03,
+	0x69, 0x70, 0x73, 0x12, 0x10, 0x0a, 0x03, 0x74, 0x74, 0x6c, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d,
+	0x52, 0x03, 0x74, 0x74, 0x6c, 0x22, 0x69, 0x0a, 0x14, 0x52, 0x65, 0x6e, 0x65, 0x77, 0x46, 0x6f,
+	0x72, 0x77, 0x61, 0x72, 0x64, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x2b, 0x0a,
+	0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e,
+	0x66, 0x77, 0x64, 0x2e, 0x41, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x24, 0x0a, 0x07, 0x72, 0x65,
+	0x73, 0x75, 0x6c, 0x74, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0a, 0x2e, 0x66, 0x77,
+	0x64, 0x2e, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x52, 0x07, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x73,
+	0x22, 0x47, 0x0a, 0x13, 0x51, 0x75, 0x65, 0x72, 0x79, 0x46, 0x6f, 0x72, 0x77, 0x61, 0x72, 0x64,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x74, 0x72, 0x61, 0x63, 0x65,
+	0x49, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x74, 0x72, 0x61, 0x63, 0x65, 0x49,
+	0x64, 0x12, 0x16, 0x0a, 0x06, 0x6f, 0x72, 0x69, 0x67, 0x69, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x06, 0x6f, 0x72, 0x69, 0x67, 0x69, 0x6e, 0x22, 0x6c, 0x0a, 0x14, 0x51, 0x75, 0x65,
+	0x72, 0x79, 0x46, 0x6f, 0x72, 0x77, 0x61, 0x72, 0x64, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x12, 0x2b, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x13, 0x2e, 0x66, 0x77, 0x64, 0x2e, 0x41, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x27,
+	0x0a, 0x07, 0x65, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x0d, 0x2e, 0x66, 0x77, 0x64, 0x2e, 0x46, 0x77, 0x64, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x07,
+	0x65, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x22, 0x2f, 0x0a, 0x13, 0x46, 0x6c, 0x75, 0x73, 0x68,
+	0x4c, 0x65, 0x61, 0x72, 0x6e, 0x65, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x18,
+	0x0a, 0x07, 0x74, 0x72, 0x61, 0x63, 0x65, 0x49, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x07, 0x74, 0x72, 0x61, 0x63, 0x65, 0x49, 0x64, 0x22, 0x55, 0x0a, 0x14, 0x46, 0x6c, 0x75, 0x73,
+	0x68, 0x4c, 0x65, 0x61, 0x72, 0x6e, 0x65, 0x64, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x12, 0x2b, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x13, 0x2e, 0x66, 0x77, 0x64, 0x2e, 0x41, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x10, 0x0a,
+	0x03, 0x69, 0x70, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x03, 0x69, 0x70, 0x73, 0x22,
+	0x41, 0x0a, 0x13, 0x57, 0x61, 0x74, 0x63, 0x68, 0x46, 0x6f, 0x72, 0x77, 0x61, 0x72, 0x64, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x74, 0x72, 0x61, 0x63, 0x65, 0x49,
+	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x74, 0x72, 0x61, 0x63, 0x65, 0x49, 0x64,
+	0x12, 0x10, 0x0a, 0x03, 0x73, 0x65, 0x71, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x03, 0x73,
+	0x65, 0x71, 0x22, 0x65, 0x0a, 0x08, 0x46, 0x77, 0x64, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x12, 0x22,
+	0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x0e, 0x2e, 0x66,
+	0x77, 0x64, 0x2e, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x54, 0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79,
+	0x70, 0x65, 0x12, 0x23, 0x0a, 0x05, 0x65, 0x6e, 0x74, 0x72, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x0d, 0x2e, 0x66, 0x77, 0x64, 0x2e, 0x46, 0x77, 0x64, 0x45, 0x6e, 0x74, 0x72, 0x79,
+	0x52, 0x05, 0x65, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x73, 0x65, 0x71, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x04, 0x52, 0x03, 0x73, 0x65, 0x71, 0x2a, 0x4e, 0x0a, 0x09, 0x45, 0x76, 0x65,
+	0x6e, 0x74, 0x54, 0x79, 0x70, 0x65, 0x12, 0x10, 0x0a, 0x0c, 0x45, 0x56, 0x45, 0x4e, 0x54, 0x5f,
+	0x55, 0x50, 0x44, 0x41, 0x54, 0x45, 0x10, 0x00, 0x12, 0x10, 0x0a, 0x0c, 0x45, 0x56, 0x45, 0x4e,
+	0x54, 0x5f, 0x44, 0x45, 0x4c, 0x45, 0x54, 0x45, 0x10, 0x01, 0x12, 0x0d, 0x0a, 0x09, 0x45, 0x56,
+	0x45, 0x4e, 0x54, 0x5f, 0x41, 0x44, 0x44, 0x10, 0x02, 0x12, 0x0e, 0x0a, 0x0a, 0x45, 0x56, 0x45,
+	0x4e, 0x54, 0x5f, 0x53, 0x59, 0x4e, 0x43, 0x10, 0x03, 0x32, 0x9f, 0x03, 0x0a, 0x03, 0x46, 0x77,
+	0x64, 0x12, 0x46, 0x0a, 0x0d, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x46, 0x6f, 0x72, 0x77, 0x61,
+	0x72, 0x64, 0x12, 0x19, 0x2e, 0x66, 0x77, 0x64, 0x2e, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x46,
+	0x6f, 0x72, 0x77, 0x61, 0x72, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1a, 0x2e,
+	0x66, 0x77, 0x64, 0x2e, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x46, 0x6f, 0x72, 0x77, 0x61, 0x72,
+	0x64, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x46, 0x0a, 0x0d, 0x44, 0x65, 0x6c,
+	0x65, 0x74, 0x65, 0x46, 0x6f, 0x72, 0x77, 0x61, 0x72, 0x64, 0x12, 0x19, 0x2e, 0x66, 0x77, 0x64,
+	0x2e, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x46, 0x6f, 0x72, 0x77, 0x61, 0x72, 0x64, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1a, 0x2e, 0x66, 0x77, 0x64, 0x2e, 0x44, 0x65, 0x6c, 0x65,
+	0x74, 0x65, 0x46, 0x6f, 0x72, 0x77, 0x61, 0x72, 0x64, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x12, 0x43, 0x0a, 0x0c, 0x51, 0x75, 0x65, 0x72, 0x79, 0x46, 0x6f, 0x72, 0x77, 0x61, 0x72,
+	0x64, 0x12, 0x18, 0x2e, 0x66, 0x77, 0x64, 0x2e, 0x51, 0x75, 0x65, 0x72, 0x79, 0x46, 0x6f, 0x72,
+	0x77, 0x61, 0x72, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x19, 0x2e, 0x66, 0x77,
+	0x64, 0x2e, 0x51, 0x75, 0x65, 0x72, 0x79, 0x46, 0x6f, 0x72, 0x77, 0x61, 0x72, 0x64, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x43, 0x0a, 0x0c, 0x52, 0x65, 0x6e, 0x65, 0x77, 0x46,
+	0x6f, 0x72, 0x77, 0x61, 0x72, 0x64, 0x12, 0x18, 0x2e, 0x66, 0x77, 0x64, 0x2e, 0x52, 0x65, 0x6e,
+	0x65, 0x77, 0x46, 0x6f, 0x72, 0x77, 0x61, 0x72, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x1a, 0x19, 0x2e, 0x66, 0x77, 0x64, 0x2e, 0x52, 0x65, 0x6e, 0x65, 0x77, 0x46, 0x6f, 0x72, 0x77,
+	0x61, 0x72, 0x64, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x43, 0x0a, 0x0c, 0x46,
+	0x6c, 0x75, 0x73, 0x68, 0x4c, 0x65, 0x61, 0x72, 0x6e, 0x65, 0x64, 0x12, 0x18, 0x2e, 0x66, 0x77,
+	0x64, 0x2e, 0x46, 0x6c, 0x75, 0x73, 0x68, 0x4c, 0x65, 0x61, 0x72, 0x6e, 0x65, 0x64, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x19, 0x2e, 0x66, 0x77, 0x64, 0x2e, 0x46, 0x6c, 0x75, 0x73,
+	0x68, 0x4c, 0x65, 0x61, 0x72, 0x6e, 0x65, 0x64, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x12, 0x39, 0x0a, 0x0c, 0x57, 0x61, 0x74, 0x63, 0x68, 0x46, 0x6f, 0x72, 0x77, 0x61, 0x72, 0x64,
+	0x12, 0x18, 0x2e, 0x66, 0x77, 0x64, 0x2e, 0x57, 0x61, 0x74, 0x63, 0x68, 0x46, 0x6f, 0x72, 0x77,
+	0x61, 0x72, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x0d, 0x2e, 0x66, 0x77, 0x64,
+	0x2e, 0x46, 0x77, 0x64, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x30, 0x01, 0x42, 0x09, 0x5a, 0x07, 0x2e,
+	0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_fwd_proto_rawDescOnce sync.Once
+	file_fwd_proto_rawDescData = file_fwd_proto_rawDesc
+)
+
+func file_fwd_proto_rawDescGZIP() []byte {
+	file_fwd_proto_rawDescOnce.Do(func() {
+		file_fwd_proto_rawDescData = protoimpl.X.CompressGZIP(file_fwd_proto_rawDescData)
+	})
+	return file_fwd_proto_rawDescData
+}
+
+var file_fwd_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_fwd_proto_msgTypes = make([]protoimpl.MessageInfo, 17)
+var file_fwd_proto_goTypes = []interface{}{
+	(EventType)(0),                // 0: fwd.EventType
+	(*Error)(nil),                 // 1: fwd.Error
+	(*ActionRequest)(nil),         // 2: fwd.ActionRequest
+	(*ActionResponse)(nil),        // 3: fwd.ActionResponse
+	(*FwdEntry)(nil),              // 4: fwd.FwdEntry
+	(*Policer)(nil),               // 5: fwd.Policer
+	(*UpdateForwardRequest)(nil),  // 6: fwd.UpdateForwardRequest
+	(*UpdateForwardResponse)(nil), // 7: fwd.UpdateForwardResponse
+	(*DeleteForwardRequest)(nil),  // 8: fwd.DeleteForwardRequest
+	(*DeleteForwardResponse)(nil), // 9: fwd.DeleteForwardResponse
+	(*RenewForwardRequest)(nil),   // 10: fwd.RenewForwardRequest
+	(*RenewForwardResponse)(nil),  // 11: fwd.RenewForwardResponse
+	(*QueryForwardRequest)(nil),   // 12: fwd.QueryForwardRequest
+	(*QueryForwardResponse)(nil),  // 13: fwd.QueryForwardResponse
+	(*FlushLearnedRequest)(nil),   // 14: fwd.FlushLearnedRequest
+	(*FlushLearnedResponse)(nil),  // 15: fwd.FlushLearnedResponse
+	(*WatchForwardRequest)(nil),   // 16: fwd.WatchForwardRequest
+	(*FwdEvent)(nil),              // 17: fwd.FwdEvent
+}
+var file_fwd_proto_depIdxs = []int32{
+	1,  // 0: fwd.ActionResponse.errors:type_name -> fwd.Error
+	5,  // 1: fwd.FwdEntry.policer:type_name -> fwd.Policer
+	4,  // 2: fwd.UpdateForwardRequest.entries:type_name -> fwd.FwdEntry
+	3,  // 3: fwd.UpdateForwardResponse.status:type_name -> fwd.ActionResponse
+	1,  // 4: fwd.UpdateForwardResponse.results:type_name -> fwd.Error
+	3,  // 5: fwd.DeleteForwardResponse.status:type_name -> fwd.ActionResponse
+	1,  // 6: fwd.DeleteForwardResponse.results:type_name -> fwd.Error
+	3,  // 7: fwd.RenewForwardResponse.status:type_name -> fwd.ActionResponse
+	1,  // 8: fwd.RenewForwardResponse.results:type_name -> fwd.Error
+	3,  // 9: fwd.QueryForwardResponse.status:type_name -> fwd.ActionResponse
+	4,  // 10: fwd.QueryForwardResponse.entries:type_name -> fwd.FwdEntry
+	3,  // 11: fwd.FlushLearnedResponse.status:type_name -> fwd.ActionResponse
+	0,  // 12: fwd.FwdEvent.type:type_name -> fwd.EventType
+	4,  // 13: fwd.FwdEvent.entry:type_name -> fwd.FwdEntry
+	6,  // 14: fwd.Fwd.UpdateForward:input_type -> fwd.UpdateForwardRequest
+	8,  // 15: fwd.Fwd.DeleteForward:input_type -> fwd.DeleteForwardRequest
+	12, // 16: fwd.Fwd.QueryForward:input_type -> fwd.QueryForwardRequest
+	10, // 17: fwd.Fwd.RenewForward:input_type -> fwd.RenewForwardRequest
+	14, // 18: fwd.Fwd.FlushLearned:input_type -> fwd.FlushLearnedRequest
+	16, // 19: fwd.Fwd.WatchForward:input_type -> fwd.WatchForwardRequest
+	7,  // 20: fwd.Fwd.UpdateForward:output_type -> fwd.UpdateForwardResponse
+	9,  // 21: fwd.Fwd.DeleteForward:output_type -> fwd.DeleteForwardResponse
+	13, // 22: fwd.Fwd.QueryForward:output_type -> fwd.QueryForwardResponse
+	11, // 23: fwd.Fwd.RenewForward:output_type -> fwd.RenewForwardResponse
+	15, // 24: fwd.Fwd.FlushLearned:output_type -> fwd.FlushLearnedResponse
+	17, // 25: fwd.Fwd.WatchForward:output_type -> fwd.FwdEvent
+	20, // [20:26] is the sub-list for method output_type
+	14, // [14:20] is the sub-list for method input_type
+	14, // [14:14] is the sub-list for extension type_name
+	14, // [14:14] is the sub-list for extension extendee
+	0,  // [0:14] is the sub-list for field type_name
+}
+
+func init() { file_fwd_proto_init() }
+func file_fwd_proto_init() {
+	if File_fwd_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_fwd_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Error); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_fwd_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ActionRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_fwd_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ActionResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_fwd_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*FwdEntry); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_fwd_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Policer); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_fwd_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*UpdateForwardRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_fwd_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*UpdateForwardResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_fwd_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DeleteForwardRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_fwd_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DeleteForwardResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_fwd_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RenewForwardRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_fwd_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RenewForwardResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_fwd_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*QueryForwardRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_fwd_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*QueryForwardResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_fwd_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*FlushLearnedRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_fwd_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*FlushLearnedResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_fwd_proto_msgTypes[15].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*WatchForwardRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_fwd_proto_msgTypes[16].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*FwdEvent); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -290,13 +1666,14 @@ func file_fwd_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_fwd_proto_rawDesc,
-			NumEnums:      0,
-			NumMessages:   3,
+			NumEnums:      1,
+			NumMessages:   17,
 			NumExtensions: 0,
-			NumServices:   0,
+			NumServices:   1,
 		},
 		GoTypes:           file_fwd_proto_goTypes,
 		DependencyIndexes: file_fwd_proto_depIdxs,
+		EnumInfos:         file_fwd_proto_enumTypes,
 		MessageInfos:      file_fwd_proto_msgTypes,
 	}.Build()
 	File_fwd_proto = out.File
@@ -0,0 +1,313 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package proto
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// FwdClient is the client API for Fwd service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type FwdClient interface {
+	UpdateForward(ctx context.Context, in *UpdateForwardRequest, opts ...grpc.CallOption) (*UpdateForwardResponse, error)
+	DeleteForward(ctx context.Context, in *DeleteForwardRequest, opts ...grpc.CallOption) (*DeleteForwardResponse, error)
+	QueryForward(ctx context.Context, in *QueryForwardRequest, opts ...grpc.CallOption) (*QueryForwardResponse, error)
+	RenewForward(ctx context.Context, in *RenewForwardRequest, opts ...grpc.CallOption) (*RenewForwardResponse, error)
+	//清除XDP学习的表项
+	FlushLearned(ctx context.Context, in *FlushLearnedRequest, opts ...grpc.CallOption) (*FlushLearnedResponse, error)
+	//订阅表项变更
+	WatchForward(ctx context.Context, in *WatchForwardRequest, opts ...grpc.CallOption) (Fwd_WatchForwardClient, error)
+}
+
+type fwdClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewFwdClient(cc grpc.ClientConnInterface) FwdClient {
+	return &fwdClient{cc}
+}
+
+func (c *fwdClient) UpdateForward(ctx context.Context, in *UpdateForwardRequest, opts ...grpc.CallOption) (*UpdateForwardResponse, error) {
+	out := new(UpdateForwardResponse)
+	err := c.cc.Invoke(ctx, "/fwd.Fwd/UpdateForward", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fwdClient) DeleteForward(ctx context.Context, in *DeleteForwardRequest, opts ...grpc.CallOption) (*DeleteForwardResponse, error) {
+	out := new(DeleteForwardResponse)
+	err := c.cc.Invoke(ctx, "/fwd.Fwd/DeleteForward", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fwdClient) QueryForward(ctx context.Context, in *QueryForwardRequest, opts ...grpc.CallOption) (*QueryForwardResponse, error) {
+	out := new(QueryForwardResponse)
+	err := c.cc.Invoke(ctx, "/fwd.Fwd/QueryForward", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fwdClient) RenewForward(ctx context.Context, in *RenewForwardRequest, opts ...grpc.CallOption) (*RenewForwardResponse, error) {
+	out := new(RenewForwardResponse)
+	err := c.cc.Invoke(ctx, "/fwd.Fwd/RenewForward", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fwdClient) FlushLearned(ctx context.Context, in *FlushLearnedRequest, opts ...grpc.CallOption) (*FlushLearnedResponse, error) {
+	out := new(FlushLearnedResponse)
+	err := c.cc.Invoke(ctx, "/fwd.Fwd/FlushLearned", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fwdClient) WatchForward(ctx context.Context, in *WatchForwardRequest, opts ...grpc.CallOption) (Fwd_WatchForwardClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Fwd_ServiceDesc.Streams[0], "/fwd.Fwd/WatchForward", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &fwdWatchForwardClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Fwd_WatchForwardClient interface {
+	Recv() (*FwdEvent, error)
+	grpc.ClientStream
+}
+
+type fwdWatchForwardClient struct {
+	grpc.ClientStream
+}
+
+func (x *fwdWatchForwardClient) Recv() (*FwdEvent, error) {
+	m := new(FwdEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// FwdServer is the server API for Fwd service.
+// All implementations must embed UnimplementedFwdServer
+// for forward compatibility
+type FwdServer interface {
+	UpdateForward(context.Context, *UpdateForwardRequest) (*UpdateForwardResponse, error)
+	DeleteForward(context.Context, *DeleteForwardRequest) (*DeleteForwardResponse, error)
+	QueryForward(context.Context, *QueryForwardRequest) (*QueryForwardResponse, error)
+	RenewForward(context.Context, *RenewForwardRequest) (*RenewForwardResponse, error)
+	//清除XDP学习的表项
+	FlushLearned(context.Context, *FlushLearnedRequest) (*FlushLearnedResponse, error)
+	//订阅表项变更
+	WatchForward(*WatchForwardRequest, Fwd_WatchForwardServer) error
+	mustEmbedUnimplementedFwdServer()
+}
+
+// UnimplementedFwdServer must be embedded to have forward compatible implementations.
+type UnimplementedFwdServer struct {
+}
+
+func (UnimplementedFwdServer) UpdateForward(context.Context, *UpdateForwardRequest) (*UpdateForwardResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateForward not implemented")
+}
+func (UnimplementedFwdServer) DeleteForward(context.Context, *DeleteForwardRequest) (*DeleteForwardResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteForward not implemented")
+}
+func (UnimplementedFwdServer) QueryForward(context.Context, *QueryForwardRequest) (*QueryForwardResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method QueryForward not implemented")
+}
+func (UnimplementedFwdServer) RenewForward(context.Context, *RenewForwardRequest) (*RenewForwardResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RenewForward not implemented")
+}
+func (UnimplementedFwdServer) FlushLearned(context.Context, *FlushLearnedRequest) (*FlushLearnedResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FlushLearned not implemented")
+}
+func (UnimplementedFwdServer) WatchForward(*WatchForwardRequest, Fwd_WatchForwardServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchForward not implemented")
+}
+func (UnimplementedFwdServer) mustEmbedUnimplementedFwdServer() {}
+
+// UnsafeFwdServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to FwdServer will
+// result in compilation errors.
+type UnsafeFwdServer interface {
+	mustEmbedUnimplementedFwdServer()
+}
+
+func RegisterFwdServer(s grpc.ServiceRegistrar, srv FwdServer) {
+	s.RegisterService(&Fwd_ServiceDesc, srv)
+}
+
+func _Fwd_UpdateForward_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateForwardRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FwdServer).UpdateForward(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/fwd.Fwd/UpdateForward",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FwdServer).UpdateForward(ctx, req.(*UpdateForwardRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Fwd_DeleteForward_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteForwardRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FwdServer).DeleteForward(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/fwd.Fwd/DeleteForward",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FwdServer).DeleteForward(ctx, req.(*DeleteForwardRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Fwd_QueryForward_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryForwardRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FwdServer).QueryForward(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/fwd.Fwd/QueryForward",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FwdServer).QueryForward(ctx, req.(*QueryForwardRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Fwd_RenewForward_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RenewForwardRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FwdServer).RenewForward(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/fwd.Fwd/RenewForward",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FwdServer).RenewForward(ctx, req.(*RenewForwardRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Fwd_FlushLearned_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FlushLearnedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FwdServer).FlushLearned(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/fwd.Fwd/FlushLearned",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FwdServer).FlushLearned(ctx, req.(*FlushLearnedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Fwd_WatchForward_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchForwardRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(FwdServer).WatchForward(m, &fwdWatchForwardServer{stream})
+}
+
+type Fwd_WatchForwardServer interface {
+	Send(*FwdEvent) error
+	grpc.ServerStream
+}
+
+type fwdWatchForwardServer struct {
+	grpc.ServerStream
+}
+
+func (x *fwdWatchForwardServer) Send(m *FwdEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Fwd_ServiceDesc is the grpc.ServiceDesc for Fwd service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Fwd_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "fwd.Fwd",
+	HandlerType: (*FwdServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "UpdateForward",
+			Handler:    _Fwd_UpdateForward_Handler,
+		},
+		{
+			MethodName: "DeleteForward",
+			Handler:    _Fwd_DeleteForward_Handler,
+		},
+		{
+			MethodName: "QueryForward",
+			Handler:    _Fwd_QueryForward_Handler,
+		},
+		{
+			MethodName: "RenewForward",
+			Handler:    _Fwd_RenewForward_Handler,
+		},
+		{
+			MethodName: "FlushLearned",
+			Handler:    _Fwd_FlushLearned_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchForward",
+			Handler:       _Fwd_WatchForward_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "fwd.proto",
+}
@@ -0,0 +1,328 @@
+package fwd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/advancevillage/fwd/pkg/fwd"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	//文件变更检测周期
+	routeFileInterval = time.Second * 2
+)
+
+//静态路由文件条目, 字段与UpdateForward一致
+//
+//eg: routes.yaml
+//
+// - ip: 10.1.0.0/16
+//   iface: 4
+//   srcMac: 08:00:27:f3:81:0e
+//   dstMac: f8:ff:27:f3:81:0e
+// - ip: 10.2.0.0/16
+//   group: 7
+// - ip: 10.3.0.0/16
+//   ifName: eth1
+//   srcMac: 08:00:27:f3:81:0f
+//   dstMac: f8:ff:27:f3:81:0f
+// - ip: 192.0.2.10
+//   action: drop
+type routeEntry struct {
+	Ip     string `json:"ip" yaml:"ip"`
+	Iface  uint32 `json:"iface" yaml:"iface"`
+	IfName string `json:"ifName" yaml:"ifName"`
+	SrcMac string `json:"srcMac" yaml:"srcMac"`
+	DstMac string `json:"dstMac" yaml:"dstMac"`
+	Group  uint32 `json:"group" yaml:"group"`
+	Action string `json:"action" yaml:"action"`
+}
+
+//最近一次对账结果
+//Missing 转发表中缺失的路由
+//Drifted 转发表中与文件不一致的路由(实际值)
+//Failed  重新下发失败的路由
+type routeDrift struct {
+	File      string
+	Routes    int
+	Time      time.Time
+	Missing   []*fwd.FwdElem
+	Drifted   []*fwd.FwdElem
+	Failed    []*fwd.FwdElem
+	LoadError string
+}
+
+type router struct {
+	mu     sync.Mutex
+	file   string
+	sum    [sha256.Size]byte
+	routes []*fwd.FwdElem
+	drift  *routeDrift
+}
+
+func newRouter(file string) *router {
+	return &router{file: file, drift: &routeDrift{File: file}}
+}
+
+//文件内容未变化时返回false
+func (r *router) load() (bool, error) {
+	var b, err = ioutil.ReadFile(r.file)
+	if err != nil {
+		return false, err
+	}
+	var sum = sha256.Sum256(b)
+	r.mu.Lock()
+	var same = sum == r.sum && r.routes != nil
+	r.mu.Unlock()
+	if same {
+		return false, nil
+	}
+	routes, err := parseRoutes(r.file, b)
+	if err != nil {
+		return false, err
+	}
+	r.mu.Lock()
+	r.sum = sum
+	r.routes = routes
+	r.mu.Unlock()
+	return true, nil
+}
+
+//按扩展名解析YAML, 其余按JSON解析
+func parseRoutes(file string, b []byte) ([]*fwd.FwdElem, error) {
+	var (
+		entries = make([]*routeEntry, 0, 2)
+		err     error
+	)
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &entries)
+	default:
+		err = json.Unmarshal(b, &entries)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var (
+		routes = make([]*fwd.FwdElem, 0, len(entries))
+		keys   = make(map[string]bool, len(entries))
+	)
+	for i, e := range entries {
+		if e == nil {
+			return nil, fmt.Errorf("route %d is empty", i)
+		}
+		var elem, err = normRoute(e)
+		if err != nil {
+			return nil, fmt.Errorf("route %d %s: %w", i, e.Ip, err)
+		}
+		var k = fmt.Sprintf("%s/%d", elem.Ip, elem.Prefix)
+		if keys[k] {
+			return nil, fmt.Errorf("route %d %s is duplicated", i, e.Ip)
+		}
+		keys[k] = true
+		routes = append(routes, elem)
+	}
+	return routes, nil
+}
+
+//转换为与QryFwd一致的表示, 便于比对
+// 10.1.2.3/16 -> 10.1.0.0 16
+// 10.1.2.3    -> 10.1.2.3 32
+func normRoute(e *routeEntry) (*fwd.FwdElem, error) {
+	var (
+		elem = &fwd.FwdElem{Iface: e.Iface, IfName: e.IfName, Group: e.Group}
+		ip   net.IP
+		ones int
+	)
+	if strings.Contains(e.Ip, "/") {
+		var _, ipnet, err = net.ParseCIDR(e.Ip)
+		if err != nil {
+			return nil, errors.New("invalid prefix format")
+		}
+		ip = ipnet.IP
+		ones, _ = ipnet.Mask.Size()
+	} else {
+		ip = net.ParseIP(e.Ip)
+		if ip == nil {
+			return nil, errors.New("invalid ip format")
+		}
+		ones = 128
+		if ip.To4() != nil {
+			ones = 32
+		}
+	}
+	elem.Ip = ip.String()
+	elem.Prefix = ones
+	switch e.Action {
+	case "", fwd.ActionRedirect:
+		elem.Action = fwd.ActionRedirect
+	case fwd.ActionDrop, fwd.ActionPass:
+		elem.Action = e.Action
+		elem.Iface, elem.IfName, elem.Group = 0, "", 0
+		elem.SrcMac = "00:00:00:00:00:00"
+		elem.DstMac = "00:00:00:00:00:00"
+		return elem, nil
+	default:
+		return nil, fwd.ErrFwdAction
+	}
+	if e.Group > 0 {
+		elem.SrcMac = "00:00:00:00:00:00"
+		elem.DstMac = "00:00:00:00:00:00"
+		return elem, nil
+	}
+	src, err := net.ParseMAC(e.SrcMac)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := net.ParseMAC(e.DstMac)
+	if err != nil {
+		return nil, err
+	}
+	elem.SrcMac = src.String()
+	elem.DstMac = dst.String()
+	return elem, nil
+}
+
+//比对期望路由与实际转发表, 返回缺失及不一致(实际值)的路由
+//期望路由指定IfName时按出接口名比对, 按路由表区分同一前缀的主表与策略表项
+func diffRoutes(routes []*fwd.FwdElem, actual []*fwd.FwdElem) ([]*fwd.FwdElem, []*fwd.FwdElem, []*fwd.FwdElem) {
+	var (
+		cur     = make(map[string]*fwd.FwdElem, len(actual))
+		missing = make([]*fwd.FwdElem, 0, 2)
+		drifted = make([]*fwd.FwdElem, 0, 2)
+		install = make([]*fwd.FwdElem, 0, 2)
+	)
+	for _, e := range actual {
+		cur[fmt.Sprintf("%d:%s/%d", e.Table, e.Ip, e.Prefix)] = e
+	}
+	for _, e := range routes {
+		var a, ok = cur[fmt.Sprintf("%d:%s/%d", e.Table, e.Ip, e.Prefix)]
+		switch {
+		case !ok:
+			missing = append(missing, e)
+			install = append(install, e)
+		case a.Action != e.Action:
+			drifted = append(drifted, a)
+			install = append(install, e)
+		case e.Group <= 0 && len(e.IfName) > 0 && a.IfName != e.IfName:
+			drifted = append(drifted, a)
+			install = append(install, e)
+		case (e.Group > 0 || len(e.IfName) <= 0) && a.Iface != e.Iface:
+			drifted = append(drifted, a)
+			install = append(install, e)
+		case a.SrcMac != e.SrcMac || a.DstMac != e.DstMac || a.Group != e.Group:
+			drifted = append(drifted, a)
+			install = append(install, e)
+		case a.Origin == fwd.OriginLearned:
+			//XDP学习的表项与期望一致, 转为静态避免被FlushLearned清除
+			install = append(install, e)
+		}
+	}
+	return missing, drifted, install
+}
+
+func (r *router) report() *routeDrift {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var d = *r.drift
+	return &d
+}
+
+//对账: 缺失或不一致的路由重新下发
+func (s *Srv) reconcile(ctx context.Context) {
+	var r = s.router
+	r.mu.Lock()
+	var routes = r.routes
+	r.mu.Unlock()
+	if routes == nil {
+		return
+	}
+	var actual, err = s.fwdCli.QryFwd(ctx)
+	if err != nil {
+		s.logger.Errorw(ctx, "reconcile routes fail", "err", err)
+		return
+	}
+	var (
+		missing, drifted, install = diffRoutes(routes, actual)
+		failed                    = make([]*fwd.FwdElem, 0, 2)
+	)
+	for _, e := range missing {
+		s.logger.Warnw(ctx, "route missing", "ip", e.Ip, "prefix", e.Prefix)
+	}
+	for _, e := range drifted {
+		s.logger.Warnw(ctx, "route drifted", "ip", e.Ip, "prefix", e.Prefix, "iface", e.Iface, "srcMac", e.SrcMac, "dstMac", e.DstMac, "group", e.Group)
+	}
+	if len(install) > 0 {
+		var errs = s.fwdCli.UptFwdBatch(ctx, install)
+		for i := range errs {
+			if errs[i] == nil {
+				continue
+			}
+			s.logger.Errorw(ctx, "reinstall route fail", "ip", install[i].Ip, "prefix", install[i].Prefix, "err", errs[i])
+			failed = append(failed, install[i])
+		}
+		s.watcher.trigger()
+	}
+	r.mu.Lock()
+	r.drift = &routeDrift{
+		File:    r.file,
+		Routes:  len(routes),
+		Time:    time.Now(),
+		Missing: missing,
+		Drifted: drifted,
+		Failed:  failed,
+	}
+	r.mu.Unlock()
+}
+
+//周期对账, 文件变更时重新加载并立即对账
+func (s *Srv) routes() {
+	var (
+		ticker = time.NewTicker(time.Duration(s.cfg.RoutesCfg.Interval) * time.Second)
+		check  = time.NewTicker(routeFileInterval)
+	)
+	defer ticker.Stop()
+	defer check.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			s.reconcile(s.ctx)
+		case <-check.C:
+			if s.loadRoutes(s.ctx) {
+				s.reconcile(s.ctx)
+			}
+		}
+	}
+}
+
+//加载失败时保留上次成功加载的路由
+func (s *Srv) loadRoutes(ctx context.Context) bool {
+	var changed, err = s.router.load()
+	s.router.mu.Lock()
+	if err != nil {
+		s.router.drift.LoadError = err.Error()
+	} else {
+		s.router.drift.LoadError = ""
+	}
+	s.router.mu.Unlock()
+	if err != nil {
+		s.logger.Errorw(ctx, "load routes fail", "file", s.router.file, "err", err)
+		return false
+	}
+	if changed {
+		s.logger.Infow(ctx, "load routes", "file", s.router.file)
+	}
+	return changed
+}
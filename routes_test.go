@@ -0,0 +1,93 @@
+package fwd
+
+import (
+	"testing"
+
+	"github.com/advancevillage/fwd/pkg/fwd"
+	"github.com/stretchr/testify/assert"
+)
+
+var routesTest = map[string]struct {
+	file   string
+	data   string
+	routes []*fwd.FwdElem
+	err    bool
+}{
+	"case-yaml": {
+		file: "routes.yaml",
+		data: "- ip: 10.1.2.3/16\n  iface: 4\n  srcMac: 08:00:27:F3:81:0E\n  dstMac: f8:ff:27:f3:81:0e\n- ip: 10.0.0.1\n  group: 7\n",
+		routes: []*fwd.FwdElem{
+			{Ip: "10.1.0.0", Prefix: 16, Iface: 4, SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e", Action: fwd.ActionRedirect},
+			{Ip: "10.0.0.1", Prefix: 32, Group: 7, SrcMac: "00:00:00:00:00:00", DstMac: "00:00:00:00:00:00", Action: fwd.ActionRedirect},
+		},
+	},
+	"case-json": {
+		file: "routes.json",
+		data: `[{"ip":"fd00::1","iface":3,"srcMac":"08:00:27:f3:81:0e","dstMac":"f8:ff:27:f3:81:0e"}]`,
+		routes: []*fwd.FwdElem{
+			{Ip: "fd00::1", Prefix: 128, Iface: 3, SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e", Action: fwd.ActionRedirect},
+		},
+	},
+	"case-blackhole": {
+		file: "routes.yaml",
+		data: "- ip: 192.0.2.10\n  action: drop\n  iface: 4\n",
+		routes: []*fwd.FwdElem{
+			{Ip: "192.0.2.10", Prefix: 32, SrcMac: "00:00:00:00:00:00", DstMac: "00:00:00:00:00:00", Action: fwd.ActionDrop},
+		},
+	},
+	"case-invalid-action": {
+		file: "routes.json",
+		data: `[{"ip":"10.0.0.1","action":"reject"}]`,
+		err:  true,
+	},
+	"case-duplicated": {
+		file: "routes.yaml",
+		data: "- ip: 10.0.0.1/32\n  group: 7\n- ip: 10.0.0.1\n  group: 8\n",
+		err:  true,
+	},
+	"case-invalid-mac": {
+		file: "routes.json",
+		data: `[{"ip":"10.0.0.1","iface":3,"srcMac":"08:00:27","dstMac":"f8:ff:27:f3:81:0e"}]`,
+		err:  true,
+	},
+}
+
+func Test_parse_routes(t *testing.T) {
+	for n, p := range routesTest {
+		f := func(t *testing.T) {
+			var routes, err = parseRoutes(p.file, []byte(p.data))
+			assert.Equal(t, p.err, err != nil)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, p.routes, routes)
+		}
+		t.Run(n, f)
+	}
+}
+
+func Test_diff_routes(t *testing.T) {
+	var (
+		routes = []*fwd.FwdElem{
+			{Ip: "10.0.0.1", Prefix: 32, Iface: 4, SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e"},
+			{Ip: "10.1.0.0", Prefix: 16, Iface: 4, SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e"},
+			{Ip: "10.2.0.0", Prefix: 16, Group: 7, SrcMac: "00:00:00:00:00:00", DstMac: "00:00:00:00:00:00"},
+			{Ip: "10.4.0.0", Prefix: 16, IfName: "eth1", SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e"},
+			{Ip: "10.5.0.1", Prefix: 32, Iface: 4, SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e"},
+		}
+		actual = []*fwd.FwdElem{
+			{Ip: "10.0.0.1", Prefix: 32, Iface: 4, SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e", Packets: 10},
+			{Ip: "10.1.0.0", Prefix: 16, Iface: 5, SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e"},
+			{Ip: "10.3.0.0", Prefix: 16, Iface: 5, SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e"},
+			{Ip: "10.4.0.0", Prefix: 16, Iface: 2, IfName: "eth1", SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e"},
+			{Ip: "10.5.0.1", Prefix: 32, Iface: 4, SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e", Origin: fwd.OriginLearned},
+			//策略表中的同前缀表项不影响主表比对
+			{Ip: "10.0.0.1", Prefix: 32, Iface: 6, SrcMac: "08:00:27:f3:81:0e", DstMac: "f8:ff:27:f3:81:0e", Table: 3},
+			{Ip: "10.2.0.0", Prefix: 16, Group: 7, SrcMac: "00:00:00:00:00:00", DstMac: "00:00:00:00:00:00", Table: 3},
+		}
+	)
+	var missing, drifted, install = diffRoutes(routes, actual)
+	assert.Equal(t, []*fwd.FwdElem{routes[2]}, missing)
+	assert.Equal(t, []*fwd.FwdElem{actual[1]}, drifted)
+	assert.Equal(t, []*fwd.FwdElem{routes[1], routes[2], routes[4]}, install)
+}
@@ -3,11 +3,19 @@ package fwd
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
+	"time"
 
 	"github.com/advancevillage/3rd/logx"
 	"github.com/advancevillage/3rd/netx"
+	"github.com/advancevillage/fwd/pkg/bpf"
 	"github.com/advancevillage/fwd/pkg/fwd"
+	"google.golang.org/grpc"
+)
+
+var (
+	progName = "xfwd"
 )
 
 type SrvCfg struct {
@@ -19,12 +27,77 @@ type SrvCfg struct {
 		Host string `json:"host"`
 		Port int    `json:"port"`
 	} `json:"httpCfg"`
+
+	BpfCfg struct {
+		Backend string `json:"backend"` //bpftool or syscall
+	} `json:"bpfCfg"`
+
+	XdpCfg struct {
+		Obj    string   `json:"obj"`    //eg: xdp/fwd.bpf.o
+		Mode   string   `json:"mode"`   //auto native generic offload
+		Ifaces []string `json:"ifaces"` //挂载XDP程序的网卡
+	} `json:"xdpCfg"`
+
+	GrpcCfg struct {
+		Host string `json:"host"`
+		Port int    `json:"port"` //0 不开启
+	} `json:"grpcCfg"`
+
+	//netx仅支持JSON响应, 指标及变更推送单独监听 GET /metrics GET /watch
+	MetricsCfg struct {
+		Host          string `json:"host"`
+		Port          int    `json:"port"`          //0 不开启
+		UsageInterval int    `json:"usageInterval"` //表项数刷新周期 秒 默认60
+	} `json:"metricsCfg"`
+
+	//表项变更推送
+	WatchCfg struct {
+		Interval int `json:"interval"` //有订阅方时比对周期 秒 默认1
+	} `json:"watchCfg"`
+
+	//静态路由文件, 周期及文件变更时与转发表对账
+	RoutesCfg struct {
+		File     string `json:"file"`     //eg: conf/routes.yaml 空 不开启
+		Interval int    `json:"interval"` //对账周期 秒
+	} `json:"routesCfg"`
+
+	//转发表快照
+	SnapshotCfg struct {
+		File     string `json:"file"`     //eg: /var/lib/xfwd/fwd.snap
+		Interval int    `json:"interval"` //保存周期 秒 0 不开启
+		Restore  bool   `json:"restore"`  //启动时转发表为空则从快照恢复
+	} `json:"snapshotCfg"`
+
+	//通过netlink将内核路由同步至转发表
+	RouteSyncCfg struct {
+		Enable           bool     `json:"enable"`
+		Tables           []uint32 `json:"tables"`           //为空时仅同步main(254)
+		ExcludeTables    []uint32 `json:"excludeTables"`    //优先于tables
+		Protocols        []string `json:"protocols"`        //eg: static bgp 186 为空时同步全部
+		ExcludeProtocols []string `json:"excludeProtocols"` //eg: kernel
+	} `json:"routeSyncCfg"`
+
+	//按源地址报文速率自动封禁
+	BanCfg struct {
+		Enable   bool   `json:"enable"`
+		Pps      uint64 `json:"pps"`      //源地址pps阈值
+		Ttl      int    `json:"ttl"`      //封禁时长 秒 0 永久
+		Interval int    `json:"interval"` //检测周期 秒 每周期遍历全部源地址计数
+	} `json:"banCfg"`
 }
 
 type Srv struct {
 	cfg     *SrvCfg
 	fwdCli  fwd.IFwd
+	progCli bpf.IProg
 	httpSrv netx.IHTTPServer
+	grpcSrv *grpc.Server
+	watcher *watcher
+	router  *router
+	rtSync  fwd.IRouteSync
+	banner  fwd.IBanDetector
+	metrics *srvMetrics
+	metrSrv *http.Server
 	logger  logx.ILogger
 	ctx     context.Context
 	cancel  context.CancelFunc
@@ -50,10 +123,65 @@ func NewSrv(cfg *SrvCfg) (*Srv, error) {
 		panic(err)
 	}
 	//3. fw
-	fwdCli, err := fwd.NewFwdClient(logger)
+	s.metrics = newSrvMetrics()
+	fwdCli, err := fwd.NewFwdClient(logger, fwd.WithBackend(cfg.BpfCfg.Backend), fwd.WithObserver(s.metrics.observe))
 	if err != nil {
 		panic(err)
 	}
+	s.metrics.registry.Collect(s.metrics.collect(s))
+	if cfg.MetricsCfg.Port > 0 {
+		var mux = http.NewServeMux()
+		mux.Handle("/metrics", s.metrics.registry.Handler())
+		mux.HandleFunc("/watch", s.watchStream)
+		s.metrSrv = &http.Server{
+			Addr:    fmt.Sprintf("%s:%d", cfg.MetricsCfg.Host, cfg.MetricsCfg.Port),
+			Handler: mux,
+		}
+	}
+	//4. xdp
+	if len(cfg.XdpCfg.Ifaces) > 0 {
+		var dev = ""
+		if cfg.XdpCfg.Mode == bpf.ModeOffload {
+			dev = cfg.XdpCfg.Ifaces[0]
+		}
+		progCli, err := bpf.NewProgClient(logger, cfg.XdpCfg.Obj, progName, cfg.XdpCfg.Mode, dev)
+		if err != nil {
+			panic(err)
+		}
+		s.progCli = progCli
+	}
+
+	//5. grpc
+	s.watcher = newWatcher()
+	if cfg.GrpcCfg.Port > 0 {
+		s.grpcSrv = newGrpcSrv(s)
+	}
+	//6. routes
+	if len(cfg.RoutesCfg.File) > 0 {
+		s.router = newRouter(cfg.RoutesCfg.File)
+	}
+	if cfg.RouteSyncCfg.Enable {
+		src, err := fwd.NewNetlinkSource(logger)
+		if err != nil {
+			panic(err)
+		}
+		filter, err := routeFilter(cfg)
+		if err != nil {
+			panic(err)
+		}
+		s.rtSync = fwd.NewRouteSync(logger, fwdCli, src, filter)
+	}
+	//7. ban
+	if cfg.BanCfg.Enable {
+		s.banner, err = fwd.NewBanDetector(logger, fwdCli, &fwd.BanPolicy{
+			Pps:      cfg.BanCfg.Pps,
+			Ttl:      time.Duration(cfg.BanCfg.Ttl) * time.Second,
+			Interval: time.Duration(cfg.BanCfg.Interval) * time.Second,
+		})
+		if err != nil {
+			panic(err)
+		}
+	}
 
 	s.logger = logger
 	s.httpSrv = srv
@@ -66,11 +194,190 @@ func NewSrv(cfg *SrvCfg) (*Srv, error) {
 }
 
 func (s *Srv) Start() {
+	var err = s.attach()
+	if err != nil {
+		s.logger.Errorw(s.ctx, "attach xdp fail", "err", err)
+		s.detach()
+		return
+	}
+	s.restore(s.ctx)
+	//先同步一次, 订阅方首次即可获取全量
+	s.syncWatch(s.ctx)
+	go s.watch()
+	//删除租约到期的表项, 由watch推送删除事件
+	go s.fwdCli.RunExpiry(s.ctx)
+	//静态路由先于API下发, 文件错误时不影响启动
+	if s.router != nil {
+		s.loadRoutes(s.ctx)
+		s.reconcile(s.ctx)
+		go s.routes()
+	}
+	if s.cfg.SnapshotCfg.Interval > 0 {
+		go s.snapshots()
+	}
+	if s.rtSync != nil {
+		go s.routeSync()
+	}
+	//未开启自动封禁时XDP不计数, 关闭配置后重启即停止计数
+	err = s.fwdCli.CountSources(s.ctx, s.banner != nil)
+	if err != nil {
+		s.logger.Errorw(s.ctx, "count sources fail", "err", err)
+	}
+	if s.banner != nil {
+		go s.banSources()
+	}
 	s.logger.Infow(s.ctx, "start server", "listen http", fmt.Sprintf("%s:%d", s.cfg.HttpCfg.Host, s.cfg.HttpCfg.Port))
 	go s.httpSrv.Start()
+	if s.grpcSrv != nil {
+		var lis, err = net.Listen("tcp", fmt.Sprintf("%s:%d", s.cfg.GrpcCfg.Host, s.cfg.GrpcCfg.Port))
+		if err != nil {
+			s.logger.Errorw(s.ctx, "listen grpc fail", "err", err)
+			s.cancel()
+		} else {
+			s.logger.Infow(s.ctx, "start grpc", "listen grpc", lis.Addr().String())
+			go s.serveGrpc(lis)
+		}
+	}
+	if s.metrSrv != nil {
+		s.logger.Infow(s.ctx, "start metrics", "listen http", s.metrSrv.Addr)
+		go s.usages()
+		go s.serveMetrics()
+	}
 	select {
 	case <-s.httpSrv.Exit():
 	case <-s.ctx.Done():
 	}
+	s.stopGrpc()
+	s.stopMetrics()
+	s.stopSnapshot()
+	s.detach()
 	s.logger.Infow(s.ctx, "exit server", "listen http", fmt.Sprintf("%s:%d", s.cfg.HttpCfg.Host, s.cfg.HttpCfg.Port))
 }
+
+//加载XDP程序并挂载到网卡
+func (s *Srv) attach() error {
+	if s.progCli == nil {
+		return nil
+	}
+	//1. 转发表需先固定(pin), 加载时复用
+	var maps, err = s.fwdCli.Tables(s.ctx)
+	if err != nil {
+		return err
+	}
+	//2. 清理上次异常退出残留的程序
+	_ = s.progCli.GCProg(s.ctx)
+
+	err = s.progCli.LoadProg(s.ctx, maps)
+	if err != nil {
+		return err
+	}
+	//3. 挂载
+	for _, iface := range s.cfg.XdpCfg.Ifaces {
+		err = s.progCli.AttachProg(s.ctx, iface)
+		if err != nil {
+			return fmt.Errorf("attach %s: %w", iface, err)
+		}
+		s.logger.Infow(s.ctx, "attach xdp", "iface", iface, "mode", s.cfg.XdpCfg.Mode)
+	}
+	return nil
+}
+
+//卸载XDP程序, 转发表保持固定(pin)
+func (s *Srv) detach() {
+	if s.progCli == nil {
+		return
+	}
+	//服务退出时s.ctx已取消
+	var ctx, cancel = context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	for _, iface := range s.cfg.XdpCfg.Ifaces {
+		var err = s.progCli.DetachProg(ctx, iface)
+		if err != nil {
+			s.logger.Errorw(ctx, "detach xdp fail", "iface", iface, "err", err)
+			continue
+		}
+		s.logger.Infow(ctx, "detach xdp", "iface", iface)
+	}
+	var err = s.progCli.GCProg(ctx)
+	if err != nil {
+		s.logger.Errorw(ctx, "gc xdp fail", "err", err)
+	}
+}
+
+func (s *Srv) serveMetrics() {
+	var err = s.metrSrv.ListenAndServe()
+	if err != nil && err != http.ErrServerClosed {
+		s.logger.Errorw(s.ctx, "metrics server", "start", err)
+	}
+}
+
+func (s *Srv) stopMetrics() {
+	if s.metrSrv == nil {
+		return
+	}
+	var ctx, cancel = context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	_ = s.metrSrv.Shutdown(ctx)
+}
+
+func (s *Srv) serveGrpc(lis net.Listener) {
+	var err = s.grpcSrv.Serve(lis)
+	if err != nil && err != grpc.ErrServerStopped {
+		s.logger.Errorw(s.ctx, "grpc server", "start", err)
+	}
+}
+
+//WatchForward 随s.ctx退出, 不会阻塞优雅关闭
+func (s *Srv) stopGrpc() {
+	if s.grpcSrv == nil {
+		return
+	}
+	s.grpcSrv.GracefulStop()
+}
+
+//退出前保存最后一次快照
+func (s *Srv) stopSnapshot() {
+	if s.cfg.SnapshotCfg.Interval <= 0 {
+		return
+	}
+	var ctx, cancel = context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	s.snapshot(ctx)
+}
+
+func (s *Srv) routeSync() {
+	var err = s.rtSync.Run(s.ctx)
+	if err != nil {
+		s.logger.Errorw(s.ctx, "route sync exit", "err", err)
+	}
+}
+
+func (s *Srv) banSources() {
+	var err = s.banner.Run(s.ctx)
+	if err != nil {
+		s.logger.Errorw(s.ctx, "ban detector exit", "err", err)
+	}
+}
+
+func routeFilter(cfg *SrvCfg) (*fwd.RouteFilter, error) {
+	var f = &fwd.RouteFilter{
+		Tables:        cfg.RouteSyncCfg.Tables,
+		ExcludeTables: cfg.RouteSyncCfg.ExcludeTables,
+	}
+	for _, name := range cfg.RouteSyncCfg.Protocols {
+		var p, err = fwd.ParseRouteProtocol(name)
+		if err != nil {
+			return nil, err
+		}
+		f.Protocols = append(f.Protocols, p)
+	}
+	for _, name := range cfg.RouteSyncCfg.ExcludeProtocols {
+		var p, err = fwd.ParseRouteProtocol(name)
+		if err != nil {
+			return nil, err
+		}
+		f.ExcludeProtocols = append(f.ExcludeProtocols, p)
+	}
+	return f, nil
+}
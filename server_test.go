@@ -0,0 +1,68 @@
+package fwd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/advancevillage/3rd/logx"
+	"github.com/advancevillage/3rd/netx"
+	"github.com/advancevillage/fwd/pkg/bpf"
+	"github.com/advancevillage/fwd/pkg/fwd"
+	"github.com/stretchr/testify/assert"
+)
+
+//表与XDP程序同在bpffs下固定, 同名时GCProg会删除表导致程序加载失败
+func Test_table_names(t *testing.T) {
+	logger, err := logx.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	c, err := fwd.NewFwdClient(logger, fwd.WithBackend(bpf.BackendSyscall))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	names, err := c.Tables(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var seen = make(map[string]bool, len(names))
+	for _, n := range names {
+		assert.NotEqual(t, progName, n)
+		assert.False(t, seen[n], n)
+		seen[n] = true
+	}
+}
+
+type fakeWriter struct {
+	netx.IHTTPWriteReader
+	body   []byte
+	writes []interface{}
+}
+
+func (w *fakeWriter) Read() ([]byte, error) {
+	return w.body, nil
+}
+
+func (w *fakeWriter) Write(code int, body interface{}) {
+	w.writes = append(w.writes, body)
+}
+
+//请求参数格式错误时仅写一次响应
+func Test_http_handler_write_once(t *testing.T) {
+	var s = &Srv{metrics: newSrvMetrics()}
+	for _, body := range []string{
+		`{"action":"BatchUpdateForward","traceId":"t1","entries":"x"}`,
+		`{"action":"CreateGroup","traceId":"t1","group":"x"}`,
+		`{"action":"NoSuchAction","traceId":"t1"}`,
+	} {
+		var wr = &fakeWriter{body: []byte(body)}
+		s.httpHandler(context.TODO(), wr)
+		assert.Equal(t, 1, len(wr.writes), body)
+		var r, ok = wr.writes[0].(interface{ GetCode() uint32 })
+		assert.True(t, ok, body)
+		assert.Equal(t, SrvErr, r.GetCode(), body)
+	}
+}
@@ -0,0 +1,142 @@
+package fwd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/advancevillage/3rd/logx"
+	"github.com/advancevillage/fwd/pkg/fwd"
+)
+
+//快照命令
+const (
+	SnapshotSave    = "save"
+	SnapshotRestore = "restore"
+)
+
+//fwd snapshot save|restore [file]
+//直接读写固定(pin)的转发表, 不依赖守护进程
+func Snapshot(cfg *SrvCfg, op string, file string) error {
+	if len(file) <= 0 {
+		file = cfg.SnapshotCfg.File
+	}
+	if len(file) <= 0 {
+		return errors.New("snapshot file is empty")
+	}
+	var logger, err = logx.NewLogger(cfg.LogCfg.Level)
+	if err != nil {
+		return err
+	}
+	fwdCli, err := fwd.NewFwdClient(logger, fwd.WithBackend(cfg.BpfCfg.Backend))
+	if err != nil {
+		return err
+	}
+	var (
+		ctx = context.Background()
+		n   int
+	)
+	switch op {
+	case SnapshotSave:
+		n, err = saveSnapshot(ctx, fwdCli, file)
+	case SnapshotRestore:
+		n, err = restoreSnapshot(ctx, fwdCli, file)
+	default:
+		return fmt.Errorf("snapshot %s is not supported", op)
+	}
+	if err != nil {
+		return err
+	}
+	logger.Infow(ctx, "snapshot", "op", op, "file", file, "entries", n)
+	return nil
+}
+
+//先写临时文件再重命名, 避免写入中断破坏已有快照
+func saveSnapshot(ctx context.Context, fwdCli fwd.IFwd, file string) (int, error) {
+	var f, err = ioutil.TempFile(filepath.Dir(file), filepath.Base(file)+".*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(f.Name())
+
+	n, err := fwdCli.Snapshot(ctx, f)
+	if err != nil {
+		f.Close()
+		return 0, err
+	}
+	err = f.Sync()
+	if err != nil {
+		f.Close()
+		return 0, err
+	}
+	err = f.Close()
+	if err != nil {
+		return 0, err
+	}
+	return n, os.Rename(f.Name(), file)
+}
+
+func restoreSnapshot(ctx context.Context, fwdCli fwd.IFwd, file string) (int, error) {
+	var f, err = os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return fwdCli.Restore(ctx, f)
+}
+
+//转发表无静态表项时从快照恢复, 如主机重启或表被GCTable清理
+//XDP在attach后即开始学习, 学习表项不计入, 否则快照不会被恢复且随后被周期快照覆盖
+func (s *Srv) restore(ctx context.Context) {
+	var file = s.cfg.SnapshotCfg.File
+	if !s.cfg.SnapshotCfg.Restore || len(file) <= 0 {
+		return
+	}
+	var elems, err = s.fwdCli.ScanFwd(ctx)
+	if err != nil {
+		s.logger.Errorw(ctx, "restore snapshot fail", "file", file, "err", err)
+		return
+	}
+	for _, e := range elems {
+		if e.Origin != fwd.OriginLearned {
+			return
+		}
+	}
+	n, err := restoreSnapshot(ctx, s.fwdCli, file)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		s.logger.Infow(ctx, "snapshot not found", "file", file)
+	case err != nil:
+		s.logger.Errorw(ctx, "restore snapshot fail", "file", file, "entries", n, "err", err)
+	default:
+		s.logger.Infow(ctx, "restore snapshot", "file", file, "entries", n)
+	}
+}
+
+func (s *Srv) snapshot(ctx context.Context) {
+	var file = s.cfg.SnapshotCfg.File
+	var n, err = saveSnapshot(ctx, s.fwdCli, file)
+	if err != nil {
+		s.logger.Errorw(ctx, "save snapshot fail", "file", file, "err", err)
+		return
+	}
+	s.logger.Infow(ctx, "save snapshot", "file", file, "entries", n)
+}
+
+//周期保存快照
+func (s *Srv) snapshots() {
+	var ticker = time.NewTicker(time.Duration(s.cfg.SnapshotCfg.Interval) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			s.snapshot(s.ctx)
+		}
+	}
+}
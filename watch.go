@@ -0,0 +1,278 @@
+package fwd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/advancevillage/3rd/logx"
+	"github.com/advancevillage/fwd/pkg/fwd"
+	"github.com/advancevillage/fwd/proto"
+)
+
+var (
+	ErrWatchSeq = errors.New("watch sequence expired")
+)
+
+var (
+	watchLogSize  = 4096
+	watchInterval = time.Second
+)
+
+//变更类型
+const (
+	EventAdd    = "add"
+	EventUpdate = "update"
+	EventDelete = "delete"
+	EventSync   = "sync"
+)
+
+//Seq 单调递增, 客户端断线后携带最后收到的Seq续订
+type fwdEvent struct {
+	Seq   uint64
+	Type  string
+	Entry *fwd.FwdElem
+}
+
+//表项变更通过周期比对转发表产生, 同时覆盖API下发及XDP slow_fwd学习的表项
+//
+// 最近watchLogSize条事件保留在内存, 续订的Seq早于保留范围时需重新获取全量
+// 无订阅方时不做周期比对, 仅在API变更表项时比对
+type watcher struct {
+	mu     sync.Mutex
+	seq    uint64
+	subs   int
+	state  map[string]*fwd.FwdElem
+	log    []*fwdEvent
+	notify chan struct{}
+	kick   chan struct{}
+}
+
+func newWatcher() *watcher {
+	return &watcher{
+		state:  make(map[string]*fwd.FwdElem),
+		notify: make(chan struct{}),
+		kick:   make(chan struct{}, 1),
+	}
+}
+
+//表项变更后立即触发比对
+func (w *watcher) trigger() {
+	select {
+	case w.kick <- struct{}{}:
+	default:
+	}
+}
+
+//订阅方退出时调用返回的函数, 订阅时立即触发比对以尽快获取学习的表项
+func (w *watcher) subscribe() func() {
+	w.mu.Lock()
+	w.subs++
+	w.mu.Unlock()
+	w.trigger()
+	return func() {
+		w.mu.Lock()
+		w.subs--
+		w.mu.Unlock()
+	}
+}
+
+func (w *watcher) active() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.subs > 0
+}
+
+//与当前转发表比对, 生成变更事件
+func (w *watcher) sync(elems []*fwd.FwdElem) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var (
+		events = make([]*fwdEvent, 0, 2)
+		next   = make(map[string]*fwd.FwdElem, len(elems))
+	)
+	for _, e := range elems {
+		//计数随转发持续变化, 续约仅推迟到期时间, 均不作为变更
+		var c = *e
+		c.Packets, c.Bytes, c.Deadline = 0, 0, 0
+		c.Policer.ExceedPkts, c.Policer.ExceedBytes = 0, 0
+		var k = w.key(&c)
+		next[k] = &c
+		var old, ok = w.state[k]
+		switch {
+		case !ok:
+			events = append(events, &fwdEvent{Type: EventAdd, Entry: &c})
+		case *old != c:
+			events = append(events, &fwdEvent{Type: EventUpdate, Entry: &c})
+		}
+	}
+	for k, e := range w.state {
+		if _, ok := next[k]; !ok {
+			events = append(events, &fwdEvent{Type: EventDelete, Entry: e})
+		}
+	}
+	w.state = next
+	if len(events) <= 0 {
+		return
+	}
+	//同批事件按key排序, 保证输出稳定
+	sort.Slice(events, func(i, j int) bool { return w.key(events[i].Entry) < w.key(events[j].Entry) })
+	for _, ev := range events {
+		w.seq++
+		ev.Seq = w.seq
+	}
+	w.log = append(w.log, events...)
+	if len(w.log) > watchLogSize {
+		w.log = append([]*fwdEvent{}, w.log[len(w.log)-watchLogSize:]...)
+	}
+	close(w.notify)
+	w.notify = make(chan struct{})
+}
+
+//snapshot为true时返回全量表项及其对应的Seq, 否则返回seq之后的事件
+//wait 在下一批事件产生时关闭
+func (w *watcher) since(seq uint64, snapshot bool) ([]*fwdEvent, []*fwd.FwdElem, uint64, <-chan struct{}, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if snapshot {
+		var snapshot = make([]*fwd.FwdElem, 0, len(w.state))
+		for _, e := range w.state {
+			snapshot = append(snapshot, e)
+		}
+		sort.Slice(snapshot, func(i, j int) bool { return w.key(snapshot[i]) < w.key(snapshot[j]) })
+		return nil, snapshot, w.seq, w.notify, nil
+	}
+	if seq > w.seq {
+		return nil, nil, w.seq, w.notify, ErrWatchSeq
+	}
+	if seq == w.seq {
+		return nil, nil, w.seq, w.notify, nil
+	}
+	if len(w.log) <= 0 || seq+1 < w.log[0].Seq {
+		return nil, nil, w.seq, w.notify, ErrWatchSeq
+	}
+	var events = append([]*fwdEvent{}, w.log[seq+1-w.log[0].Seq:]...)
+	return events, nil, w.seq, w.notify, nil
+}
+
+//策略表与主表可存在相同前缀, key包含表ID
+func (w *watcher) key(e *fwd.FwdElem) string {
+	return fmt.Sprintf("%d:%s/%d", e.Table, e.Ip, e.Prefix)
+}
+
+//有订阅方时周期比对转发表, 捕获XDP slow_fwd学习及LRU淘汰的表项
+//bpftool后端每次比对需多次fork, 无订阅方时仅响应kick
+func (s *Srv) watch() {
+	var interval = watchInterval
+	if s.cfg.WatchCfg.Interval > 0 {
+		interval = time.Duration(s.cfg.WatchCfg.Interval) * time.Second
+	}
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		var tick <-chan time.Time
+		if s.watcher.active() {
+			tick = ticker.C
+		}
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-tick:
+		case <-s.watcher.kick:
+		}
+		s.syncWatch(s.ctx)
+	}
+}
+
+func (s *Srv) syncWatch(ctx context.Context) {
+	var elems, err = s.fwdCli.ScanFwd(ctx)
+	if err != nil {
+		s.logger.Errorw(ctx, "watch forward sync fail", "err", err)
+		return
+	}
+	s.watcher.sync(elems)
+}
+
+//GET /watch?seq=N 以NDJSON持续推送变更事件, 每行一个fwdEvent
+//seq为0时先推送全量表项及sync事件, 续订的seq已过期时返回410, 需以seq为0重新订阅
+func (s *Srv) watchStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var (
+		ctx   = context.WithValue(r.Context(), logx.TraceId, r.Header.Get("X-Trace-Id"))
+		q     = r.URL.Query().Get("seq")
+		seq   uint64
+		err   error
+		enc   = json.NewEncoder(w)
+		fl, _ = w.(http.Flusher)
+	)
+	if len(q) > 0 {
+		seq, err = strconv.ParseUint(q, 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	var snapshot = seq == 0
+	defer s.watcher.subscribe()()
+	for first := true; ; first = false {
+		var events, elems, cur, wait, err = s.watcher.since(seq, snapshot)
+		if err != nil {
+			s.logger.Warnw(ctx, "watch forward fail", "seq", seq, "err", err)
+			//已开始推送时直接断开, 客户端续订时获得410
+			if first {
+				s.metrics.request("WatchForward", SrvGone)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusGone)
+				_ = enc.Encode(&proto.ActionResponse{Code: SrvGone, Errors: []*proto.Error{{Code: WatchSeqCode, Msg: WatchSeqMsg}}})
+			}
+			return
+		}
+		if first {
+			s.metrics.request("WatchForward", SrvOk)
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusOK)
+		}
+		if snapshot {
+			for _, e := range elems {
+				err = enc.Encode(&fwdEvent{Seq: cur, Type: EventAdd, Entry: e})
+				if err != nil {
+					return
+				}
+			}
+			err = enc.Encode(&fwdEvent{Seq: cur, Type: EventSync})
+			if err != nil {
+				return
+			}
+			snapshot = false
+		}
+		for _, ev := range events {
+			err = enc.Encode(ev)
+			if err != nil {
+				return
+			}
+		}
+		if fl != nil {
+			fl.Flush()
+		}
+		seq = cur
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.ctx.Done():
+			return
+		case <-wait:
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package fwd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/advancevillage/3rd/logx"
+	"github.com/advancevillage/fwd/pkg/fwd"
+	"github.com/stretchr/testify/assert"
+)
+
+var watchTest = map[string]struct {
+	rounds [][]*fwd.FwdElem
+	seq    uint64
+	types  []string
+	err    error
+}{
+	"case-add-update-delete": {
+		rounds: [][]*fwd.FwdElem{
+			{{Ip: "10.0.0.1", Prefix: 32, Iface: 4}, {Ip: "10.1.0.0", Prefix: 16, Iface: 4}},
+			{{Ip: "10.0.0.1", Prefix: 32, Iface: 5, Packets: 10}},
+		},
+		seq:   0,
+		types: []string{EventAdd, EventAdd, EventUpdate, EventDelete},
+	},
+	"case-resume": {
+		rounds: [][]*fwd.FwdElem{
+			{{Ip: "10.0.0.1", Prefix: 32, Iface: 4}},
+			{{Ip: "10.0.0.1", Prefix: 32, Iface: 4}, {Ip: "10.0.0.2", Prefix: 32, Iface: 4}},
+		},
+		seq:   1,
+		types: []string{EventAdd},
+	},
+	"case-counter-only": {
+		rounds: [][]*fwd.FwdElem{
+			{{Ip: "10.0.0.1", Prefix: 32, Iface: 4}},
+			{{Ip: "10.0.0.1", Prefix: 32, Iface: 4, Packets: 1, Bytes: 64}},
+		},
+		seq:   1,
+		types: []string{},
+	},
+	"case-policy-table": {
+		rounds: [][]*fwd.FwdElem{
+			{{Ip: "10.2.0.0", Prefix: 16, Iface: 4}, {Ip: "10.2.0.0", Prefix: 16, Iface: 5, Table: 10}},
+			{{Ip: "10.2.0.0", Prefix: 16, Iface: 4}, {Ip: "10.2.0.0", Prefix: 16, Iface: 5, Table: 10}},
+		},
+		seq:   0,
+		types: []string{EventAdd, EventAdd},
+	},
+	"case-future-seq": {
+		rounds: [][]*fwd.FwdElem{
+			{{Ip: "10.0.0.1", Prefix: 32, Iface: 4}},
+		},
+		seq: 9,
+		err: ErrWatchSeq,
+	},
+}
+
+func Test_watch(t *testing.T) {
+	for n, p := range watchTest {
+		f := func(t *testing.T) {
+			var w = newWatcher()
+			for _, r := range p.rounds {
+				w.sync(r)
+			}
+			var events, _, cur, _, err = w.since(p.seq, false)
+			assert.Equal(t, p.err, err)
+			if err != nil {
+				return
+			}
+			var types = make([]string, 0, len(events))
+			for i, ev := range events {
+				types = append(types, ev.Type)
+				assert.Equal(t, p.seq+uint64(i)+1, ev.Seq)
+			}
+			assert.Equal(t, p.types, types)
+			assert.Equal(t, p.seq+uint64(len(events)), cur)
+		}
+		t.Run(n, f)
+	}
+}
+
+func Test_watch_expired(t *testing.T) {
+	var w = newWatcher()
+	for i := 0; i <= watchLogSize; i++ {
+		//每轮交替新增删除同一表项
+		if i%2 == 0 {
+			w.sync([]*fwd.FwdElem{{Ip: "10.0.0.1", Prefix: 32}})
+		} else {
+			w.sync(nil)
+		}
+	}
+	var _, _, _, _, err = w.since(0, false)
+	assert.Equal(t, ErrWatchSeq, err)
+	_, snapshot, cur, _, err := w.since(0, true)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(watchLogSize+1), cur)
+	assert.Equal(t, 1, len(snapshot))
+	events, _, _, _, err := w.since(1, false)
+	assert.Nil(t, err)
+	assert.Equal(t, watchLogSize, len(events))
+}
+
+func Test_watch_stream(t *testing.T) {
+	logger, err := logx.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var ctx, cancel = context.WithCancel(context.TODO())
+	defer cancel()
+	var s = &Srv{watcher: newWatcher(), metrics: newSrvMetrics(), logger: logger, ctx: ctx}
+	var ts = httptest.NewServer(http.HandlerFunc(s.watchStream))
+	defer ts.Close()
+	s.watcher.sync([]*fwd.FwdElem{{Ip: "10.0.0.1", Prefix: 32, Iface: 4}})
+
+	//1. 过期的seq
+	rsp, err := http.Get(ts.URL + "?seq=9")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	rsp.Body.Close()
+	assert.Equal(t, http.StatusGone, rsp.StatusCode)
+
+	//2. 全量后持续推送变更
+	rsp, err = http.Get(ts.URL + "?seq=0")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer rsp.Body.Close()
+	assert.Equal(t, http.StatusOK, rsp.StatusCode)
+	var (
+		sc   = bufio.NewScanner(rsp.Body)
+		next = func() *fwdEvent {
+			var ev = &fwdEvent{}
+			if assert.True(t, sc.Scan()) {
+				assert.Nil(t, json.Unmarshal(sc.Bytes(), ev))
+			}
+			return ev
+		}
+	)
+	var ev = next()
+	assert.Equal(t, EventAdd, ev.Type)
+	assert.Equal(t, uint64(1), ev.Seq)
+	ev = next()
+	assert.Equal(t, EventSync, ev.Type)
+	s.watcher.sync([]*fwd.FwdElem{{Ip: "10.0.0.1", Prefix: 32, Iface: 5}})
+	ev = next()
+	assert.Equal(t, EventUpdate, ev.Type)
+	assert.Equal(t, uint64(2), ev.Seq)
+	assert.Equal(t, uint32(5), ev.Entry.Iface)
+}
+
+func Test_watch_subscribe(t *testing.T) {
+	var w = newWatcher()
+	assert.False(t, w.active())
+	var unsub = w.subscribe()
+	assert.True(t, w.active())
+	//订阅时立即触发比对
+	assert.Equal(t, 1, len(w.kick))
+	var unsub2 = w.subscribe()
+	unsub()
+	assert.True(t, w.active())
+	unsub2()
+	assert.False(t, w.active())
+}